@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AutoReply maps an inbound keyword to a canned outbound reply
+type AutoReply struct {
+	Keyword   string    `json:"keyword"`
+	Reply     string    `json:"reply"`
+	OptOut    bool      `json:"opt_out"` // Sender is added to the opt-out list when matched
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OptOut represents a number that has opted out of outbound messages
+type OptOut struct {
+	PhoneNumber string    `json:"phone_number"`
+	Keyword     string    `json:"keyword"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NormalizeKeyword converts message text or a configured keyword into its matching form
+func NormalizeKeyword(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+// SetAutoReply creates or replaces the auto-reply for a keyword
+func SetAutoReply(keyword, reply string, optOut bool) error {
+	query := `
+		INSERT INTO auto_replies (keyword, reply, opt_out, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(keyword) DO UPDATE SET reply = excluded.reply, opt_out = excluded.opt_out
+	`
+	_, err := DB.Exec(query, NormalizeKeyword(keyword), reply, optOut, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to set auto-reply: %w", err)
+	}
+	return nil
+}
+
+// GetAutoReply retrieves the auto-reply matching the given text, or nil if none is configured
+func GetAutoReply(text string) (*AutoReply, error) {
+	var ar AutoReply
+	err := DB.QueryRow("SELECT keyword, reply, opt_out, created_at FROM auto_replies WHERE keyword = ?", NormalizeKeyword(text)).
+		Scan(&ar.Keyword, &ar.Reply, &ar.OptOut, &ar.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get auto-reply: %w", err)
+	}
+	return &ar, nil
+}
+
+// GetAutoReplies retrieves all configured auto-replies, ordered by keyword
+func GetAutoReplies() ([]AutoReply, error) {
+	rows, err := DB.Query("SELECT keyword, reply, opt_out, created_at FROM auto_replies ORDER BY keyword")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query auto-replies: %w", err)
+	}
+	defer rows.Close()
+
+	replies := []AutoReply{}
+	for rows.Next() {
+		var ar AutoReply
+		if err := rows.Scan(&ar.Keyword, &ar.Reply, &ar.OptOut, &ar.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan auto-reply: %w", err)
+		}
+		replies = append(replies, ar)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating auto-reply rows: %w", err)
+	}
+
+	return replies, nil
+}
+
+// DeleteAutoReply removes the auto-reply for a keyword, reporting whether it existed
+func DeleteAutoReply(keyword string) (bool, error) {
+	result, err := DB.Exec("DELETE FROM auto_replies WHERE keyword = ?", NormalizeKeyword(keyword))
+	if err != nil {
+		return false, fmt.Errorf("failed to delete auto-reply: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// AddOptOut records that a number has opted out via the given keyword
+func AddOptOut(phoneNumber, keyword string) error {
+	query := `
+		INSERT INTO opt_outs (phone_number, keyword, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(phone_number) DO NOTHING
+	`
+	_, err := DB.Exec(query, phoneNumber, NormalizeKeyword(keyword), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add opt-out: %w", err)
+	}
+	return nil
+}
+
+// RemoveOptOut removes a number from the opt-out list, reporting whether it was present
+func RemoveOptOut(phoneNumber string) (bool, error) {
+	result, err := DB.Exec("DELETE FROM opt_outs WHERE phone_number = ?", phoneNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove opt-out: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// GetOptOuts retrieves all opted-out numbers, newest first
+func GetOptOuts() ([]OptOut, error) {
+	rows, err := DB.Query("SELECT phone_number, keyword, created_at FROM opt_outs ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query opt-outs: %w", err)
+	}
+	defer rows.Close()
+
+	optOuts := []OptOut{}
+	for rows.Next() {
+		var o OptOut
+		var keyword sql.NullString
+		if err := rows.Scan(&o.PhoneNumber, &keyword, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan opt-out: %w", err)
+		}
+		o.Keyword = keyword.String
+		optOuts = append(optOuts, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating opt-out rows: %w", err)
+	}
+
+	return optOuts, nil
+}
+
+// IsOptedOut returns whether the number is on the opt-out list
+func IsOptedOut(phoneNumber string) bool {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM opt_outs WHERE phone_number = ?", phoneNumber).Scan(&count)
+	if err != nil {
+		return false
+	}
+	return count > 0
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BlockedNumber represents a number that outbound messages must not be sent to
+type BlockedNumber struct {
+	PhoneNumber string    `json:"phone_number"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AddBlockedNumber adds a number to the blocklist, updating the reason if already present
+func AddBlockedNumber(phoneNumber, reason string) error {
+	query := `
+		INSERT INTO blocked_numbers (phone_number, reason, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(phone_number) DO UPDATE SET reason = excluded.reason
+	`
+	_, err := DB.Exec(query, phoneNumber, reason, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add blocked number: %w", err)
+	}
+	return nil
+}
+
+// RemoveBlockedNumber removes a number from the blocklist, reporting whether it was present
+func RemoveBlockedNumber(phoneNumber string) (bool, error) {
+	result, err := DB.Exec("DELETE FROM blocked_numbers WHERE phone_number = ?", phoneNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove blocked number: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// GetBlockedNumbers retrieves all blocked numbers, newest first
+func GetBlockedNumbers() ([]BlockedNumber, error) {
+	rows, err := DB.Query("SELECT phone_number, reason, created_at FROM blocked_numbers ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocked numbers: %w", err)
+	}
+	defer rows.Close()
+
+	blocked := []BlockedNumber{}
+	for rows.Next() {
+		var b BlockedNumber
+		var reason sql.NullString
+		if err := rows.Scan(&b.PhoneNumber, &reason, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocked number: %w", err)
+		}
+		b.Reason = reason.String
+		blocked = append(blocked, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating blocked number rows: %w", err)
+	}
+
+	return blocked, nil
+}
+
+// IsBlocked returns whether the number is on the blocklist
+func IsBlocked(phoneNumber string) bool {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM blocked_numbers WHERE phone_number = ?", phoneNumber).Scan(&count)
+	if err != nil {
+		return false
+	}
+	return count > 0
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CostGroup is the summed cost of the messages sharing a profile or a day
+type CostGroup struct {
+	Key          string  // Messaging profile ID or UTC day (YYYY-MM-DD)
+	Amount       float64 // USD
+	MessageCount int
+}
+
+// CostSummary is the summed stored cost of every billed message, including soft-deleted ones
+type CostSummary struct {
+	Total        float64
+	MessageCount int
+	ByProfile    []CostGroup // Ordered by profile ID
+	ByDay        []CostGroup // Ordered by day
+}
+
+// GetCostSummary sums the stored per-message costs, grouped by profile and by UTC day. Messages
+// without a stored cost (inbound, or saved before costs were persisted) are left out. Sums are kept
+// in the same fixed precision as spend limits so totals don't pick up floating point drift.
+func GetCostSummary() (CostSummary, error) {
+	rows, err := DB.Query("SELECT COALESCE(messaging_profile_id, ''), created_at, cost FROM messages WHERE cost > 0")
+	if err != nil {
+		return CostSummary{}, fmt.Errorf("failed to query message costs: %w", err)
+	}
+	defer rows.Close()
+
+	type tally struct {
+		units int64
+		count int
+	}
+	var total tally
+	byProfile := map[string]*tally{}
+	byDay := map[string]*tally{}
+	add := func(groups map[string]*tally, key string, units int64) {
+		if groups[key] == nil {
+			groups[key] = &tally{}
+		}
+		groups[key].units += units
+		groups[key].count++
+	}
+
+	for rows.Next() {
+		var profileID string
+		var createdAt time.Time
+		var cost float64
+		if err := rows.Scan(&profileID, &createdAt, &cost); err != nil {
+			return CostSummary{}, fmt.Errorf("failed to scan message cost: %w", err)
+		}
+		units := toSpendUnits(cost)
+		total.units += units
+		total.count++
+		add(byProfile, profileID, units)
+		add(byDay, spendDay(createdAt), units)
+	}
+	if err := rows.Err(); err != nil {
+		return CostSummary{}, fmt.Errorf("error iterating message cost rows: %w", err)
+	}
+
+	groups := func(tallies map[string]*tally) []CostGroup {
+		result := make([]CostGroup, 0, len(tallies))
+		for key, t := range tallies {
+			result = append(result, CostGroup{Key: key, Amount: fromSpendUnits(t.units), MessageCount: t.count})
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+		return result
+	}
+
+	return CostSummary{
+		Total:        fromSpendUnits(total.units),
+		MessageCount: total.count,
+		ByProfile:    groups(byProfile),
+		ByDay:        groups(byDay),
+	}, nil
+}
@@ -4,22 +4,78 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"telnyx-mock/internal/events"
+	"telnyx-mock/internal/sms"
 	_ "modernc.org/sqlite"
 )
 
 type Message struct {
-	ID                 string    `json:"id"`
-	CreatedAt          time.Time `json:"created_at"`
-	Sender             string    `json:"sender"`
-	Recipient          string    `json:"recipient"`
-	Content            string    `json:"content"`
-	MediaURLs          string    `json:"media_urls"` // Stored as JSON string
-	MessagingProfileID string    `json:"messaging_profile_id"`
-	Direction          string    `json:"direction"`
+	ID                 string     `json:"id"`
+	CreatedAt          time.Time  `json:"created_at"`
+	Sender             string     `json:"sender"`
+	Recipient          string     `json:"recipient"`
+	Content            string     `json:"content"`
+	MediaURLs          string     `json:"media_urls"` // Stored as JSON string
+	MessagingProfileID string     `json:"messaging_profile_id"`
+	Direction          string     `json:"direction"`
+	Status             string     `json:"status"`
+	ValidUntil         *time.Time `json:"valid_until"`
+	WebhookURL         string     `json:"webhook_url"`
+	WebhookFailoverURL string     `json:"webhook_failover_url"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"`   // Set when soft-deleted
+	ReceivedAt         time.Time  `json:"received_at"`            // Provider-reported time, falls back to created_at
+	Seq                int64      `json:"seq"`                    // Increases with every insert; a cursor for incremental sync
+	Encoding           string     `json:"encoding,omitempty"`     // "GSM-7" or "UCS-2" for messages sent through the API
+	Tags               []string   `json:"tags,omitempty"`         // Tags carried by an inbound Telnyx webhook
+	WebhookURLs        []string   `json:"webhook_urls,omitempty"` // Fan-out webhook consumers, replacing webhook_url
+	Type               string     `json:"type,omitempty"`         // "SMS" or "MMS" as classified when the message was sent through the API
+	Parts              int        `json:"parts,omitempty"`        // Parts reported and billed when the message was sent through the API
+	Priority           string     `json:"priority,omitempty"`         // Delivery priority requested at send time
+	WebhookDelayMs     *int       `json:"webhook_delay_ms,omitempty"` // Per-message webhook delay requested at send time
+	From               Endpoint   `json:"from"`                   // Sender with its derived carrier and line type
 }
 
+// Endpoint is a message's sender as reported in API responses
+type Endpoint struct {
+	PhoneNumber string `json:"phone_number"`
+	Carrier     string `json:"carrier"`
+	LineType    string `json:"line_type"`
+}
+
+// NewEndpoint describes number with the carrier and line type derived from it
+func NewEndpoint(number string) Endpoint {
+	return Endpoint{PhoneNumber: number, Carrier: sms.MockCarrier, LineType: sms.LineType(number)}
+}
+
+// MessageOptions holds optional lifecycle fields stored alongside a message
+type MessageOptions struct {
+	Status             string    // Defaults to "queued" for outbound and "received" for inbound
+	ValidUntil         time.Time // Zero means the message never expires
+	WebhookURL         string
+	WebhookFailoverURL string
+	CreatedAt          time.Time // Defaults to now
+	ReceivedAt         time.Time // Defaults to CreatedAt
+	Replace            bool      // Overwrite an existing message with the same ID instead of failing
+	Cost               float64   // Estimated cost in USD; zero for messages that aren't billed
+	Encoding           string    // Encoding reported in the create response; empty for inbound messages
+	Tags               []string
+	WebhookURLs        []string // Fan-out webhook consumers
+	Type               string   // "SMS" or "MMS" as classified at send time; empty for inbound messages
+	Parts              int      // Parts reported and billed at send time, including a forced count; zero for inbound messages
+	Recipients         []string // Every participant of a group message, each tracked with its own status
+	Priority           string   // Delivery priority requested at send time; empty for normal
+	WebhookDelayMs     *int     // Per-message webhook delay requested at send time; nil for the default timing
+}
+
+// messageColumns lists the columns scanned by scanMessage, in order
+const messageColumns = `id, created_at, sender, recipient, content, media_urls, messaging_profile_id, direction,
+	status, valid_until, webhook_url, webhook_failover_url, deleted_at, received_at, seq, encoding, tags, webhook_urls, message_type, parts,
+	priority, webhook_delay_ms`
+
 // LogEntry represents an application log entry
 type LogEntry struct {
 	ID        int64     `json:"id"`
@@ -32,13 +88,21 @@ type LogEntry struct {
 
 var DB *sql.DB
 
+// LogEvents publishes every log entry as it is written, for live tailing
+var LogEvents = events.NewBroadcaster[LogEntry](64)
+
+// dbFilePath is the path of the open database file, used to report its size
+var dbFilePath string
+
 // InitDB initializes the SQLite database and creates the messages table
 func InitDB(dbPath string) error {
 	var err error
-	DB, err = sql.Open("sqlite", dbPath)
+	// Wait on locks instead of failing with SQLITE_BUSY when webhook goroutines write concurrently
+	DB, err = sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
+	dbFilePath = dbPath
 
 	// Create messages table
 	createTableSQL := `
@@ -50,7 +114,14 @@ func InitDB(dbPath string) error {
 		content TEXT,
 		media_urls TEXT,
 		messaging_profile_id TEXT,
-		direction TEXT NOT NULL
+		direction TEXT NOT NULL,
+		status TEXT,
+		valid_until DATETIME,
+		webhook_url TEXT,
+		webhook_failover_url TEXT,
+		deleted_at DATETIME,
+		received_at DATETIME,
+		seq INTEGER
 	);
 	`
 
@@ -59,18 +130,37 @@ func InitDB(dbPath string) error {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
-	// Add messaging_profile_id column if it doesn't exist (migration for existing databases)
-	// SQLite doesn't support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so we check first
-	var columnExists int
-	err = DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('messages') WHERE name='messaging_profile_id'").Scan(&columnExists)
-	if err == nil && columnExists == 0 {
-		_, err = DB.Exec("ALTER TABLE messages ADD COLUMN messaging_profile_id TEXT")
-		if err != nil {
-			// Ignore error if column already exists (race condition)
-			_ = err
+	// Add columns introduced after the original schema (migration for existing databases)
+	for _, column := range []struct{ name, ddl string }{
+		{"messaging_profile_id", "TEXT"},
+		{"status", "TEXT"},
+		{"valid_until", "DATETIME"},
+		{"webhook_url", "TEXT"},
+		{"webhook_failover_url", "TEXT"},
+		{"deleted_at", "DATETIME"},
+		{"received_at", "DATETIME"},
+		{"seq", "INTEGER"},
+		{"cost", "REAL"},
+		{"encoding", "TEXT"},
+		{"tags", "TEXT"},
+		{"webhook_urls", "TEXT"},
+		{"message_type", "TEXT"},
+		{"parts", "INTEGER"},
+		{"priority", "TEXT"},
+		{"webhook_delay_ms", "INTEGER"},
+	} {
+		if err := ensureColumn("messages", column.name, column.ddl); err != nil {
+			return err
 		}
 	}
 
+	if err := initMessageSeq(); err != nil {
+		return err
+	}
+	if _, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages (seq)"); err != nil {
+		return fmt.Errorf("failed to create seq index: %w", err)
+	}
+
 	// Create credentials table (single row for API key)
 	createCredentialsSQL := `
 	CREATE TABLE IF NOT EXISTS credentials (
@@ -85,6 +175,14 @@ func InitDB(dbPath string) error {
 		return fmt.Errorf("failed to create credentials table: %w", err)
 	}
 
+	// The key replaced by the last rotation, accepted until previous_expires_at
+	if err := ensureColumn("credentials", "previous_api_key", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn("credentials", "previous_expires_at", "DATETIME"); err != nil {
+		return err
+	}
+
 	// Initialize with default API key if none exists
 	var count int
 	err = DB.QueryRow("SELECT COUNT(*) FROM credentials").Scan(&count)
@@ -93,8 +191,7 @@ func InitDB(dbPath string) error {
 	}
 
 	if count == 0 {
-		defaultKey := "test-token"
-		_, err = DB.Exec("INSERT INTO credentials (id, api_key, updated_at) VALUES (1, ?, ?)", defaultKey, time.Now().UTC())
+		_, err = DB.Exec("INSERT INTO credentials (id, api_key, updated_at) VALUES (1, ?, ?)", DefaultAPIKey, time.Now().UTC())
 		if err != nil {
 			return fmt.Errorf("failed to initialize default credentials: %w", err)
 		}
@@ -113,6 +210,7 @@ func InitDB(dbPath string) error {
 	CREATE INDEX IF NOT EXISTS idx_logs_created_at ON logs(created_at);
 	CREATE INDEX IF NOT EXISTS idx_logs_level ON logs(level);
 	CREATE INDEX IF NOT EXISTS idx_logs_category ON logs(category);
+	CREATE INDEX IF NOT EXISTS idx_logs_message_id ON logs(` + logMessageIDExpr + `);
 	`
 
 	_, err = DB.Exec(createLogsSQL)
@@ -134,6 +232,186 @@ func InitDB(dbPath string) error {
 		return fmt.Errorf("failed to create settings table: %w", err)
 	}
 
+	// Create auto-reply and opt-out tables for keyword compliance flows (STOP/HELP)
+	createAutoRepliesSQL := `
+	CREATE TABLE IF NOT EXISTS auto_replies (
+		keyword TEXT PRIMARY KEY,
+		reply TEXT NOT NULL,
+		opt_out INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS opt_outs (
+		phone_number TEXT PRIMARY KEY,
+		keyword TEXT,
+		created_at DATETIME NOT NULL
+	);
+	`
+
+	_, err = DB.Exec(createAutoRepliesSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create auto-reply tables: %w", err)
+	}
+
+	// Create blocked numbers table for deterministic outbound rejection
+	createBlockedNumbersSQL := `
+	CREATE TABLE IF NOT EXISTS blocked_numbers (
+		phone_number TEXT PRIMARY KEY,
+		reason TEXT,
+		created_at DATETIME NOT NULL
+	);
+	`
+
+	_, err = DB.Exec(createBlockedNumbersSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create blocked numbers table: %w", err)
+	}
+
+	// Create messaging profiles table; per-profile settings are stored as JSON
+	createProfilesSQL := `
+	CREATE TABLE IF NOT EXISTS messaging_profiles (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		response_overrides TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`
+
+	_, err = DB.Exec(createProfilesSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create messaging profiles table: %w", err)
+	}
+	if err := ensureColumn("messaging_profiles", "daily_spend_limit", "REAL"); err != nil {
+		return err
+	}
+
+	// Create profile spend table; one row per profile per UTC day, in ten-thousandths of a dollar
+	createProfileSpendSQL := `
+	CREATE TABLE IF NOT EXISTS profile_spend (
+		messaging_profile_id TEXT NOT NULL,
+		day TEXT NOT NULL,
+		amount_units INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (messaging_profile_id, day)
+	);
+	`
+
+	_, err = DB.Exec(createProfileSpendSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create profile spend table: %w", err)
+	}
+
+	// Create status history table; one row per status a message has been in, for its timeline
+	createStatusHistorySQL := `
+	CREATE TABLE IF NOT EXISTS message_status_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		occurred_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_message_status_history_message_id ON message_status_history (message_id);
+	`
+
+	_, err = DB.Exec(createStatusHistorySQL)
+	if err != nil {
+		return fmt.Errorf("failed to create message status history table: %w", err)
+	}
+
+	// Create recipients table; one row per participant of a group message, each with its own status
+	createRecipientsSQL := `
+	CREATE TABLE IF NOT EXISTS message_recipients (
+		message_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		phone_number TEXT NOT NULL,
+		status TEXT NOT NULL,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (message_id, position)
+	);
+	`
+
+	_, err = DB.Exec(createRecipientsSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create message recipients table: %w", err)
+	}
+
+	// Create number pool table; numbers are assigned to a messaging profile
+	createProfileNumbersSQL := `
+	CREATE TABLE IF NOT EXISTS profile_numbers (
+		phone_number TEXT PRIMARY KEY,
+		messaging_profile_id TEXT NOT NULL,
+		use_count INTEGER NOT NULL DEFAULT 0,
+		last_used_at DATETIME,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_profile_numbers_profile ON profile_numbers(messaging_profile_id);
+	`
+
+	_, err = DB.Exec(createProfileNumbersSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create profile numbers table: %w", err)
+	}
+	if err := ensureColumn("profile_numbers", "display_name", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn("profile_numbers", "campaign_registered", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Create idempotency table; the stored response is replayed when a key is reused
+	createIdempotencySQL := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		key TEXT PRIMARY KEY,
+		message_id TEXT NOT NULL,
+		response TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+	`
+
+	_, err = DB.Exec(createIdempotencySQL)
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency table: %w", err)
+	}
+	// The original status is replayed too; older rows predate create_success_status and were 200
+	if err := ensureColumn("idempotency_keys", "status", "INTEGER NOT NULL DEFAULT 200"); err != nil {
+		return err
+	}
+
+	// Create settings profiles table; each row is a named snapshot of the settings as JSON
+	createSettingsProfilesSQL := `
+	CREATE TABLE IF NOT EXISTS settings_profiles (
+		name TEXT PRIMARY KEY,
+		settings TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`
+
+	_, err = DB.Exec(createSettingsProfilesSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create settings profiles table: %w", err)
+	}
+
+	// Create webhook deliveries table; one row per delivery attempt, for the delivery stats
+	createWebhookDeliveriesSQL := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		message_id TEXT,
+		attempt INTEGER NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		success INTEGER NOT NULL,
+		error TEXT,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
+	`
+
+	_, err = DB.Exec(createWebhookDeliveriesSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook deliveries table: %w", err)
+	}
+
 	// Clean up logs older than 7 days on startup
 	if err := CleanupOldLogs(7); err != nil {
 		// Log the error but don't fail initialization
@@ -143,8 +421,57 @@ func InitDB(dbPath string) error {
 	return nil
 }
 
+// ensureColumn adds a column to table if it doesn't exist yet. SQLite doesn't support
+// IF NOT EXISTS for ALTER TABLE ADD COLUMN, so we check pragma_table_info first.
+func ensureColumn(table, name, ddl string) error {
+	var columnExists int
+	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, name).Scan(&columnExists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s columns: %w", table, err)
+	}
+	if columnExists > 0 {
+		return nil
+	}
+	if _, err := DB.Exec("ALTER TABLE " + table + " ADD COLUMN " + name + " " + ddl); err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, name, err)
+	}
+	return nil
+}
+
 // InsertMessage inserts a new message into the database
 func InsertMessage(id, sender, recipient, content string, mediaURLs []string, messagingProfileID string, direction string) error {
+	return InsertMessageWithOptions(id, sender, recipient, content, mediaURLs, messagingProfileID, direction, MessageOptions{})
+}
+
+// InsertMessageWithOptions inserts a new message along with its optional lifecycle fields
+func InsertMessageWithOptions(id, sender, recipient, content string, mediaURLs []string, messagingProfileID string, direction string, opts MessageOptions) error {
+	return insertMessage(DB, id, sender, recipient, content, mediaURLs, messagingProfileID, direction, opts)
+}
+
+// InsertMessagesBatch inserts one message per ID sharing the same fields, in a single transaction
+func InsertMessagesBatch(ids []string, sender, recipient, content string, messagingProfileID string, direction string, opts MessageOptions) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin batch insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if err := insertMessage(tx, id, sender, recipient, content, nil, messagingProfileID, direction, opts); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch insert: %w", err)
+	}
+	return nil
+}
+
+// insertMessage inserts a message using db, which may be the database or a transaction
+func insertMessage(db interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, id, sender, recipient, content string, mediaURLs []string, messagingProfileID string, direction string, opts MessageOptions) error {
 	mediaURLsJSON := "[]"
 	if len(mediaURLs) > 0 {
 		jsonBytes, err := json.Marshal(mediaURLs)
@@ -154,59 +481,407 @@ func InsertMessage(id, sender, recipient, content string, mediaURLs []string, me
 		mediaURLsJSON = string(jsonBytes)
 	}
 
+	var tagsJSON interface{}
+	if len(opts.Tags) > 0 {
+		jsonBytes, err := json.Marshal(opts.Tags)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tags: %w", err)
+		}
+		tagsJSON = string(jsonBytes)
+	}
+
+	var webhookURLsJSON interface{}
+	if len(opts.WebhookURLs) > 0 {
+		jsonBytes, err := json.Marshal(opts.WebhookURLs)
+		if err != nil {
+			return fmt.Errorf("failed to marshal webhook_urls: %w", err)
+		}
+		webhookURLsJSON = string(jsonBytes)
+	}
+
+	status := opts.Status
+	if status == "" {
+		status = "queued"
+		if direction == "inbound" {
+			status = "received"
+		}
+	}
+
+	var validUntil interface{}
+	if !opts.ValidUntil.IsZero() {
+		validUntil = opts.ValidUntil.UTC()
+	}
+
 	query := `
-		INSERT INTO messages (id, created_at, sender, recipient, content, media_urls, messaging_profile_id, direction)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO messages (id, created_at, sender, recipient, content, media_urls, messaging_profile_id, direction,
+			status, valid_until, webhook_url, webhook_failover_url, received_at, seq, cost, encoding, tags, webhook_urls, message_type, parts,
+			priority, webhook_delay_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	if opts.Replace {
+		query += `
+		ON CONFLICT(id) DO UPDATE SET created_at = excluded.created_at, sender = excluded.sender,
+			recipient = excluded.recipient, content = excluded.content, media_urls = excluded.media_urls,
+			messaging_profile_id = excluded.messaging_profile_id, direction = excluded.direction,
+			status = excluded.status, valid_until = excluded.valid_until, webhook_url = excluded.webhook_url,
+			webhook_failover_url = excluded.webhook_failover_url, received_at = excluded.received_at,
+			seq = excluded.seq, cost = excluded.cost, encoding = excluded.encoding,
+			tags = excluded.tags, webhook_urls = excluded.webhook_urls, message_type = excluded.message_type,
+			parts = excluded.parts, priority = excluded.priority, webhook_delay_ms = excluded.webhook_delay_ms,
+			deleted_at = NULL
 	`
+	}
 
-	_, err := DB.Exec(query, id, time.Now().UTC(), sender, recipient, content, mediaURLsJSON, messagingProfileID, direction)
+	createdAt := time.Now().UTC()
+	if !opts.CreatedAt.IsZero() {
+		createdAt = opts.CreatedAt.UTC()
+	}
+	receivedAt := createdAt
+	if !opts.ReceivedAt.IsZero() {
+		receivedAt = opts.ReceivedAt.UTC()
+	}
+
+	seq, err := nextMessageSeq(db)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(query, id, createdAt, sender, recipient, content, mediaURLsJSON, messagingProfileID, direction,
+		status, validUntil, opts.WebhookURL, opts.WebhookFailoverURL, receivedAt, seq, opts.Cost, opts.Encoding, tagsJSON, webhookURLsJSON, opts.Type, opts.Parts,
+		opts.Priority, opts.WebhookDelayMs)
 	if err != nil {
 		return fmt.Errorf("failed to insert message: %w", err)
 	}
 
-	return nil
+	// A replaced message starts over: the old one's recipients and status history don't carry across
+	if opts.Replace {
+		if _, err := db.Exec("DELETE FROM message_recipients WHERE message_id = ?", id); err != nil {
+			return fmt.Errorf("failed to clear message recipients: %w", err)
+		}
+		if _, err := db.Exec("DELETE FROM message_status_history WHERE message_id = ?", id); err != nil {
+			return fmt.Errorf("failed to clear message status history: %w", err)
+		}
+	}
+
+	if len(opts.Recipients) > 0 {
+		if err := recordRecipients(db, id, opts.Recipients, status, createdAt); err != nil {
+			return err
+		}
+	}
+
+	return recordStatus(db, id, status, createdAt)
 }
 
-// GetAllMessages retrieves all messages from the database, ordered by created_at DESC
+// GetAllMessages retrieves all messages that haven't been soft-deleted, ordered by created_at DESC
 func GetAllMessages() ([]Message, error) {
 	query := `
-		SELECT id, created_at, sender, recipient, content, media_urls, messaging_profile_id, direction
+		SELECT ` + messageColumns + `
 		FROM messages
+		WHERE deleted_at IS NULL
 		ORDER BY created_at DESC
 	`
 
-	rows, err := DB.Query(query)
+	return queryMessages(query)
+}
+
+// MessageFilter narrows a message listing; zero-valued fields are ignored
+type MessageFilter struct {
+	Since              time.Time // Only messages created at or after this time
+	Until              time.Time // Only messages created at or before this time
+	Direction          string    // "inbound" or "outbound"
+	MessagingProfileID string
+	IncludeDeleted     bool   // Also return soft-deleted messages
+	AfterSeq           int64  // Only messages with a seq greater than this
+	Sort               string // Column to order by, one of messageSortColumns; defaults to created_at
+	Order              string // "asc" or "desc" (default)
+	Encoding           string // "GSM-7" or "UCS-2"
+}
+
+// messageSortColumns allowlists the columns GetMessages can order by, since ORDER BY can't be parameterized
+var messageSortColumns = []string{"created_at", "sender", "recipient", "seq"}
+
+// GetMessages retrieves messages matching the filter, ordered by created_at DESC unless the filter
+// says otherwise. Unknown sort columns fall back to created_at.
+func GetMessages(filter MessageFilter) ([]Message, error) {
+	query, args := messagesQuery(filter)
+	return queryMessages(query, args...)
+}
+
+// StreamMessages calls fn for each message matching the filter, in GetMessages order, without
+// loading the whole result set into memory. Iteration stops at the first error fn returns.
+func StreamMessages(filter MessageFilter, fn func(Message) error) error {
+	query, args := messagesQuery(filter)
+	return eachMessage(query, args, fn)
+}
+
+// messagesQuery builds the SELECT for a message listing
+func messagesQuery(filter MessageFilter) (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.Direction != "" {
+		conditions = append(conditions, "direction = ?")
+		args = append(args, filter.Direction)
+	}
+	if filter.MessagingProfileID != "" {
+		conditions = append(conditions, "messaging_profile_id = ?")
+		args = append(args, filter.MessagingProfileID)
+	}
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if filter.AfterSeq > 0 {
+		conditions = append(conditions, "seq > ?")
+		args = append(args, filter.AfterSeq)
+	}
+	if filter.Encoding != "" {
+		conditions = append(conditions, "encoding = ?")
+		args = append(args, filter.Encoding)
+	}
+
+	query := `SELECT ` + messageColumns + ` FROM messages`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sortColumn := "created_at"
+	if slices.Contains(messageSortColumns, filter.Sort) {
+		sortColumn = filter.Sort
+	}
+	order := "DESC"
+	if strings.EqualFold(filter.Order, "asc") {
+		order = "ASC"
+	}
+	query += " ORDER BY " + sortColumn + " " + order
+
+	return query, args
+}
+
+// GetMessagesByTimeRange retrieves messages created within [since, until]; a zero bound is open-ended
+func GetMessagesByTimeRange(since, until time.Time) ([]Message, error) {
+	return GetMessages(MessageFilter{Since: since, Until: until})
+}
+
+// queryMessages runs a query selecting messageColumns and scans every row
+func queryMessages(query string, args ...interface{}) ([]Message, error) {
+	messages := []Message{} // Initialize as empty slice, not nil, so JSON encodes as [] not null
+	err := eachMessage(query, args, func(msg Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query messages: %w", err)
+		return nil, err
+	}
+	return messages, nil
+}
+
+// eachMessage runs a query selecting messageColumns and calls fn for each row as it is scanned
+func eachMessage(query string, args []interface{}, fn func(Message) error) error {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query messages: %w", err)
 	}
 	defer rows.Close()
 
-	messages := []Message{} // Initialize as empty slice, not nil, so JSON encodes as [] not null
 	for rows.Next() {
-		var msg Message
-		err := rows.Scan(&msg.ID, &msg.CreatedAt, &msg.Sender, &msg.Recipient, &msg.Content, &msg.MediaURLs, &msg.MessagingProfileID, &msg.Direction)
+		msg, err := scanMessage(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %w", err)
+			return fmt.Errorf("failed to scan message: %w", err)
+		}
+		if err := fn(*msg); err != nil {
+			return err
 		}
-		messages = append(messages, msg)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	return messages, nil
+	return nil
+}
+
+// scanMessage scans a row selected with messageColumns, tolerating NULLs in migrated columns
+func scanMessage(row interface{ Scan(...any) error }) (*Message, error) {
+	var msg Message
+	var profileID, status, webhookURL, failoverURL, encoding, tags, webhookURLs, msgType, priority sql.NullString
+	var validUntil, deletedAt, receivedAt sql.NullTime
+	var seq, parts, webhookDelayMs sql.NullInt64
+	err := row.Scan(&msg.ID, &msg.CreatedAt, &msg.Sender, &msg.Recipient, &msg.Content, &msg.MediaURLs, &profileID, &msg.Direction,
+		&status, &validUntil, &webhookURL, &failoverURL, &deletedAt, &receivedAt, &seq, &encoding, &tags, &webhookURLs, &msgType, &parts,
+		&priority, &webhookDelayMs)
+	if err != nil {
+		return nil, err
+	}
+	msg.MessagingProfileID = profileID.String
+	msg.Status = status.String
+	if validUntil.Valid {
+		msg.ValidUntil = &validUntil.Time
+	}
+	msg.WebhookURL = webhookURL.String
+	msg.WebhookFailoverURL = failoverURL.String
+	if deletedAt.Valid {
+		msg.DeletedAt = &deletedAt.Time
+	}
+	msg.ReceivedAt = msg.CreatedAt
+	if receivedAt.Valid {
+		msg.ReceivedAt = receivedAt.Time
+	}
+	msg.Seq = seq.Int64
+	msg.Encoding = encoding.String
+	msg.Type = msgType.String
+	msg.Parts = int(parts.Int64)
+	msg.Priority = priority.String
+	if webhookDelayMs.Valid {
+		delay := int(webhookDelayMs.Int64)
+		msg.WebhookDelayMs = &delay
+	}
+	msg.From = NewEndpoint(msg.Sender)
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &msg.Tags); err != nil {
+			return nil, fmt.Errorf("failed to parse tags: %w", err)
+		}
+	}
+	if webhookURLs.Valid {
+		if err := json.Unmarshal([]byte(webhookURLs.String), &msg.WebhookURLs); err != nil {
+			return nil, fmt.Errorf("failed to parse webhook_urls: %w", err)
+		}
+	}
+	return &msg, nil
+}
+
+// GetMessageByID retrieves a single message, or nil if it doesn't exist
+func GetMessageByID(id string) (*Message, error) {
+	row := DB.QueryRow("SELECT "+messageColumns+" FROM messages WHERE id = ?", id)
+	msg, err := scanMessage(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	return msg, nil
+}
+
+// UpdateMessageStatus advances the lifecycle status of a message, reporting whether it moved.
+// Messages already expired, failed or delivered are left alone, so a status progression that
+// outlives an expiry sweep can't resurrect the message.
+func UpdateMessageStatus(id, status string) (bool, error) {
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return true, nil
+	}
+
+	result, err := DB.Exec("UPDATE messages SET status = ? WHERE id = ? AND status NOT IN ('expired', 'failed', 'delivered')", status, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to update message status: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	if err := updateRecipientStatuses(DB, id, status, now); err != nil {
+		return false, err
+	}
+	return true, recordStatus(DB, id, status, now)
 }
 
-// ClearAllMessages truncates the messages table
+// ExpireMessages marks undelivered outbound messages whose valid_until has passed as "expired"
+// and returns the messages that were transitioned
+func ExpireMessages(now time.Time) ([]Message, error) {
+	candidates, err := queryMessages(`
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE direction = 'outbound'
+		  AND status IN ('queued', 'sent')
+		  AND valid_until IS NOT NULL
+		  AND valid_until < ?
+		ORDER BY created_at
+	`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []Message{}
+	for _, msg := range candidates {
+		// Guard on status so a message delivered since the SELECT isn't overwritten
+		result, err := DB.Exec("UPDATE messages SET status = 'expired' WHERE id = ? AND status IN ('queued', 'sent')", msg.ID)
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire message: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			msg.Status = "expired"
+			expired = append(expired, msg)
+			if err := updateRecipientStatuses(DB, msg.ID, "expired", now); err != nil {
+				return expired, err
+			}
+			if err := recordStatus(DB, msg.ID, "expired", now.UTC()); err != nil {
+				return expired, err
+			}
+		}
+	}
+
+	return expired, nil
+}
+
+// softDeleteEnabled reports whether deletes should mark messages with deleted_at instead of removing them
+func softDeleteEnabled() bool {
+	return GetBoolSetting("soft_delete", false)
+}
+
+// DeleteMessageByID deletes a single message (soft-deleting it when soft_delete is on),
+// reporting whether it existed
+func DeleteMessageByID(id string) (bool, error) {
+	var result sql.Result
+	var err error
+	if softDeleteEnabled() {
+		result, err = DB.Exec("UPDATE messages SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now().UTC(), id)
+	} else {
+		result, err = DB.Exec("DELETE FROM messages WHERE id = ?", id)
+		if err == nil {
+			_, err = DB.Exec("DELETE FROM message_status_history WHERE message_id = ?", id)
+		}
+		if err == nil {
+			_, err = DB.Exec("DELETE FROM message_recipients WHERE message_id = ?", id)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete message: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// ClearAllMessages truncates the messages table, or soft-deletes every message when soft_delete is on
 func ClearAllMessages() error {
-	_, err := DB.Exec("DELETE FROM messages")
+	var err error
+	if softDeleteEnabled() {
+		_, err = DB.Exec("UPDATE messages SET deleted_at = ? WHERE deleted_at IS NULL", time.Now().UTC())
+	} else {
+		_, err = DB.Exec("DELETE FROM messages")
+		if err == nil {
+			_, err = DB.Exec("DELETE FROM message_status_history")
+		}
+		if err == nil {
+			_, err = DB.Exec("DELETE FROM message_recipients")
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to clear messages: %w", err)
 	}
 	return nil
 }
 
+// DefaultAPIKey is the API key configured on a fresh database
+const DefaultAPIKey = "test-token"
+
 // Credential represents stored API credentials
 type Credential struct {
 	APIKey    string    `json:"api_key"`
@@ -221,7 +896,7 @@ func GetCredential() (*Credential, error) {
 		if err == sql.ErrNoRows {
 			// Return default if no credentials exist
 			return &Credential{
-				APIKey:    "test-token",
+				APIKey:    DefaultAPIKey,
 				UpdatedAt: time.Now().UTC(),
 			}, nil
 		}
@@ -230,14 +905,23 @@ func GetCredential() (*Credential, error) {
 	return &cred, nil
 }
 
-// SetCredential updates the stored API key
+// SetCredential updates the stored API key. With old_api_key_grace_seconds set, the key it replaces
+// keeps working until the grace period elapses.
 func SetCredential(apiKey string) error {
+	now := time.Now().UTC()
+	var previousKey, previousExpiresAt interface{}
+	if grace := GetIntSetting("old_api_key_grace_seconds", 0); grace > 0 {
+		if current, err := GetCredential(); err == nil && current.APIKey != apiKey {
+			previousKey, previousExpiresAt = current.APIKey, now.Add(time.Duration(grace)*time.Second)
+		}
+	}
+
 	// Use INSERT OR REPLACE to handle both insert and update (SQLite-specific syntax)
 	query := `
-		INSERT OR REPLACE INTO credentials (id, api_key, updated_at)
-		VALUES (1, ?, ?)
+		INSERT OR REPLACE INTO credentials (id, api_key, updated_at, previous_api_key, previous_expires_at)
+		VALUES (1, ?, ?, ?, ?)
 	`
-	_, err := DB.Exec(query, apiKey, time.Now().UTC())
+	_, err := DB.Exec(query, apiKey, now, previousKey, previousExpiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to set credential: %w", err)
 	}
@@ -265,7 +949,22 @@ func ValidateCredential(authHeader string) bool {
 	}
 	
 	// Compare token with stored API key
-	return token == cred.APIKey
+	if token == cred.APIKey {
+		return true
+	}
+	return token != "" && validPreviousKey(token)
+}
+
+// validPreviousKey reports whether token is the key replaced by the last rotation and its grace
+// period hasn't elapsed
+func validPreviousKey(token string) bool {
+	var previousKey sql.NullString
+	var expiresAt sql.NullTime
+	err := DB.QueryRow("SELECT previous_api_key, previous_expires_at FROM credentials WHERE id = 1").Scan(&previousKey, &expiresAt)
+	if err != nil || !previousKey.Valid || !expiresAt.Valid {
+		return false
+	}
+	return token == previousKey.String && time.Now().Before(expiresAt.Time)
 }
 
 // GetExpectedToken returns the stored API key for debugging purposes
@@ -277,6 +976,14 @@ func GetExpectedToken() string {
 	return cred.APIKey
 }
 
+// PoolStats returns the connection pool statistics, or zero values if the DB isn't open
+func PoolStats() sql.DBStats {
+	if DB == nil {
+		return sql.DBStats{}
+	}
+	return DB.Stats()
+}
+
 // CloseDB closes the database connection
 func CloseDB() error {
 	if DB != nil {
@@ -306,11 +1013,30 @@ func InsertLog(level, category, message string, details map[string]interface{})
 		VALUES (?, ?, ?, ?, ?)
 	`
 
-	_, err := DB.Exec(query, time.Now().UTC(), level, category, message, detailsJSON)
+	createdAt := time.Now().UTC()
+	result, err := DB.Exec(query, createdAt, level, category, message, detailsJSON)
 	if err != nil {
 		return fmt.Errorf("failed to insert log: %w", err)
 	}
 
+	id, _ := result.LastInsertId()
+	LogEvents.Publish(LogEntry{
+		ID:        id,
+		CreatedAt: createdAt,
+		Level:     level,
+		Category:  category,
+		Message:   message,
+		Details:   detailsJSON,
+	})
+
+	// Keep the table within max_logs as entries arrive; ids only grow, so everything at or below
+	// id-max is older than the newest max entries
+	if max := GetIntSetting("max_logs", 0); max > 0 && id > int64(max) {
+		if _, err := DB.Exec("DELETE FROM logs WHERE id <= ?", id-int64(max)); err != nil {
+			return fmt.Errorf("failed to trim logs: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -331,20 +1057,139 @@ func LogWarning(category, message string, details map[string]interface{}) {
 
 // GetLogs retrieves log entries, optionally filtered by level and category
 func GetLogs(level, category string, limit int) ([]LogEntry, error) {
+	return FilterLogs(LogFilter{Level: level, Category: category}, limit)
+}
+
+// LogFilter narrows a log listing; empty fields are ignored
+type LogFilter struct {
+	Level     string
+	Category  string
+	MessageID string    // Only entries whose details reference this message_id
+	Since     time.Time // Only entries created at or after this time
+	Until     time.Time // Only entries created at or before this time
+}
+
+// logMessageIDExpr extracts details.message_id, tolerating entries without JSON details. Queries must
+// compare this exact expression directly (not inside an OR) for SQLite to use idx_logs_message_id
+// instead of parsing every row's JSON.
+const logMessageIDExpr = `json_extract(CASE WHEN json_valid(details) THEN details END, '$.message_id')`
+
+// messageIDCondition returns the extra WHERE clause and args restricting logs to messageID, if set
+func messageIDCondition(messageID string) (string, []interface{}) {
+	if messageID == "" {
+		return "", nil
+	}
+	return " AND " + logMessageIDExpr + " = ?", []interface{}{messageID}
+}
+
+// FilterLogs retrieves the most recent log entries matching the filter, newest first
+func FilterLogs(filter LogFilter, limit int) ([]LogEntry, error) {
+	return FilterLogsPage(filter, 0, limit)
+}
+
+// FilterLogsPage retrieves up to limit log entries matching the filter, newest first, after
+// skipping the first offset of them
+func FilterLogsPage(filter LogFilter, offset, limit int) ([]LogEntry, error) {
 	if limit <= 0 {
 		limit = 100
 	}
 
+	where, args := logFilterCondition(filter)
+	// id breaks created_at ties so consecutive pages neither repeat nor skip entries
 	query := `
 		SELECT id, created_at, level, category, message, details
 		FROM logs
-		WHERE (? = '' OR level = ?)
-		  AND (? = '' OR category = ?)
-		ORDER BY created_at DESC
+		WHERE ` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	return queryLogs(query, append(args, limit, offset)...)
+}
+
+// CountLogs returns how many log entries match the filter
+func CountLogs(filter LogFilter) (int, error) {
+	where, args := logFilterCondition(filter)
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM logs WHERE "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count logs: %w", err)
+	}
+	return count, nil
+}
+
+// logFilterCondition returns the WHERE clause and args selecting the logs that match the filter
+func logFilterCondition(filter LogFilter) (string, []interface{}) {
+	messageIDClause, messageIDArgs := messageIDCondition(filter.MessageID)
+	where := `(? = '' OR level = ?)
+		  AND (? = '' OR category = ?)` + messageIDClause
+	args := append([]interface{}{filter.Level, filter.Level, filter.Category, filter.Category}, messageIDArgs...)
+	if !filter.Since.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where += " AND created_at <= ?"
+		args = append(args, filter.Until.UTC())
+	}
+	return where, args
+}
+
+// GetLogsAfter retrieves log entries with an id greater than afterID, oldest first,
+// optionally narrowed by the filter
+func GetLogsAfter(afterID int64, filter LogFilter, limit int) ([]LogEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	where, args := logFilterCondition(filter)
+	query := `
+		SELECT id, created_at, level, category, message, details
+		FROM logs
+		WHERE id > ? AND ` + where + `
+		ORDER BY id
 		LIMIT ?
 	`
 
-	rows, err := DB.Query(query, level, level, category, category, limit)
+	args = append([]interface{}{afterID}, args...)
+	return queryLogs(query, append(args, limit)...)
+}
+
+// EachLog calls fn with every log entry matching the filter, oldest first, one row at a time so
+// large exports aren't held in memory. It stops at the first error fn returns.
+func EachLog(filter LogFilter, fn func(LogEntry) error) error {
+	where, args := logFilterCondition(filter)
+	rows, err := DB.Query(`
+		SELECT id, created_at, level, category, message, details
+		FROM logs
+		WHERE `+where+`
+		ORDER BY created_at, id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log LogEntry
+		var details sql.NullString
+		if err := rows.Scan(&log.ID, &log.CreatedAt, &log.Level, &log.Category, &log.Message, &details); err != nil {
+			return fmt.Errorf("failed to scan log: %w", err)
+		}
+		log.Details = details.String
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating log rows: %w", err)
+	}
+	return nil
+}
+
+// queryLogs runs a query selecting log columns and scans every row
+func queryLogs(query string, args ...interface{}) ([]LogEntry, error) {
+	rows, err := DB.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query logs: %w", err)
 	}
@@ -371,7 +1216,8 @@ func GetLogs(level, category string, limit int) ([]LogEntry, error) {
 	return logs, nil
 }
 
-// CleanupOldLogs removes log entries older than the specified number of days
+// CleanupOldLogs removes log entries, and the webhook delivery attempts recorded alongside them,
+// older than the specified number of days
 func CleanupOldLogs(days int) error {
 	cutoff := time.Now().UTC().AddDate(0, 0, -days)
 	
@@ -379,6 +1225,9 @@ func CleanupOldLogs(days int) error {
 	if err != nil {
 		return fmt.Errorf("failed to cleanup old logs: %w", err)
 	}
+	if _, err := DB.Exec("DELETE FROM webhook_deliveries WHERE created_at < ?", cutoff); err != nil {
+		return fmt.Errorf("failed to cleanup old webhook deliveries: %w", err)
+	}
 
 	affected, _ := result.RowsAffected()
 	if affected > 0 {
@@ -388,15 +1237,50 @@ func CleanupOldLogs(days int) error {
 	return nil
 }
 
-// ClearAllLogs removes all log entries
+// TrimLogs deletes all but the newest max log entries, returning how many were removed, and
+// likewise trims the recorded webhook delivery attempts. A max of 0 or less means unlimited.
+func TrimLogs(max int) (int64, error) {
+	if max <= 0 {
+		return 0, nil
+	}
+
+	result, err := DB.Exec("DELETE FROM logs WHERE id NOT IN (SELECT id FROM logs ORDER BY id DESC LIMIT ?)", max)
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim logs: %w", err)
+	}
+	if _, err := DB.Exec("DELETE FROM webhook_deliveries WHERE id NOT IN (SELECT id FROM webhook_deliveries ORDER BY id DESC LIMIT ?)", max); err != nil {
+		return 0, fmt.Errorf("failed to trim webhook deliveries: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected, nil
+}
+
+// ClearAllLogs removes all log entries and recorded webhook delivery attempts
 func ClearAllLogs() error {
 	_, err := DB.Exec("DELETE FROM logs")
 	if err != nil {
 		return fmt.Errorf("failed to clear logs: %w", err)
 	}
+	if _, err := DB.Exec("DELETE FROM webhook_deliveries"); err != nil {
+		return fmt.Errorf("failed to clear webhook deliveries: %w", err)
+	}
 	return nil
 }
 
+// ClearLogsBefore removes log entries created before the given time, returning how many were
+// deleted, along with the webhook delivery attempts recorded before it
+func ClearLogsBefore(t time.Time) (int64, error) {
+	result, err := DB.Exec("DELETE FROM logs WHERE created_at < ?", t.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear logs: %w", err)
+	}
+	if _, err := DB.Exec("DELETE FROM webhook_deliveries WHERE created_at < ?", t.UTC()); err != nil {
+		return 0, fmt.Errorf("failed to clear webhook deliveries: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected, nil
+}
+
 // GetSetting retrieves a setting value by key
 func GetSetting(key string) (string, error) {
 	var value string
@@ -425,6 +1309,25 @@ func SetSetting(key, value string) error {
 	return nil
 }
 
+// SetSettingAudited stores a setting value and logs the change with the previous value, leaving an
+// audit trail of configuration changes
+func SetSettingAudited(key, value string) error {
+	previous, err := GetSetting(key)
+	if err != nil {
+		return err
+	}
+	if err := SetSetting(key, value); err != nil {
+		return err
+	}
+
+	Log("system", "Setting changed", map[string]interface{}{
+		"key":       key,
+		"old_value": previous,
+		"new_value": value,
+	})
+	return nil
+}
+
 // IsDebugMode returns whether debug mode is enabled
 func IsDebugMode() bool {
 	value, err := GetSetting("debug_mode")
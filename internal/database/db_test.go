@@ -1,8 +1,11 @@
 package database
 
 import (
+	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func setupTestDB(t *testing.T) func() {
@@ -220,6 +223,40 @@ func TestValidateCredential_Invalid(t *testing.T) {
 	}
 }
 
+func TestValidateCredential_RotationGrace(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Without a grace period the old key stops working immediately
+	SetCredential("first-key")
+	if ValidateCredential("Bearer test-token") {
+		t.Error("Should not validate the replaced key without a grace period")
+	}
+
+	SetSetting("old_api_key_grace_seconds", "60")
+	SetCredential("second-key")
+	if !ValidateCredential("Bearer first-key") {
+		t.Error("Should validate the replaced key within the grace period")
+	}
+	if !ValidateCredential("Bearer second-key") {
+		t.Error("Should validate the new key")
+	}
+	if ValidateCredential("Bearer test-token") {
+		t.Error("Should only honor the most recently replaced key")
+	}
+
+	// Once the grace period has passed, only the new key works
+	if _, err := DB.Exec("UPDATE credentials SET previous_expires_at = ?", time.Now().UTC().Add(-time.Second)); err != nil {
+		t.Fatalf("Failed to expire grace period: %v", err)
+	}
+	if ValidateCredential("Bearer first-key") {
+		t.Error("Should not validate the replaced key after the grace period")
+	}
+	if !ValidateCredential("Bearer second-key") {
+		t.Error("Should still validate the new key")
+	}
+}
+
 func TestMessagesOrderedByCreatedAtDesc(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
@@ -246,3 +283,214 @@ func TestMessagesOrderedByCreatedAtDesc(t *testing.T) {
 		t.Errorf("Expected last message to be 'id-first', got '%s'", messages[2].ID)
 	}
 }
+
+func TestGetMessagesByTimeRange(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	InsertMessageWithOptions("id-before", "+111", "+222", "before", []string{}, "profile-1", "outbound", MessageOptions{CreatedAt: base.Add(-time.Hour)})
+	InsertMessageWithOptions("id-inside", "+111", "+222", "inside", []string{}, "profile-1", "outbound", MessageOptions{CreatedAt: base})
+	InsertMessageWithOptions("id-after", "+111", "+222", "after", []string{}, "profile-1", "outbound", MessageOptions{CreatedAt: base.Add(time.Hour)})
+
+	messages, err := GetMessagesByTimeRange(base.Add(-time.Minute), base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != "id-inside" {
+		t.Errorf("Expected only 'id-inside', got %+v", messages)
+	}
+
+	// Open-ended bounds
+	messages, _ = GetMessagesByTimeRange(base, time.Time{})
+	if len(messages) != 2 {
+		t.Errorf("Expected 2 messages since base, got %d", len(messages))
+	}
+	messages, _ = GetMessagesByTimeRange(time.Time{}, time.Time{})
+	if len(messages) != 3 {
+		t.Errorf("Expected 3 messages with no bounds, got %d", len(messages))
+	}
+}
+
+func TestClearLogsBefore(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	cutoff := time.Now().UTC().Add(-time.Hour)
+	DB.Exec("INSERT INTO logs (created_at, level, category, message, details) VALUES (?, 'info', 'system', 'old entry', '')", cutoff.Add(-time.Minute))
+	Log("system", "recent entry", nil)
+
+	deleted, err := ClearLogsBefore(cutoff)
+	if err != nil {
+		t.Fatalf("Failed to clear logs: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 deleted log, got %d", deleted)
+	}
+
+	logs, _ := GetLogs("", "", 10)
+	if len(logs) != 1 || logs[0].Message != "recent entry" {
+		t.Errorf("Expected only the recent entry to remain, got %+v", logs)
+	}
+}
+
+func TestWebhookSigningKeyRotation(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	first, err := GetWebhookSigningKey()
+	if err != nil {
+		t.Fatalf("Failed to get signing key: %v", err)
+	}
+	again, _ := GetWebhookSigningKey()
+	if !first.Equal(again) {
+		t.Error("Expected the stored signing key to be reused")
+	}
+	if previous, _ := GetPreviousWebhookSigningKey(); previous != nil {
+		t.Error("Expected no previous key before rotation")
+	}
+
+	rotated, err := RotateWebhookSigningKey()
+	if err != nil {
+		t.Fatalf("Failed to rotate signing key: %v", err)
+	}
+	if rotated.Equal(first) {
+		t.Error("Expected rotation to generate a new key")
+	}
+	previous, _ := GetPreviousWebhookSigningKey()
+	if !first.Equal(previous) {
+		t.Error("Expected the old key to become the previous key")
+	}
+}
+
+func TestRotateWebhookSigningKey_NoDB(t *testing.T) {
+	saved := DB
+	DB = nil
+	defer func() { DB = saved }()
+
+	rotated, err := RotateWebhookSigningKey()
+	if err != nil {
+		t.Fatalf("Failed to rotate without a database: %v", err)
+	}
+	if current, _ := GetWebhookSigningKey(); !current.Equal(rotated) {
+		t.Error("Expected the rotated key to become the in-memory signing key")
+	}
+}
+
+func TestSoftDeleteVisibility(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	InsertMessage("msg-1", "+111", "+222", "first", []string{}, "profile-1", "outbound")
+	InsertMessage("msg-2", "+111", "+222", "second", []string{}, "profile-1", "outbound")
+	InsertMessage("msg-3", "+111", "+222", "third", []string{}, "profile-1", "outbound")
+
+	SetSetting("soft_delete", "true")
+	deleted, err := DeleteMessageByID("msg-1")
+	if err != nil || !deleted {
+		t.Fatalf("Expected msg-1 to be soft-deleted, got %v, %v", deleted, err)
+	}
+
+	if messages, _ := GetAllMessages(); len(messages) != 2 {
+		t.Errorf("Expected 2 visible messages, got %d", len(messages))
+	}
+	all, _ := GetMessages(MessageFilter{IncludeDeleted: true})
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 messages including deleted, got %d", len(all))
+	}
+	for _, msg := range all {
+		if (msg.ID == "msg-1") != (msg.DeletedAt != nil) {
+			t.Errorf("Unexpected deleted_at for %s: %v", msg.ID, msg.DeletedAt)
+		}
+	}
+
+	ClearAllMessages()
+	if messages, _ := GetAllMessages(); len(messages) != 0 {
+		t.Errorf("Expected no visible messages after soft clear, got %d", len(messages))
+	}
+	if all, _ := GetMessages(MessageFilter{IncludeDeleted: true}); len(all) != 3 {
+		t.Errorf("Expected soft clear to keep 3 rows, got %d", len(all))
+	}
+
+	SetSetting("soft_delete", "false")
+	DeleteMessageByID("msg-2")
+	if all, _ := GetMessages(MessageFilter{IncludeDeleted: true}); len(all) != 2 {
+		t.Errorf("Expected hard delete to remove the row, got %d rows", len(all))
+	}
+}
+
+func TestInitDB_UpgradesOldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old_schema.db")
+
+	// The original messages table, before messaging_profile_id and the lifecycle columns
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	_, err = old.Exec(`
+		CREATE TABLE messages (
+			id TEXT PRIMARY KEY,
+			created_at DATETIME NOT NULL,
+			sender TEXT NOT NULL,
+			recipient TEXT NOT NULL,
+			content TEXT,
+			media_urls TEXT,
+			direction TEXT NOT NULL
+		);
+		INSERT INTO messages (id, created_at, sender, recipient, content, media_urls, direction)
+		VALUES ('old-msg', '2024-01-01 12:00:00+00:00', '+111', '+222', 'hello', '[]', 'outbound');
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+
+	if err := InitDB(path); err != nil {
+		t.Fatalf("Failed to upgrade old database: %v", err)
+	}
+	defer CloseDB()
+
+	messages, err := GetAllMessages()
+	if err != nil {
+		t.Fatalf("Failed to read upgraded messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != "old-msg" || messages[0].Status != "" {
+		t.Errorf("Expected the old message with empty new fields, got %+v", messages)
+	}
+
+	// Existing messages are numbered, and new ones continue after them
+	if messages[0].Seq != 1 {
+		t.Errorf("Expected the old message to be backfilled with seq 1, got %d", messages[0].Seq)
+	}
+	InsertMessage("new-msg", "+111", "+222", "hi", nil, "", "outbound")
+	if msg, _ := GetMessageByID("new-msg"); msg == nil || msg.Seq != 2 {
+		t.Errorf("Expected the new message to get seq 2, got %+v", msg)
+	}
+
+	// Running the migration again is a no-op
+	if err := ensureColumn("messages", "status", "TEXT"); err != nil {
+		t.Errorf("Expected ensureColumn to be idempotent, got %v", err)
+	}
+}
+
+func TestInsertMessage_SeqNeverReused(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	InsertMessage("first", "+111", "+222", "one", nil, "", "outbound")
+	InsertMessage("second", "+111", "+222", "two", nil, "", "outbound")
+	second, _ := GetMessageByID("second")
+
+	// Deleting the newest message must not hand its seq to the next one
+	DB.Exec("DELETE FROM messages WHERE id = 'second'")
+	InsertMessage("third", "+111", "+222", "three", nil, "", "outbound")
+	third, _ := GetMessageByID("third")
+	if third.Seq <= second.Seq {
+		t.Errorf("Expected seq to keep increasing after a delete, got %d then %d", second.Seq, third.Seq)
+	}
+
+	messages, _ := GetMessages(MessageFilter{AfterSeq: second.Seq})
+	if len(messages) != 1 || messages[0].ID != "third" {
+		t.Errorf("Expected only 'third' after seq %d, got %+v", second.Seq, messages)
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// WebhookDelivery is one attempt to deliver a webhook to a URL
+type WebhookDelivery struct {
+	URL        string
+	EventType  string
+	MessageID  string
+	Attempt    int
+	StatusCode int
+	Success    bool
+	Error      string
+	CreatedAt  time.Time
+}
+
+// DeliveryGroup counts the delivery attempts sharing an event type or URL
+type DeliveryGroup struct {
+	Key       string
+	Total     int
+	Succeeded int
+}
+
+// SuccessRate is the fraction of the group's attempts that succeeded, or 0 with no attempts
+func (g DeliveryGroup) SuccessRate() float64 {
+	if g.Total == 0 {
+		return 0
+	}
+	return float64(g.Succeeded) / float64(g.Total)
+}
+
+// DeliveryStats breaks the recorded delivery attempts down by event type and by URL
+type DeliveryStats struct {
+	Total       DeliveryGroup
+	ByEventType []DeliveryGroup
+	ByURL       []DeliveryGroup
+}
+
+// RecordWebhookDelivery stores a delivery attempt, keeping at most max_logs of them; CreatedAt
+// defaults to now
+func RecordWebhookDelivery(d WebhookDelivery) error {
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return nil
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO webhook_deliveries (url, event_type, message_id, attempt, status_code, success, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.URL, d.EventType, d.MessageID, d.Attempt, d.StatusCode, d.Success, d.Error, d.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+
+	// max_logs bounds the recorded attempts as it does the logs
+	id, _ := result.LastInsertId()
+	if max := GetIntSetting("max_logs", 0); max > 0 && id > int64(max) {
+		if _, err := DB.Exec("DELETE FROM webhook_deliveries WHERE id <= ?", id-int64(max)); err != nil {
+			return fmt.Errorf("failed to trim webhook deliveries: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetDeliveryStats counts the delivery attempts made between since and until (either may be zero
+// for no bound), in total and grouped by event type and by URL, each sorted by key
+func GetDeliveryStats(since, until time.Time) (DeliveryStats, error) {
+	where := "1 = 1"
+	var args []interface{}
+	if !since.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, since.UTC())
+	}
+	if !until.IsZero() {
+		where += " AND created_at <= ?"
+		args = append(args, until.UTC())
+	}
+
+	var stats DeliveryStats
+	var err error
+	if stats.ByEventType, err = deliveryGroups("event_type", where, args); err != nil {
+		return DeliveryStats{}, err
+	}
+	if stats.ByURL, err = deliveryGroups("url", where, args); err != nil {
+		return DeliveryStats{}, err
+	}
+	for _, group := range stats.ByEventType {
+		stats.Total.Total += group.Total
+		stats.Total.Succeeded += group.Succeeded
+	}
+	return stats, nil
+}
+
+// deliveryGroups counts the delivery attempts matching where, grouped by column
+func deliveryGroups(column, where string, args []interface{}) ([]DeliveryGroup, error) {
+	rows, err := DB.Query(`
+		SELECT `+column+`, COUNT(*), SUM(success)
+		FROM webhook_deliveries
+		WHERE `+where+`
+		GROUP BY `+column+`
+		ORDER BY `+column, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []DeliveryGroup{}
+	for rows.Next() {
+		var group DeliveryGroup
+		if err := rows.Scan(&group.Key, &group.Total, &group.Succeeded); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// IdempotencyTTL is how long an Idempotency-Key is remembered after first use
+const IdempotencyTTL = 24 * time.Hour
+
+// IdempotencyKey records the message created for an Idempotency-Key and the response to replay
+type IdempotencyKey struct {
+	Key       string    `json:"key"`
+	MessageID string    `json:"message_id"`
+	Response  string    `json:"-"`
+	Status    int       `json:"-"` // HTTP status the original response was sent with
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SaveIdempotencyKey stores the response for a key, and the status it was sent with, replacing any
+// expired entry
+func SaveIdempotencyKey(key, messageID string, response []byte, status int) error {
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO idempotency_keys (key, message_id, response, status, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET message_id = excluded.message_id, response = excluded.response,
+			status = excluded.status, created_at = excluded.created_at, expires_at = excluded.expires_at
+	`
+	_, err := DB.Exec(query, key, messageID, string(response), status, now, now.Add(IdempotencyTTL))
+	if err != nil {
+		return fmt.Errorf("failed to save idempotency key: %w", err)
+	}
+	return nil
+}
+
+// GetIdempotencyKey returns the active entry for a key, or nil if it is unknown or expired
+func GetIdempotencyKey(key string) (*IdempotencyKey, error) {
+	var k IdempotencyKey
+	err := DB.QueryRow(
+		"SELECT key, message_id, response, status, created_at, expires_at FROM idempotency_keys WHERE key = ? AND expires_at > ?",
+		key, time.Now().UTC(),
+	).Scan(&k.Key, &k.MessageID, &k.Response, &k.Status, &k.CreatedAt, &k.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return &k, nil
+}
+
+// GetIdempotencyKeys retrieves all unexpired keys, newest first
+func GetIdempotencyKeys() ([]IdempotencyKey, error) {
+	rows, err := DB.Query(
+		"SELECT key, message_id, created_at, expires_at FROM idempotency_keys WHERE expires_at > ? ORDER BY created_at DESC",
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query idempotency keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []IdempotencyKey{}
+	for rows.Next() {
+		var k IdempotencyKey
+		if err := rows.Scan(&k.Key, &k.MessageID, &k.CreatedAt, &k.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan idempotency key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating idempotency key rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+// ClearIdempotencyKeys removes all stored keys, returning how many were deleted
+func ClearIdempotencyKeys() (int64, error) {
+	result, err := DB.Exec("DELETE FROM idempotency_keys")
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear idempotency keys: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected, nil
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"fmt"
+	"os"
+)
+
+// fileSize returns the size of the database file in bytes, or 0 if it can't be read
+func fileSize() int64 {
+	info, err := os.Stat(dbFilePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Vacuum rebuilds the database file to reclaim space left by deleted rows,
+// returning the file size in bytes before and after
+func Vacuum() (before, after int64, err error) {
+	before = fileSize()
+	if _, err := DB.Exec("VACUUM"); err != nil {
+		return before, before, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return before, fileSize(), nil
+}
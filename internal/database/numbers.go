@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PoolNumber is a phone number in a messaging profile's number pool
+type PoolNumber struct {
+	PhoneNumber        string     `json:"phone_number"`
+	MessagingProfileID string     `json:"messaging_profile_id"`
+	DisplayName        string     `json:"display_name,omitempty"` // Sender name returned in the from object
+	CampaignRegistered bool       `json:"campaign_registered"`    // Registered to a 10DLC campaign
+	UseCount           int        `json:"use_count"`
+	LastUsedAt         *time.Time `json:"last_used_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// AddPoolNumber assigns a number to a profile's pool, moving it if it belonged to another profile.
+// displayName may be empty for numbers without a sender name.
+func AddPoolNumber(profileID, phoneNumber, displayName string, campaignRegistered bool) error {
+	query := `
+		INSERT INTO profile_numbers (phone_number, messaging_profile_id, display_name, campaign_registered, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(phone_number) DO UPDATE SET messaging_profile_id = excluded.messaging_profile_id, display_name = excluded.display_name, campaign_registered = excluded.campaign_registered
+	`
+	_, err := DB.Exec(query, phoneNumber, profileID, displayName, campaignRegistered, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add pool number: %w", err)
+	}
+	return nil
+}
+
+// RemovePoolNumber removes a number from a profile's pool, reporting whether it was present
+func RemovePoolNumber(profileID, phoneNumber string) (bool, error) {
+	result, err := DB.Exec("DELETE FROM profile_numbers WHERE messaging_profile_id = ? AND phone_number = ?", profileID, phoneNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove pool number: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// GetPoolNumbers retrieves a profile's pool numbers in the order they were added
+func GetPoolNumbers(profileID string) ([]PoolNumber, error) {
+	rows, err := DB.Query(`
+		SELECT phone_number, messaging_profile_id, COALESCE(display_name, ''), campaign_registered, use_count, last_used_at, created_at
+		FROM profile_numbers
+		WHERE messaging_profile_id = ?
+		ORDER BY created_at, phone_number
+	`, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pool numbers: %w", err)
+	}
+	defer rows.Close()
+
+	numbers := []PoolNumber{}
+	for rows.Next() {
+		var n PoolNumber
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&n.PhoneNumber, &n.MessagingProfileID, &n.DisplayName, &n.CampaignRegistered, &n.UseCount, &lastUsed, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pool number: %w", err)
+		}
+		if lastUsed.Valid {
+			n.LastUsedAt = &lastUsed.Time
+		}
+		numbers = append(numbers, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pool number rows: %w", err)
+	}
+
+	return numbers, nil
+}
+
+// NextPoolNumber picks the profile's least recently used number (round-robin) and records the use.
+// It returns "" if the profile has no pool numbers.
+func NextPoolNumber(profileID string) (string, error) {
+	// A single UPDATE ... RETURNING keeps selection and bookkeeping atomic across concurrent sends
+	query := `
+		UPDATE profile_numbers
+		SET use_count = use_count + 1, last_used_at = ?
+		WHERE phone_number = (
+			SELECT phone_number FROM profile_numbers
+			WHERE messaging_profile_id = ?
+			ORDER BY last_used_at, created_at, phone_number
+			LIMIT 1
+		)
+		RETURNING phone_number
+	`
+	var phoneNumber string
+	err := DB.QueryRow(query, time.Now().UTC(), profileID).Scan(&phoneNumber)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to select pool number: %w", err)
+	}
+	return phoneNumber, nil
+}
+
+// GetPoolDisplayName returns the display name mapped to a number in the profile's pool, or ""
+// if the number isn't in the pool or has no display name
+func GetPoolDisplayName(profileID, phoneNumber string) (string, error) {
+	var displayName sql.NullString
+	err := DB.QueryRow("SELECT display_name FROM profile_numbers WHERE messaging_profile_id = ? AND phone_number = ?", profileID, phoneNumber).Scan(&displayName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get pool display name: %w", err)
+	}
+	return displayName.String, nil
+}
+
+// IsCampaignRegistered reports whether a number is in a number pool and registered to a 10DLC
+// campaign
+func IsCampaignRegistered(phoneNumber string) (bool, error) {
+	var registered bool
+	err := DB.QueryRow("SELECT campaign_registered FROM profile_numbers WHERE phone_number = ?", phoneNumber).Scan(&registered)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get campaign registration: %w", err)
+	}
+	return registered, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ResponseOverrides holds per-profile defaults applied when building message responses
+type ResponseOverrides struct {
+	Type        string                 `json:"type,omitempty"`         // Default type for messages without media ("SMS" or "MMS")
+	Encoding    string                 `json:"encoding,omitempty"`     // Default encoding ("GSM-7" or "UCS-2")
+	ExtraFields map[string]interface{} `json:"extra_fields,omitempty"` // Merged into the create response over response_extra_fields
+}
+
+// MessagingProfile represents a simulated Telnyx messaging profile
+type MessagingProfile struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	ResponseOverrides ResponseOverrides `json:"response_overrides"`
+	DailySpendLimit   float64           `json:"daily_spend_limit"` // USD per UTC day; 0 means unlimited
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// UpsertProfile creates a messaging profile or updates an existing one
+func UpsertProfile(profile MessagingProfile) error {
+	overridesJSON, err := json.Marshal(profile.ResponseOverrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response overrides: %w", err)
+	}
+
+	query := `
+		INSERT INTO messaging_profiles (id, name, response_overrides, daily_spend_limit, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, response_overrides = excluded.response_overrides,
+			daily_spend_limit = excluded.daily_spend_limit, updated_at = excluded.updated_at
+	`
+	now := time.Now().UTC()
+	_, err = DB.Exec(query, profile.ID, profile.Name, string(overridesJSON), profile.DailySpendLimit, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save messaging profile: %w", err)
+	}
+	return nil
+}
+
+// GetProfile retrieves a messaging profile by ID, or nil if it doesn't exist
+func GetProfile(id string) (*MessagingProfile, error) {
+	row := DB.QueryRow("SELECT id, name, response_overrides, daily_spend_limit, created_at, updated_at FROM messaging_profiles WHERE id = ?", id)
+	profile, err := scanProfile(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get messaging profile: %w", err)
+	}
+	return profile, nil
+}
+
+// GetProfiles retrieves all messaging profiles, ordered by creation time
+func GetProfiles() ([]MessagingProfile, error) {
+	rows, err := DB.Query("SELECT id, name, response_overrides, daily_spend_limit, created_at, updated_at FROM messaging_profiles ORDER BY created_at")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messaging profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := []MessagingProfile{}
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan messaging profile: %w", err)
+		}
+		profiles = append(profiles, *profile)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating messaging profile rows: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// DeleteProfile removes a messaging profile, reporting whether it existed
+func DeleteProfile(id string) (bool, error) {
+	result, err := DB.Exec("DELETE FROM messaging_profiles WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete messaging profile: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// scanProfile scans a messaging profile row, decoding its JSON columns
+func scanProfile(row interface{ Scan(...any) error }) (*MessagingProfile, error) {
+	var profile MessagingProfile
+	var name, overrides sql.NullString
+	var spendLimit sql.NullFloat64
+	if err := row.Scan(&profile.ID, &name, &overrides, &spendLimit, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+		return nil, err
+	}
+	profile.Name = name.String
+	profile.DailySpendLimit = spendLimit.Float64
+	if overrides.Valid && overrides.String != "" {
+		if err := json.Unmarshal([]byte(overrides.String), &profile.ResponseOverrides); err != nil {
+			return nil, fmt.Errorf("failed to decode response overrides: %w", err)
+		}
+	}
+	return &profile, nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MessageRecipient is one participant of a group message, with its own delivery status
+type MessageRecipient struct {
+	PhoneNumber string `json:"phone_number"`
+	Status      string `json:"status"`
+}
+
+// recordRecipients stores a group message's participants, in order, all starting at status, using
+// db, which may be the database or a transaction
+func recordRecipients(db interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, messageID string, recipients []string, status string, at time.Time) error {
+	for i, number := range recipients {
+		_, err := db.Exec("INSERT INTO message_recipients (message_id, position, phone_number, status, updated_at) VALUES (?, ?, ?, ?, ?)",
+			messageID, i, number, status, at.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to record message recipient: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateRecipientStatuses moves a group message's participants to the message's new status; those
+// that already failed stay failed
+func updateRecipientStatuses(db interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, messageID, status string, at time.Time) error {
+	_, err := db.Exec("UPDATE message_recipients SET status = ?, updated_at = ? WHERE message_id = ? AND status != 'failed'", status, at.UTC(), messageID)
+	if err != nil {
+		return fmt.Errorf("failed to update message recipients: %w", err)
+	}
+	return nil
+}
+
+// FailMessageRecipients marks individual participants of a group message as failed, leaving the
+// message and its other participants to carry on
+func FailMessageRecipients(messageID string, numbers []string) error {
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, number := range numbers {
+		_, err := DB.Exec("UPDATE message_recipients SET status = 'failed', updated_at = ? WHERE message_id = ? AND phone_number = ?", now, messageID, number)
+		if err != nil {
+			return fmt.Errorf("failed to fail message recipient: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetMessageRecipients returns a group message's participants in the order they were addressed,
+// or none for a message with a single recipient
+func GetMessageRecipients(messageID string) ([]MessageRecipient, error) {
+	rows, err := DB.Query("SELECT phone_number, status FROM message_recipients WHERE message_id = ? ORDER BY position", messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := []MessageRecipient{}
+	for rows.Next() {
+		var recipient MessageRecipient
+		if err := rows.Scan(&recipient.PhoneNumber, &recipient.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan message recipient: %w", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read message recipients: %w", err)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// resetTables lists every table cleared by ResetAll; add new feature tables here
+var resetTables = []string{
+	"messages",
+	"logs",
+	"messaging_profiles",
+	"profile_numbers",
+	"profile_spend",
+	"message_status_history",
+	"message_recipients",
+	"auto_replies",
+	"opt_outs",
+	"blocked_numbers",
+	"idempotency_keys",
+	"settings",
+	"settings_profiles",
+	"webhook_deliveries",
+}
+
+// ResetAll returns the mock to a clean state in a single transaction: all data and settings are
+// removed and the default credential is restored
+func ResetAll() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range resetTables {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	_, err = tx.Exec("INSERT OR REPLACE INTO credentials (id, api_key, updated_at) VALUES (1, ?, ?)", DefaultAPIKey, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to restore default credential: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reset: %w", err)
+	}
+	return nil
+}
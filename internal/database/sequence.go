@@ -0,0 +1,46 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// initMessageSeq creates the counter behind messages.seq and backfills messages stored before the
+// column existed. The counter is an AUTOINCREMENT table rather than MAX(seq)+1 so a value is never
+// handed out twice, even after the newest message is deleted.
+func initMessageSeq() error {
+	if _, err := DB.Exec(`CREATE TABLE IF NOT EXISTS message_seq (seq INTEGER PRIMARY KEY AUTOINCREMENT)`); err != nil {
+		return fmt.Errorf("failed to create message_seq table: %w", err)
+	}
+
+	// Number pre-existing messages in insertion order, then move the counter past them
+	if _, err := DB.Exec(`UPDATE messages SET seq = rowid WHERE seq IS NULL`); err != nil {
+		return fmt.Errorf("failed to backfill message seq: %w", err)
+	}
+	if _, err := DB.Exec(`INSERT OR IGNORE INTO message_seq (seq) SELECT MAX(seq) FROM messages HAVING MAX(seq) IS NOT NULL`); err != nil {
+		return fmt.Errorf("failed to advance message seq: %w", err)
+	}
+	if _, err := DB.Exec(`DELETE FROM message_seq`); err != nil {
+		return fmt.Errorf("failed to trim message seq: %w", err)
+	}
+	return nil
+}
+
+// nextMessageSeq allocates the next messages.seq value. The allocated row is deleted straight
+// away; AUTOINCREMENT remembers the high-water mark so the table stays empty.
+func nextMessageSeq(db interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}) (int64, error) {
+	result, err := db.Exec(`INSERT INTO message_seq DEFAULT VALUES`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate message seq: %w", err)
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate message seq: %w", err)
+	}
+	if _, err := db.Exec(`DELETE FROM message_seq WHERE seq = ?`, seq); err != nil {
+		return 0, fmt.Errorf("failed to allocate message seq: %w", err)
+	}
+	return seq, nil
+}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+)
+
+// DefaultMessageValidityHours is the validity window reported in valid_until when not configured
+const DefaultMessageValidityHours = 24
+
+// DefaultMMSMaxMedia is the maximum number of media URLs per MMS when not configured
+const DefaultMMSMaxMedia = 10
+
+// DefaultWebhookInitialDelayMs is the wait before the first status webhook when not configured
+const DefaultWebhookInitialDelayMs = 500
+
+// DefaultInboundReadDelayMs is the wait between message.received and message.read when read
+// receipts are on but no delay is configured
+const DefaultInboundReadDelayMs = 1000
+
+// GetIntSetting retrieves an integer setting, returning def when it is unset or invalid, or the DB
+// is not initialized
+func GetIntSetting(key string, def int) int {
+	if DB == nil {
+		return def
+	}
+	value, err := GetSetting(key)
+	if err != nil || value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return parsed
+}
+
+// GetFloatSetting retrieves a decimal setting, returning def when it is unset or invalid, or the DB
+// is not initialized
+func GetFloatSetting(key string, def float64) float64 {
+	if DB == nil {
+		return def
+	}
+	value, err := GetSetting(key)
+	if err != nil || value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return def
+	}
+	return parsed
+}
+
+// GetBoolSetting retrieves a boolean setting stored as "true"/"false", returning def when it is unset
+// or the DB is not initialized
+func GetBoolSetting(key string, def bool) bool {
+	if DB == nil {
+		return def
+	}
+	value, err := GetSetting(key)
+	if err != nil || value == "" {
+		return def
+	}
+	return value == "true"
+}
+
+// GetMessageValidityHours returns how long outbound messages remain valid (valid_until window)
+func GetMessageValidityHours() int {
+	return GetIntSetting("message_validity_hours", DefaultMessageValidityHours)
+}
+
+// GetCarrierRejectRules returns the recipient regex and text token that trigger a simulated carrier reject.
+// Either may be empty, meaning that trigger is disabled.
+func GetCarrierRejectRules() (recipientPattern, textToken string) {
+	recipientPattern, _ = GetSetting("carrier_reject_pattern")
+	textToken, _ = GetSetting("carrier_reject_token")
+	return recipientPattern, textToken
+}
+
+// DebugEnabled reports whether debug mode is on, via the SMSSINK_DEBUG env var (which takes
+// precedence) or the debug_mode setting
+func DebugEnabled() bool {
+	if os.Getenv("SMSSINK_DEBUG") == "true" {
+		return true
+	}
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return false
+	}
+	return IsDebugMode()
+}
+
+// GetWebhookCustomHeaders returns the headers added to every outbound webhook (empty if unset or invalid)
+func GetWebhookCustomHeaders() map[string]string {
+	headers := map[string]string{}
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return headers
+	}
+	value, err := GetSetting("webhook_custom_headers")
+	if err != nil || value == "" {
+		return headers
+	}
+	if err := json.Unmarshal([]byte(value), &headers); err != nil {
+		return map[string]string{}
+	}
+	return headers
+}
+
+// GetWebhookFieldMap returns the renames applied to top-level webhook payload keys, old name to
+// new name (empty if unset or invalid)
+func GetWebhookFieldMap() map[string]string {
+	fieldMap := map[string]string{}
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return fieldMap
+	}
+	value, err := GetSetting("webhook_field_map")
+	if err != nil || value == "" {
+		return fieldMap
+	}
+	if err := json.Unmarshal([]byte(value), &fieldMap); err != nil {
+		return map[string]string{}
+	}
+	return fieldMap
+}
+
+// GetResponseExtraFields returns the fields merged into every create response (empty if unset or invalid)
+func GetResponseExtraFields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	value, err := GetSetting("response_extra_fields")
+	if err != nil || value == "" {
+		return fields
+	}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return map[string]interface{}{}
+	}
+	return fields
+}
+
+// LatencyConfig describes the artificial latency added to API responses
+type LatencyConfig struct {
+	Mode     string // "fixed" (always MeanMs) or "normal" (normally distributed around MeanMs)
+	MeanMs   int
+	StddevMs int // Only used in "normal" mode
+}
+
+// GetLatencyConfig returns the configured API latency; the default is fixed at 0ms (off)
+func GetLatencyConfig() LatencyConfig {
+	mode, _ := GetSetting("api_latency_mode")
+	if mode == "" {
+		mode = "fixed"
+	}
+	return LatencyConfig{
+		Mode:     mode,
+		MeanMs:   GetIntSetting("api_latency_ms", 0),
+		StddevMs: GetIntSetting("api_latency_stddev_ms", 0),
+	}
+}
+
+// GetMMSMaxMedia returns the maximum number of media URLs allowed on an MMS
+func GetMMSMaxMedia() int {
+	return GetIntSetting("mms_max_media", DefaultMMSMaxMedia)
+}
+
+// GetWebhookVersion returns the webhook payload format, "v2" (default) or legacy "v1"
+func GetWebhookVersion() string {
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return "v2"
+	}
+	value, err := GetSetting("webhook_version")
+	if err != nil || value == "" {
+		return "v2"
+	}
+	return value
+}
+
+// GetStringListSetting retrieves a setting stored as a JSON array of strings, returning an empty
+// list when it is unset, invalid, or the DB is not initialized
+func GetStringListSetting(key string) []string {
+	list := []string{}
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return list
+	}
+	value, err := GetSetting(key)
+	if err != nil || value == "" {
+		return list
+	}
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return []string{}
+	}
+	return list
+}
+
+// GetWebhookEvents returns the event types to deliver, or an empty list meaning all events
+func GetWebhookEvents() []string {
+	return GetStringListSetting("webhook_events")
+}
+
+// WebhookEventEnabled reports whether webhooks of the given event type should be delivered
+func WebhookEventEnabled(eventType string) bool {
+	events := GetWebhookEvents()
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNumberPoolStrategy returns how the sender is chosen from a profile's number pool:
+// "none" (default, use the request's from) or "round_robin"
+func GetNumberPoolStrategy() string {
+	value, err := GetSetting("number_pool_strategy")
+	if err != nil || value == "" {
+		return "none"
+	}
+	return value
+}
+
+// GetWebhookHTTPMethod returns the HTTP method used to deliver webhooks, "POST" (default) or "PUT"
+func GetWebhookHTTPMethod() string {
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return "POST"
+	}
+	value, err := GetSetting("webhook_http_method")
+	if err != nil || value == "" {
+		return "POST"
+	}
+	return value
+}
+
+// GetResponseRecordType returns the record_type reported in the create response, "message" by default
+func GetResponseRecordType() string {
+	value, err := GetSetting("response_record_type")
+	if err != nil || value == "" {
+		return "message"
+	}
+	return value
+}
+
+// GetWebhookDumpDir returns the directory webhook payloads are also written to, or "" when dumping is off
+func GetWebhookDumpDir() string {
+	// Gracefully handle case where DB is not initialized (e.g., in webhook tests)
+	if DB == nil {
+		return ""
+	}
+	value, _ := GetSetting("webhook_dump_dir")
+	return value
+}
+
+// GetDefaultInboundText returns the text simulated inbound messages without text or media get,
+// or "" when they're rejected instead
+func GetDefaultInboundText() string {
+	value, _ := GetSetting("default_inbound_text")
+	return value
+}
+
+// GetInboundWebhookURL returns the URL simulated inbound messages are reported to, or "" for none
+func GetInboundWebhookURL() string {
+	value, _ := GetSetting("inbound_webhook_url")
+	return value
+}
+
+// GetInboundDuplicateMode returns how an inbound webhook reusing a stored message ID is handled:
+// "error" (default, rejected with a 500), "ignore" (acknowledged but not stored) or "replace"
+// (the stored message is overwritten)
+func GetInboundDuplicateMode() string {
+	value, err := GetSetting("inbound_duplicate_mode")
+	if err != nil || value == "" {
+		return "error"
+	}
+	return value
+}
+
+// GetRetryAfterFormat returns how Retry-After headers are rendered: "seconds" (default) or "http-date"
+func GetRetryAfterFormat() string {
+	value, err := GetSetting("retry_after_format")
+	if err != nil || value == "" {
+		return "seconds"
+	}
+	return value
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SettingsProfile is a named snapshot of the settings, restored in one step to switch test scenarios
+type SettingsProfile struct {
+	Name      string            `json:"name"`
+	Settings  map[string]string `json:"settings"` // Stored setting values by key; unset settings are absent
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// unprofiledSettings are settings keys that snapshots leave alone: the webhook signing keys
+// belong to the instance, not to a test scenario
+var unprofiledSettings = map[string]bool{
+	webhookKeySetting:         true,
+	previousWebhookKeySetting: true,
+}
+
+// SaveSettingsProfile snapshots the current settings under name, replacing any earlier snapshot
+// with that name
+func SaveSettingsProfile(name string) error {
+	settings, err := profiledSettings(DB)
+	if err != nil {
+		return err
+	}
+
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	query := `
+		INSERT INTO settings_profiles (name, settings, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET settings = excluded.settings, updated_at = excluded.updated_at
+	`
+	now := time.Now().UTC()
+	if _, err := DB.Exec(query, name, string(settingsJSON), now, now); err != nil {
+		return fmt.Errorf("failed to save settings profile: %w", err)
+	}
+	return nil
+}
+
+// GetSettingsProfile retrieves a settings profile by name, or nil if it doesn't exist
+func GetSettingsProfile(name string) (*SettingsProfile, error) {
+	row := DB.QueryRow("SELECT name, settings, created_at, updated_at FROM settings_profiles WHERE name = ?", name)
+	profile, err := scanSettingsProfile(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get settings profile: %w", err)
+	}
+	return profile, nil
+}
+
+// GetSettingsProfiles retrieves every settings profile, ordered by name
+func GetSettingsProfiles() ([]SettingsProfile, error) {
+	rows, err := DB.Query("SELECT name, settings, created_at, updated_at FROM settings_profiles ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settings profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := []SettingsProfile{}
+	for rows.Next() {
+		profile, err := scanSettingsProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settings profile: %w", err)
+		}
+		profiles = append(profiles, *profile)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating settings profile rows: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// DeleteSettingsProfile removes a settings profile, reporting whether it existed
+func DeleteSettingsProfile(name string) (bool, error) {
+	result, err := DB.Exec("DELETE FROM settings_profiles WHERE name = ?", name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete settings profile: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// ApplySettingsProfile replaces the current settings with the profile's snapshot in a single
+// transaction, so settings set since the snapshot go back to their defaults
+func ApplySettingsProfile(profile SettingsProfile) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin applying settings profile: %w", err)
+	}
+	defer tx.Rollback()
+
+	previous, err := profiledSettings(tx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM settings WHERE key NOT IN (?, ?)", webhookKeySetting, previousWebhookKeySetting); err != nil {
+		return fmt.Errorf("failed to clear settings: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for key, value := range profile.Settings {
+		if unprofiledSettings[key] {
+			continue
+		}
+		if _, err := tx.Exec("INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)", key, value, now); err != nil {
+			return fmt.Errorf("failed to restore setting %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit settings profile: %w", err)
+	}
+
+	// Audit each key the profile changed, like a settings update does; keys the profile lacks
+	// were cleared back to their defaults
+	keys := make([]string, 0, len(previous)+len(profile.Settings))
+	for key := range previous {
+		keys = append(keys, key)
+	}
+	for key := range profile.Settings {
+		if _, ok := previous[key]; !ok && !unprofiledSettings[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if previous[key] == profile.Settings[key] {
+			continue
+		}
+		Log("system", "Setting changed", map[string]interface{}{
+			"key":       key,
+			"old_value": previous[key],
+			"new_value": profile.Settings[key],
+		})
+	}
+	return nil
+}
+
+// profiledSettings returns the current settings a profile covers, keyed by name
+func profiledSettings(q interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}) (map[string]string, error) {
+	rows, err := q.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		if !unprofiledSettings[key] {
+			settings[key] = value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating setting rows: %w", err)
+	}
+	return settings, nil
+}
+
+// scanSettingsProfile scans a settings_profiles row
+func scanSettingsProfile(row interface{ Scan(...any) error }) (*SettingsProfile, error) {
+	var profile SettingsProfile
+	var settingsJSON string
+	if err := row.Scan(&profile.Name, &settingsJSON, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(settingsJSON), &profile.Settings); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %w", err)
+	}
+	return &profile, nil
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// spendUnitsPerDollar is the precision spend is tracked at; simulated prices have four decimals
+const spendUnitsPerDollar = 10000
+
+// spendDay returns the UTC day a spend accumulator is keyed by, so totals reset at UTC midnight
+func spendDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+func toSpendUnits(amount float64) int64 {
+	return int64(math.Round(amount * spendUnitsPerDollar))
+}
+
+func fromSpendUnits(units int64) float64 {
+	return float64(units) / spendUnitsPerDollar
+}
+
+// AddProfileSpend adds amount to the profile's spend for the UTC day containing now, unless that
+// would take it over limit. A limit of 0 means unlimited. It reports whether the spend was added.
+// The check and the increment happen in one statement so concurrent sends can't both slip under.
+func AddProfileSpend(profileID string, amount, limit float64, now time.Time) (bool, error) {
+	units := toSpendUnits(amount)
+	limitUnits := int64(math.MaxInt64)
+	if limit > 0 {
+		limitUnits = toSpendUnits(limit)
+	}
+
+	query := `
+		INSERT INTO profile_spend (messaging_profile_id, day, amount_units)
+		SELECT ?, ?, ? WHERE ? <= ?
+		ON CONFLICT(messaging_profile_id, day) DO UPDATE SET amount_units = amount_units + excluded.amount_units
+		WHERE profile_spend.amount_units + excluded.amount_units <= ?
+	`
+	result, err := DB.Exec(query, profileID, spendDay(now), units, units, limitUnits, limitUnits)
+	if err != nil {
+		return false, fmt.Errorf("failed to record profile spend: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// RefundProfileSpend takes amount back off the profile's spend for the UTC day containing now, for
+// a charge whose message was never stored
+func RefundProfileSpend(profileID string, amount float64, now time.Time) error {
+	query := `
+		UPDATE profile_spend SET amount_units = MAX(amount_units - ?, 0)
+		WHERE messaging_profile_id = ? AND day = ?
+	`
+	if _, err := DB.Exec(query, toSpendUnits(amount), profileID, spendDay(now)); err != nil {
+		return fmt.Errorf("failed to refund profile spend: %w", err)
+	}
+	return nil
+}
+
+// GetProfileSpend returns the profile's spend in USD for the UTC day containing now
+func GetProfileSpend(profileID string, now time.Time) (float64, error) {
+	var units int64
+	err := DB.QueryRow("SELECT COALESCE(SUM(amount_units), 0) FROM profile_spend WHERE messaging_profile_id = ? AND day = ?", profileID, spendDay(now)).Scan(&units)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get profile spend: %w", err)
+	}
+	return fromSpendUnits(units), nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import "fmt"
+
+// MessageCounts breaks the stored messages down by direction and by status
+type MessageCounts struct {
+	ByDirection map[string]int `json:"by_direction"`
+	ByStatus    map[string]int `json:"by_status"`
+}
+
+// LogCounts breaks the stored logs down by level
+type LogCounts struct {
+	Total   int            `json:"total"`
+	ByLevel map[string]int `json:"by_level"`
+}
+
+// CountMessages returns how many messages are stored, excluding soft-deleted ones
+func CountMessages() (int, error) {
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM messages WHERE deleted_at IS NULL").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return count, nil
+}
+
+// GetMessageCounts groups the stored messages, excluding soft-deleted ones, by direction and status
+func GetMessageCounts() (MessageCounts, error) {
+	counts := MessageCounts{ByDirection: map[string]int{}, ByStatus: map[string]int{}}
+	if err := countGroups("SELECT direction, COUNT(*) FROM messages WHERE deleted_at IS NULL GROUP BY direction", counts.ByDirection); err != nil {
+		return MessageCounts{}, err
+	}
+	if err := countGroups("SELECT COALESCE(status, ''), COUNT(*) FROM messages WHERE deleted_at IS NULL GROUP BY status", counts.ByStatus); err != nil {
+		return MessageCounts{}, err
+	}
+	return counts, nil
+}
+
+// GetLogCounts groups the stored logs by level
+func GetLogCounts() (LogCounts, error) {
+	counts := LogCounts{ByLevel: map[string]int{}}
+	if err := countGroups("SELECT level, COUNT(*) FROM logs GROUP BY level", counts.ByLevel); err != nil {
+		return LogCounts{}, err
+	}
+	for _, n := range counts.ByLevel {
+		counts.Total += n
+	}
+	return counts, nil
+}
+
+// countGroups runs a "SELECT key, COUNT(*) ... GROUP BY key" query into groups
+func countGroups(query string, groups map[string]int) error {
+	rows, err := DB.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to count groups: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return fmt.Errorf("failed to scan group count: %w", err)
+		}
+		groups[key] = count
+	}
+	return rows.Err()
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TimelineEvent is one entry in a message's lifecycle
+type TimelineEvent struct {
+	Type       string          `json:"type"` // "created", "status" or "webhook"
+	OccurredAt time.Time       `json:"occurred_at"`
+	Status     string          `json:"status,omitempty"`  // The status entered, for "status" events
+	Message    string          `json:"message,omitempty"` // The delivery log message, for "webhook" events
+	Details    json.RawMessage `json:"details,omitempty"` // The delivery log details, for "webhook" events
+}
+
+// recordStatus appends a status to a message's history, using db, which may be the database or
+// a transaction
+func recordStatus(db interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, messageID, status string, at time.Time) error {
+	_, err := db.Exec("INSERT INTO message_status_history (message_id, status, occurred_at) VALUES (?, ?, ?)", messageID, status, at.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record message status: %w", err)
+	}
+	return nil
+}
+
+// GetMessageTimeline merges a message's creation, every status it has entered and each webhook
+// delivery attempt logged for it into one list, oldest first. Events at the same instant keep
+// that order, so a status precedes the webhook announcing it.
+func GetMessageTimeline(msg Message) ([]TimelineEvent, error) {
+	timeline := []TimelineEvent{{Type: "created", OccurredAt: msg.CreatedAt}}
+
+	rows, err := DB.Query("SELECT status, occurred_at FROM message_status_history WHERE message_id = ? ORDER BY id", msg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		event := TimelineEvent{Type: "status"}
+		if err := rows.Scan(&event.Status, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status history: %w", err)
+		}
+		timeline = append(timeline, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read status history: %w", err)
+	}
+
+	where, args := logFilterCondition(LogFilter{Category: "webhook", MessageID: msg.ID})
+	deliveries, err := queryLogs("SELECT id, created_at, level, category, message, details FROM logs WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range deliveries {
+		event := TimelineEvent{Type: "webhook", OccurredAt: entry.CreatedAt, Message: entry.Message}
+		if json.Valid([]byte(entry.Details)) {
+			event.Details = json.RawMessage(entry.Details)
+		}
+		timeline = append(timeline, event)
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].OccurredAt.Before(timeline[j].OccurredAt)
+	})
+	return timeline, nil
+}
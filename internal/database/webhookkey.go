@@ -0,0 +1,107 @@
+package database
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+)
+
+// Settings keys holding the base64-encoded ed25519 seeds used to sign webhooks
+const (
+	webhookKeySetting         = "webhook_signing_key"
+	previousWebhookKeySetting = "webhook_signing_key_previous"
+)
+
+var (
+	webhookKeyMu sync.Mutex
+	// memoryWebhookKey signs webhooks when the database isn't initialized (e.g., in webhook tests)
+	memoryWebhookKey ed25519.PrivateKey
+)
+
+// GetWebhookSigningKey returns the current webhook signing key, generating and storing one on first use
+func GetWebhookSigningKey() (ed25519.PrivateKey, error) {
+	webhookKeyMu.Lock()
+	defer webhookKeyMu.Unlock()
+
+	if DB == nil {
+		if memoryWebhookKey == nil {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate webhook signing key: %w", err)
+			}
+			memoryWebhookKey = key
+		}
+		return memoryWebhookKey, nil
+	}
+
+	key, err := loadWebhookKey(webhookKeySetting)
+	if err != nil || key != nil {
+		return key, err
+	}
+	return generateWebhookKey()
+}
+
+// GetPreviousWebhookSigningKey returns the key that was current before the last rotation, or nil if
+// the key has never been rotated
+func GetPreviousWebhookSigningKey() (ed25519.PrivateKey, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	webhookKeyMu.Lock()
+	defer webhookKeyMu.Unlock()
+	return loadWebhookKey(previousWebhookKeySetting)
+}
+
+// RotateWebhookSigningKey makes the current key the previous one and generates a new current key.
+// Without a database only the in-memory key is replaced; no previous key is kept.
+func RotateWebhookSigningKey() (ed25519.PrivateKey, error) {
+	webhookKeyMu.Lock()
+	defer webhookKeyMu.Unlock()
+
+	if DB == nil {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate webhook signing key: %w", err)
+		}
+		memoryWebhookKey = key
+		return key, nil
+	}
+
+	current, err := GetSetting(webhookKeySetting)
+	if err != nil {
+		return nil, err
+	}
+	if current != "" {
+		if err := SetSetting(previousWebhookKeySetting, current); err != nil {
+			return nil, err
+		}
+	}
+	return generateWebhookKey()
+}
+
+// loadWebhookKey reads a stored seed, returning nil if the setting is empty
+func loadWebhookKey(setting string) (ed25519.PrivateKey, error) {
+	value, err := GetSetting(setting)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid stored webhook signing key")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// generateWebhookKey creates and stores a new current signing key. Callers must hold webhookKeyMu.
+func generateWebhookKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook signing key: %w", err)
+	}
+	if err := SetSetting(webhookKeySetting, base64.StdEncoding.EncodeToString(key.Seed())); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
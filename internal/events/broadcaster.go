@@ -0,0 +1,61 @@
+package events
+
+import "sync"
+
+// Broadcaster fans out published events to every current subscriber.
+// Publishing never blocks: events are dropped for subscribers whose buffer is full.
+type Broadcaster[T any] struct {
+	mu          sync.Mutex
+	subscribers map[chan T]struct{}
+	bufferSize  int
+}
+
+// NewBroadcaster creates a broadcaster whose subscriber channels hold up to bufferSize events
+func NewBroadcaster[T any](bufferSize int) *Broadcaster[T] {
+	return &Broadcaster[T]{
+		subscribers: make(map[chan T]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe registers a new subscriber and returns its event channel and an unsubscribe function
+func (b *Broadcaster[T]) Subscribe() (<-chan T, func()) {
+	ch := make(chan T, b.bufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish sends an event to all subscribers without blocking
+func (b *Broadcaster[T]) Publish(event T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Subscriber is not keeping up; drop the event rather than stall the publisher
+		}
+	}
+}
+
+// SubscriberCount returns the number of active subscribers
+func (b *Broadcaster[T]) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
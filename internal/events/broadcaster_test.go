@@ -0,0 +1,70 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBroadcaster_PublishToSubscribers(t *testing.T) {
+	b := NewBroadcaster[string](4)
+
+	first, unsubFirst := b.Subscribe()
+	defer unsubFirst()
+	second, unsubSecond := b.Subscribe()
+	defer unsubSecond()
+
+	b.Publish("hello")
+
+	for i, ch := range []<-chan string{first, second} {
+		select {
+		case got := <-ch:
+			if got != "hello" {
+				t.Errorf("Subscriber %d: expected 'hello', got '%s'", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Subscriber %d: timeout waiting for event", i)
+		}
+	}
+}
+
+func TestBroadcaster_Unsubscribe(t *testing.T) {
+	b := NewBroadcaster[int](1)
+
+	ch, unsubscribe := b.Subscribe()
+	if b.SubscriberCount() != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d", b.SubscriberCount())
+	}
+
+	unsubscribe()
+	unsubscribe() // Safe to call twice
+
+	if b.SubscriberCount() != 0 {
+		t.Errorf("Expected 0 subscribers, got %d", b.SubscriberCount())
+	}
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+
+	// Publishing with no subscribers must not block or panic
+	b.Publish(1)
+}
+
+func TestBroadcaster_SlowSubscriberDoesNotBlock(t *testing.T) {
+	b := NewBroadcaster[int](1)
+	_, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			b.Publish(i)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+}
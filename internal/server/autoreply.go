@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// applyAutoReply sends the configured canned reply when an inbound message matches a keyword.
+// The reply is stored as an outbound message from the number that received the inbound message.
+func applyAutoReply(from, to, text, messagingProfileID string) {
+	autoReply, err := database.GetAutoReply(text)
+	if err != nil {
+		database.LogError("message", "Failed to look up auto-reply", map[string]interface{}{
+			"error": err.Error(),
+			"from":  from,
+		})
+		return
+	}
+	if autoReply == nil {
+		return
+	}
+
+	if autoReply.OptOut {
+		if err := database.AddOptOut(from, autoReply.Keyword); err != nil {
+			database.LogError("message", "Failed to record opt-out", map[string]interface{}{
+				"error":   err.Error(),
+				"number":  from,
+				"keyword": autoReply.Keyword,
+			})
+		} else {
+			database.Log("message", "Number opted out via keyword", map[string]interface{}{
+				"number":  from,
+				"keyword": autoReply.Keyword,
+			})
+		}
+	}
+
+	replyID := uuid.New().String()
+	if err := database.InsertMessage(replyID, to, from, autoReply.Reply, []string{}, messagingProfileID, "outbound"); err != nil {
+		database.LogError("message", "Failed to save auto-reply message", map[string]interface{}{
+			"error":   err.Error(),
+			"keyword": autoReply.Keyword,
+			"to":      from,
+		})
+		return
+	}
+
+	database.Log("message", "Auto-reply sent", map[string]interface{}{
+		"message_id": replyID,
+		"keyword":    autoReply.Keyword,
+		"from":       to,
+		"to":         from,
+	})
+}
+
+// HandleListAutoReplies handles GET /api/auto-replies
+func HandleListAutoReplies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	replies, err := database.GetAutoReplies()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve auto-replies.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, replies)
+}
+
+// HandleSetAutoReply handles POST /api/auto-replies
+func HandleSetAutoReply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Keyword string `json:"keyword"`
+		Reply   string `json:"reply"`
+		OptOut  *bool  `json:"opt_out"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+
+	keyword := database.NormalizeKeyword(req.Keyword)
+	if keyword == "" || req.Reply == "" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'keyword' and 'reply' parameters are required.", http.StatusBadRequest)
+		return
+	}
+
+	// STOP opts the sender out unless explicitly told otherwise
+	optOut := keyword == "STOP"
+	if req.OptOut != nil {
+		optOut = *req.OptOut
+	}
+
+	if err := database.SetAutoReply(keyword, req.Reply, optOut); err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save auto-reply.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Auto-reply configured", map[string]interface{}{
+		"keyword": keyword,
+		"opt_out": optOut,
+	})
+
+	autoReply, err := database.GetAutoReply(keyword)
+	if err != nil || autoReply == nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve saved auto-reply.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, autoReply)
+}
+
+// HandleDeleteAutoReply handles DELETE /api/auto-replies/{keyword}
+func HandleDeleteAutoReply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keyword := chi.URLParam(r, "keyword")
+	deleted, err := database.DeleteAutoReply(keyword)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to delete auto-reply.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] No auto-reply is configured for that keyword.", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// HandleListOptOuts handles GET /api/opt-outs
+func HandleListOptOuts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	optOuts, err := database.GetOptOuts()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve opt-outs.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, optOuts)
+}
+
+// HandleDeleteOptOut handles DELETE /api/opt-outs/{phone_number} (opts the number back in)
+func HandleDeleteOptOut(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	phoneNumber := chi.URLParam(r, "phone_number")
+	removed, err := database.RemoveOptOut(phoneNumber)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to remove opt-out.", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] That number is not opted out.", http.StatusNotFound)
+		return
+	}
+
+	database.Log("system", "Opt-out removed", map[string]interface{}{
+		"number": phoneNumber,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
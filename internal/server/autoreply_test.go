@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestAutoReply_StopThenBlockedSend(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Configure STOP keyword
+	body := map[string]interface{}{
+		"keyword": "stop",
+		"reply":   "You have been unsubscribed.",
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auto-replies", bytes.NewReader(bodyBytes))
+	rr := httptest.NewRecorder()
+	HandleSetAutoReply(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var autoReply map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &autoReply)
+	if autoReply["keyword"] != "STOP" {
+		t.Errorf("Expected keyword 'STOP', got '%v'", autoReply["keyword"])
+	}
+	if autoReply["opt_out"] != true {
+		t.Errorf("Expected STOP to opt out by default, got '%v'", autoReply["opt_out"])
+	}
+
+	// Inbound STOP from the subscriber
+	body = map[string]interface{}{
+		"from": "+15550001111",
+		"to":   "+15559990000",
+		"text": " Stop ",
+	}
+	bodyBytes, _ = json.Marshal(body)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/messages/inbound", bytes.NewReader(bodyBytes))
+	rr = httptest.NewRecorder()
+	HandleSimulateInbound(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// The canned reply should be stored as an outbound message back to the subscriber
+	messages, _ := database.GetAllMessages()
+	var reply *database.Message
+	for i := range messages {
+		if messages[i].Direction == "outbound" {
+			reply = &messages[i]
+		}
+	}
+	if reply == nil {
+		t.Fatal("Expected an outbound auto-reply message")
+	}
+	if reply.Recipient != "+15550001111" || reply.Sender != "+15559990000" {
+		t.Errorf("Expected reply from +15559990000 to +15550001111, got from %s to %s", reply.Sender, reply.Recipient)
+	}
+	if reply.Content != "You have been unsubscribed." {
+		t.Errorf("Expected reply text, got '%s'", reply.Content)
+	}
+
+	if !database.IsOptedOut("+15550001111") {
+		t.Fatal("Expected sender to be opted out")
+	}
+
+	// Subsequent outbound send to the opted-out number is rejected
+	body = map[string]interface{}{
+		"from":                 "+15559990000",
+		"to":                   "+15550001111",
+		"text":                 "Are you still there?",
+		"messaging_profile_id": "profile-123",
+	}
+	bodyBytes, _ = json.Marshal(body)
+
+	req = httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr = httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	errObj := response["errors"].([]interface{})[0].(map[string]interface{})
+	if errObj["code"] != "10013" {
+		t.Errorf("Expected error code '10013', got '%v'", errObj["code"])
+	}
+}
+
+func TestAutoReply_NoMatchSendsNothing(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetAutoReply("HELP", "Reply STOP to unsubscribe.", false)
+
+	body := map[string]interface{}{
+		"from": "+15550001111",
+		"to":   "+15559990000",
+		"text": "Hello there",
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", bytes.NewReader(bodyBytes))
+	rr := httptest.NewRecorder()
+	HandleInboundWebhook(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	messages, _ := database.GetAllMessages()
+	if len(messages) != 1 {
+		t.Errorf("Expected only the inbound message, got %d messages", len(messages))
+	}
+	if database.IsOptedOut("+15550001111") {
+		t.Error("Expected sender not to be opted out")
+	}
+}
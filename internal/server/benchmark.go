@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+	"telnyx-mock/internal/webhook"
+)
+
+// MaxLoadCount bounds how many messages a single load generation request may create
+const MaxLoadCount = 10000
+
+// loadBatchSize is how many messages are inserted per transaction
+const loadBatchSize = 500
+
+// Sender, recipient and text used for generated load messages
+const (
+	loadFrom = "+15550000001"
+	loadTo   = "+15550000002"
+	loadText = "Benchmark message"
+)
+
+// HandleGenerateLoad handles POST /api/benchmark/generate (debug mode only), inserting count outbound
+// messages in batches and reporting the insert throughput. With webhook_url, status callbacks are
+// dispatched for every message as well.
+func HandleGenerateLoad(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isDebugMode() {
+		validator.WriteError(w, "10010", "Forbidden", "[SmsSink] This endpoint is only available in debug mode.", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Count      int    `json:"count"`
+		WebhookURL string `json:"webhook_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+	if req.Count < 1 || req.Count > MaxLoadCount {
+		validator.WriteError(w, "10005", "Invalid parameter", fmt.Sprintf("[SmsSink] The 'count' parameter must be between 1 and %d.", MaxLoadCount), http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]string, req.Count)
+	for i := range ids {
+		ids[i] = uuid.New().String()
+	}
+
+	opts := database.MessageOptions{WebhookURL: req.WebhookURL, Encoding: "GSM-7"}
+	start := time.Now()
+	batches := 0
+	for i := 0; i < len(ids); i += loadBatchSize {
+		end := min(i+loadBatchSize, len(ids))
+		if err := database.InsertMessagesBatch(ids[i:end], loadFrom, loadTo, loadText, "", "outbound", opts); err != nil {
+			database.LogError("system", "Failed to generate load", map[string]interface{}{
+				"error":    err.Error(),
+				"inserted": i,
+			})
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to insert generated messages.", http.StatusInternalServerError)
+			return
+		}
+		batches++
+	}
+	elapsed := time.Since(start)
+
+	if req.WebhookURL != "" {
+		for _, id := range ids {
+			webhook.SendStatusCallbacks(webhook.MessageDetails{
+				ID:         id,
+				From:       loadFrom,
+				To:         loadTo,
+				Text:       loadText,
+				MediaURLs:  []string{},
+				Type:       "SMS",
+				Parts:      1,
+				WebhookURL: req.WebhookURL,
+			})
+		}
+	}
+
+	messagesPerSecond := float64(req.Count) / elapsed.Seconds()
+	database.Log("system", "Benchmark load generated", map[string]interface{}{
+		"count":               req.Count,
+		"duration_ms":         elapsed.Milliseconds(),
+		"messages_per_second": messagesPerSecond,
+		"webhooks":            req.WebhookURL != "",
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":               req.Count,
+		"batches":             batches,
+		"duration_ms":         elapsed.Milliseconds(),
+		"messages_per_second": messagesPerSecond,
+		"webhooks_dispatched": req.WebhookURL != "",
+	})
+}
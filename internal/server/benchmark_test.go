@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleGenerateLoad(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	generate := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		HandleGenerateLoad(rr, httptest.NewRequest(http.MethodPost, "/api/benchmark/generate", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := generate(`{"count": 10}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d outside debug mode, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	database.SetSetting("debug_mode", "true")
+
+	rr := generate(`{"count": 1200}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["count"] != float64(1200) || response["batches"] != float64(3) {
+		t.Errorf("Expected 1200 messages in 3 batches, got %v", response)
+	}
+	if rate, _ := response["messages_per_second"].(float64); rate <= 0 {
+		t.Errorf("Expected a positive messages_per_second, got %v", response["messages_per_second"])
+	}
+
+	messages, _ := database.GetAllMessages()
+	if len(messages) != 1200 {
+		t.Errorf("Expected 1200 stored messages, got %d", len(messages))
+	}
+
+	for _, body := range []string{`{"count": 0}`, `{"count": 10001}`} {
+		if rr := generate(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// HandleListBlockedNumbers handles GET /api/blocked-numbers
+func HandleListBlockedNumbers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blocked, err := database.GetBlockedNumbers()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve blocked numbers.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, blocked)
+}
+
+// HandleAddBlockedNumber handles POST /api/blocked-numbers
+func HandleAddBlockedNumber(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		PhoneNumber string `json:"phone_number"`
+		Reason      string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+
+	if req.PhoneNumber == "" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'phone_number' parameter is required.", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.AddBlockedNumber(req.PhoneNumber, req.Reason); err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to block number.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Number blocked", map[string]interface{}{
+		"number": req.PhoneNumber,
+		"reason": req.Reason,
+	})
+
+	writeJSON(w, http.StatusOK, req)
+}
+
+// HandleDeleteBlockedNumber handles DELETE /api/blocked-numbers/{phone_number}
+func HandleDeleteBlockedNumber(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	phoneNumber := chi.URLParam(r, "phone_number")
+	removed, err := database.RemoveBlockedNumber(phoneNumber)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to unblock number.", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] That number is not blocked.", http.StatusNotFound)
+		return
+	}
+
+	database.Log("system", "Number unblocked", map[string]interface{}{
+		"number": phoneNumber,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestBlockedNumber_SendRejected(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := map[string]interface{}{
+		"phone_number": "+15557778888",
+		"reason":       "compliance test",
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/blocked-numbers", bytes.NewReader(bodyBytes))
+	rr := httptest.NewRecorder()
+	HandleAddBlockedNumber(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	body = map[string]interface{}{
+		"from":                 "+15551112222",
+		"to":                   "+15557778888",
+		"text":                 "Hello",
+		"messaging_profile_id": "profile-123",
+	}
+	bodyBytes, _ = json.Marshal(body)
+
+	req = httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr = httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	errObj := response["errors"].([]interface{})[0].(map[string]interface{})
+	if errObj["code"] != "10013" {
+		t.Errorf("Expected error code '10013', got '%v'", errObj["code"])
+	}
+	if errObj["detail"] != "[SmsSink] Recipient has opted out." {
+		t.Errorf("Unexpected error detail '%v'", errObj["detail"])
+	}
+
+	// Nothing should have been stored
+	messages, _ := database.GetAllMessages()
+	if len(messages) != 0 {
+		t.Errorf("Expected 0 messages, got %d", len(messages))
+	}
+}
+
+func TestBlockedNumber_ListAndDelete(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.AddBlockedNumber("+15557778888", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/blocked-numbers", nil)
+	rr := httptest.NewRecorder()
+	HandleListBlockedNumbers(rr, req)
+
+	var blocked []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &blocked)
+	if len(blocked) != 1 || blocked[0]["phone_number"] != "+15557778888" {
+		t.Fatalf("Expected one blocked number, got %v", blocked)
+	}
+
+	removed, err := database.RemoveBlockedNumber("+15557778888")
+	if err != nil || !removed {
+		t.Fatalf("Expected number to be removed, got removed=%v err=%v", removed, err)
+	}
+	if database.IsBlocked("+15557778888") {
+		t.Error("Expected number to be unblocked")
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+	"telnyx-mock/internal/webhook"
+)
+
+// Bounds on a single burst
+const (
+	MaxBurstCount   = 10000
+	MaxBurstSeconds = 3600
+)
+
+// burstText is the text of every message a burst creates
+const burstText = "Burst message"
+
+// burst tracks a running or finished burst
+type burst struct {
+	mu          sync.Mutex
+	id          string
+	count       int
+	overSeconds float64
+	created     int
+	status      string // running, completed, failed or cancelled
+	startedAt   time.Time
+	finishedAt  *time.Time
+	stop        chan struct{}
+}
+
+// bursts holds every burst started since the last reset, by ID
+var bursts = struct {
+	mu      sync.Mutex
+	entries map[string]*burst
+}{entries: map[string]*burst{}}
+
+// snapshot returns the burst's progress as a response object
+func (b *burst) snapshot() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return map[string]interface{}{
+		"id":           b.id,
+		"count":        b.count,
+		"over_seconds": b.overSeconds,
+		"created":      b.created,
+		"status":       b.status,
+		"started_at":   b.startedAt,
+		"finished_at":  b.finishedAt,
+	}
+}
+
+// finish records the burst's final status
+func (b *burst) finish(status string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now().UTC()
+	b.status, b.finishedAt = status, &now
+}
+
+// run creates the burst's messages spread evenly over its window: message i is created at
+// startedAt + i*window/count, so the first goes out immediately and the last one slot before the end
+func (b *burst) run(webhookURL string) {
+	interval := time.Duration(b.overSeconds*float64(time.Second)) / time.Duration(b.count)
+	for i := 0; i < b.count; i++ {
+		// A burst running behind schedule never waits, so check for a stop on every message
+		select {
+		case <-b.stop:
+			b.finish("cancelled")
+			return
+		default:
+		}
+
+		if wait := time.Until(b.startedAt.Add(time.Duration(i) * interval)); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-b.stop:
+				b.finish("cancelled")
+				return
+			}
+		}
+
+		id := uuid.New().String()
+		opts := database.MessageOptions{WebhookURL: webhookURL, Encoding: "GSM-7"}
+		if err := database.InsertMessageWithOptions(id, loadFrom, loadTo, burstText, nil, "", "outbound", opts); err != nil {
+			database.LogError("system", "Failed to create burst message", map[string]interface{}{
+				"error":    err.Error(),
+				"burst_id": b.id,
+				"created":  i,
+			})
+			b.finish("failed")
+			return
+		}
+		webhook.SendStatusCallbacks(webhook.MessageDetails{
+			ID:         id,
+			From:       loadFrom,
+			To:         loadTo,
+			Text:       burstText,
+			MediaURLs:  []string{},
+			Type:       "SMS",
+			Parts:      1,
+			WebhookURL: webhookURL,
+		})
+
+		b.mu.Lock()
+		b.created++
+		b.mu.Unlock()
+	}
+
+	b.finish("completed")
+	database.Log("system", "Burst completed", map[string]interface{}{
+		"burst_id": b.id,
+		"count":    b.count,
+	})
+}
+
+// cancelBursts stops every running burst and forgets all bursts
+func cancelBursts() {
+	bursts.mu.Lock()
+	defer bursts.mu.Unlock()
+	for _, b := range bursts.entries {
+		close(b.stop)
+	}
+	bursts.entries = map[string]*burst{}
+}
+
+// HandleStartBurst handles POST /api/simulator/burst (debug mode only), creating count outbound
+// messages spread evenly over over_seconds in the background. The response is a handle whose
+// progress can be polled at /api/simulator/burst/{id}.
+func HandleStartBurst(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isDebugMode() {
+		validator.WriteError(w, "10010", "Forbidden", "[SmsSink] This endpoint is only available in debug mode.", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Count       int     `json:"count"`
+		OverSeconds float64 `json:"over_seconds"`
+		WebhookURL  string  `json:"webhook_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+	if req.Count < 1 || req.Count > MaxBurstCount {
+		validator.WriteError(w, "10005", "Invalid parameter", fmt.Sprintf("[SmsSink] The 'count' parameter must be between 1 and %d.", MaxBurstCount), http.StatusBadRequest)
+		return
+	}
+	if req.OverSeconds < 0 || req.OverSeconds > MaxBurstSeconds {
+		validator.WriteError(w, "10005", "Invalid parameter", fmt.Sprintf("[SmsSink] The 'over_seconds' parameter must be between 0 and %d.", MaxBurstSeconds), http.StatusBadRequest)
+		return
+	}
+
+	b := &burst{
+		id:          uuid.New().String(),
+		count:       req.Count,
+		overSeconds: req.OverSeconds,
+		status:      "running",
+		startedAt:   time.Now().UTC(),
+		stop:        make(chan struct{}),
+	}
+	bursts.mu.Lock()
+	bursts.entries[b.id] = b
+	bursts.mu.Unlock()
+
+	database.Log("system", "Burst started", map[string]interface{}{
+		"burst_id":     b.id,
+		"count":        req.Count,
+		"over_seconds": req.OverSeconds,
+		"webhooks":     req.WebhookURL != "",
+	})
+
+	go b.run(req.WebhookURL)
+
+	w.Header().Set("Location", "/api/simulator/burst/"+b.id)
+	writeJSON(w, http.StatusAccepted, b.snapshot())
+}
+
+// HandleGetBurst handles GET /api/simulator/burst/{id}, reporting a burst's progress
+func HandleGetBurst(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bursts.mu.Lock()
+	b := bursts.entries[chi.URLParam(r, "id")]
+	bursts.mu.Unlock()
+	if b == nil {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Burst not found.", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, b.snapshot())
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleStartBurst_SpreadsOverWindow(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	defer cancelBursts()
+
+	router := chi.NewRouter()
+	router.Post("/api/simulator/burst", HandleStartBurst)
+	router.Get("/api/simulator/burst/{id}", HandleGetBurst)
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response
+	}
+
+	if code, _ := do(http.MethodPost, "/api/simulator/burst", `{"count": 5, "over_seconds": 1}`); code != http.StatusForbidden {
+		t.Fatalf("Expected status %d outside debug mode, got %d", http.StatusForbidden, code)
+	}
+
+	database.SetSetting("debug_mode", "true")
+
+	for _, body := range []string{`{"count": 0, "over_seconds": 1}`, `{"count": 5, "over_seconds": -1}`, `{"count": 5, "over_seconds": 3601}`} {
+		if code, _ := do(http.MethodPost, "/api/simulator/burst", body); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, body, code)
+		}
+	}
+
+	start := time.Now().UTC()
+	code, handle := do(http.MethodPost, "/api/simulator/burst", `{"count": 5, "over_seconds": 0.5}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, code)
+	}
+	id := handle["id"].(string)
+
+	var progress map[string]interface{}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, progress = do(http.MethodGet, "/api/simulator/burst/"+id, "")
+		if progress["status"] != "running" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if progress["status"] != "completed" || progress["created"] != float64(5) {
+		t.Fatalf("Expected a completed burst of 5, got %v", progress)
+	}
+
+	messages, _ := database.GetMessages(database.MessageFilter{Sort: "created_at", Order: "asc"})
+	if len(messages) != 5 {
+		t.Fatalf("Expected 5 burst messages, got %d", len(messages))
+	}
+	// Slots are 100ms apart: the first lands immediately, the last 400ms in, all inside the window
+	first, last := messages[0].CreatedAt, messages[4].CreatedAt
+	if first.Sub(start) > 100*time.Millisecond {
+		t.Errorf("Expected the first message immediately, got it after %v", first.Sub(start))
+	}
+	if spread := last.Sub(first); spread < 350*time.Millisecond || last.Sub(start) > 600*time.Millisecond {
+		t.Errorf("Expected messages spread across the 0.5s window, got first %v and last %v after start", first.Sub(start), last.Sub(start))
+	}
+
+	if code, _ := do(http.MethodGet, "/api/simulator/burst/unknown", ""); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown burst, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestBurstRun_StopsWithoutWaiting(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// With no window there's never a wait, so the stop must be noticed between messages
+	b := &burst{
+		id:        "burst-1",
+		count:     1000,
+		status:    "running",
+		startedAt: time.Now().UTC(),
+		stop:      make(chan struct{}),
+	}
+	close(b.stop)
+	b.run("")
+
+	progress := b.snapshot()
+	if progress["status"] != "cancelled" || progress["created"] != 0 {
+		t.Errorf("Expected a cancelled burst with nothing created, got %v", progress)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	"telnyx-mock/internal/database"
+)
+
+// carrierRejects reports whether the configured carrier reject rules match an outbound message,
+// either by recipient pattern or by a token appearing in the text
+func carrierRejects(to, text string) bool {
+	pattern, token := database.GetCarrierRejectRules()
+
+	if token != "" && strings.Contains(text, token) {
+		return true
+	}
+
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			database.LogError("message", "Invalid carrier reject pattern", map[string]interface{}{
+				"error":   err.Error(),
+				"pattern": pattern,
+			})
+			return false
+		}
+		return re.MatchString(to)
+	}
+
+	return false
+}
+
+// blockedCountryCode returns the blocked_country_codes prefix matching an outbound recipient, or
+// "" if its country is permitted
+func blockedCountryCode(to string) string {
+	for _, prefix := range database.GetStringListSetting("blocked_country_codes") {
+		if strings.HasPrefix(to, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// isCountryCodePrefix reports whether s is an E.164 country code prefix: '+' and 1-4 digits,
+// allowing NANP area codes such as +1876 to narrow +1
+func isCountryCodePrefix(s string) bool {
+	if len(s) < 2 || len(s) > 5 || s[0] != '+' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/webhook"
+)
+
+func TestHandleCreateMessage_CarrierReject(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("carrier_reject_pattern", `^\+1555`)
+	database.SetSetting("carrier_reject_token", "#reject")
+
+	var mu sync.Mutex
+	received := map[string][]webhook.TelnyxWebhookPayload{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		to := payload.Data.Payload["to"].([]interface{})[0].(map[string]interface{})["phone_number"].(string)
+		received[to] = append(received[to], payload)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	send := func(to, text string) string {
+		body := map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   to,
+			"text":                 text,
+			"messaging_profile_id": "profile-1",
+			"webhook_url":          server.URL,
+		}
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+
+	patternID := send("+15551234567", "hello")
+	tokenID := send("+14445550000", "please #reject this")
+	send("+14445551111", "hello")
+
+	time.Sleep(2500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, to := range []string{"+15551234567", "+14445550000"} {
+		events := received[to]
+		if len(events) != 1 {
+			t.Fatalf("Expected exactly 1 webhook for rejected %s, got %d", to, len(events))
+		}
+		data := events[0].Data
+		if data.EventType != "message.failed" {
+			t.Errorf("Expected 'message.failed' for %s, got '%s'", to, data.EventType)
+		}
+		errs := data.Payload["errors"].([]interface{})
+		if errs[0].(map[string]interface{})["code"] != "30006" {
+			t.Errorf("Expected error code 30006 for %s, got %v", to, errs[0])
+		}
+	}
+
+	if events := received["+14445551111"]; len(events) != 2 || events[0].Data.EventType != "message.sent" {
+		t.Errorf("Expected normal sent/delivered sequence for unmatched message, got %d events", len(events))
+	}
+
+	for _, id := range []string{patternID, tokenID} {
+		if msg, _ := database.GetMessageByID(id); msg == nil || msg.Status != "failed" {
+			t.Errorf("Expected stored status 'failed' for %s, got %+v", id, msg)
+		}
+	}
+}
+
+func TestHandleCreateMessage_GroupRecipientRejected(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("carrier_reject_pattern", `^\+1555`)
+	database.SetSetting("webhook_initial_delay_ms", "0")
+
+	received := make(chan webhook.TelnyxWebhookPayload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   []string{"+14445550001", "+15550000002", "+14445550003"},
+		"text":                 "Group hello",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          server.URL,
+		"webhook_delay_ms":     10,
+	})
+	if data["type"] != "MMS" {
+		t.Errorf("Expected a group message to be 'MMS', got %v", data["type"])
+	}
+	if to := data["to"].([]interface{}); len(to) != 3 {
+		t.Fatalf("Expected all 3 recipients in the response, got %v", to)
+	}
+
+	want := map[string]string{"+14445550001": "delivered", "+15550000002": "failed", "+14445550003": "delivered"}
+	for _, eventType := range []string{"message.sent", "message.delivered"} {
+		var payload webhook.TelnyxWebhookPayload
+		select {
+		case payload = <-received:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timeout waiting for %s", eventType)
+		}
+		if payload.Data.EventType != eventType {
+			t.Fatalf("Expected '%s', got '%s'", eventType, payload.Data.EventType)
+		}
+		if eventType != "message.delivered" {
+			continue
+		}
+
+		to := payload.Data.Payload["to"].([]interface{})
+		if len(to) != 3 {
+			t.Fatalf("Expected 3 recipients in the webhook, got %d", len(to))
+		}
+		for _, entry := range to {
+			recipient := entry.(map[string]interface{})
+			if number := recipient["phone_number"].(string); recipient["status"] != want[number] {
+				t.Errorf("Expected %s to be '%s' in the webhook, got %v", number, want[number], recipient["status"])
+			}
+		}
+	}
+
+	msg, _ := database.GetMessageByID(data["id"].(string))
+	if msg == nil || msg.Status != "delivered" {
+		t.Errorf("Expected the group message to be 'delivered', got %+v", msg)
+	}
+	recipients, err := database.GetMessageRecipients(data["id"].(string))
+	if err != nil || len(recipients) != 3 {
+		t.Fatalf("Expected 3 stored recipients, got %v (%v)", recipients, err)
+	}
+	for _, recipient := range recipients {
+		if recipient.Status != want[recipient.PhoneNumber] {
+			t.Errorf("Expected stored %s to be '%s', got '%s'", recipient.PhoneNumber, want[recipient.PhoneNumber], recipient.Status)
+		}
+	}
+
+	// Retrieving the message lists every participant with its own status
+	router := chi.NewRouter()
+	router.Get("/v2/messages/{id}", HandleRetrieveMessage)
+	req := httptest.NewRequest(http.MethodGet, "/v2/messages/"+data["id"].(string), nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var response struct {
+		Data struct {
+			To []database.MessageRecipient `json:"to"`
+		} `json:"data"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Data.To) != 3 {
+		t.Fatalf("Expected 3 recipients from GET /v2/messages/{id}, got %s", rr.Body.String())
+	}
+	for _, recipient := range response.Data.To {
+		if recipient.Status != want[recipient.PhoneNumber] {
+			t.Errorf("Expected retrieved %s to be '%s', got '%s'", recipient.PhoneNumber, want[recipient.PhoneNumber], recipient.Status)
+		}
+	}
+}
+
+func TestHandleSetSettings_InvalidCarrierRejectPattern(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader([]byte(`{"carrier_reject_pattern": "([a-"}`)))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleCreateMessage_BlockedCountryCodes(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader([]byte(`{"blocked_country_codes": ["+44", "+33"]}`)))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	send := func(to string) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(map[string]interface{}{
+			"from":                 "+15550001111",
+			"to":                   to,
+			"text":                 "Hello",
+			"messaging_profile_id": "profile-1",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		return rr
+	}
+
+	rr = send("+447700900123")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d for a blocked country, got %d. Body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	errObj := response["errors"].([]interface{})[0].(map[string]interface{})
+	if errObj["code"] != "10013" || errObj["detail"] != "[SmsSink] Destination country not permitted." {
+		t.Errorf("Unexpected error %v", errObj)
+	}
+
+	if rr := send("+4915112345678"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for an allowed country, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if messages, _ := database.GetAllMessages(); len(messages) != 1 {
+		t.Errorf("Expected only the allowed send to be stored, got %d messages", len(messages))
+	}
+}
+
+func TestHandleSetSettings_InvalidBlockedCountryCode(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader([]byte(`{"blocked_country_codes": ["44"]}`)))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"telnyx-mock/internal/validator"
+)
+
+// unrecognizedFields returns the top-level JSON keys in a message request body that
+// don't map to any MessageRequest field, sorted for stable output
+func unrecognizedFields(body []byte) []string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return []string{}
+	}
+
+	known := map[string]bool{}
+	t := reflect.TypeOf(validator.MessageRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known[name] = true
+		}
+	}
+
+	unknown := []string{}
+	for key := range raw {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleCreateMessage_Echo(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := []byte(`{"from": "+1234567890", "to": "+0987654321", "text": "Hi", "messaging_profile_id": "profile-1", "sendAt": "later"}`)
+
+	send := func() map[string]interface{} {
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages?echo=true", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response
+	}
+
+	// Outside debug mode the echo is stripped
+	if _, ok := send()["_debug"]; ok {
+		t.Error("Expected no '_debug' object when debug mode is off")
+	}
+
+	database.SetSetting("debug_mode", "true")
+	debug, ok := send()["_debug"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected '_debug' object in debug mode")
+	}
+
+	received := debug["received"].(map[string]interface{})
+	if received["text"] != "Hi" || received["messaging_profile_id"] != "profile-1" {
+		t.Errorf("Expected parsed request fields, got %v", received)
+	}
+
+	unrecognized := debug["unrecognized"].([]interface{})
+	if len(unrecognized) != 1 || unrecognized[0] != "sendAt" {
+		t.Errorf("Expected ['sendAt'] as unrecognized, got %v", unrecognized)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"telnyx-mock/internal/validator"
+	"telnyx-mock/internal/webhook"
+)
+
+// sseKeepaliveInterval controls how often an idle event stream sends a comment line
+// so proxies don't close the connection
+var sseKeepaliveInterval = 15 * time.Second
+
+// HandleWebhookEvents handles GET /api/webhooks/events (Server-Sent Events)
+// Each webhook delivery attempt is emitted as a "delivery" event as it happens.
+func HandleWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Streaming is not supported by this connection.", http.StatusInternalServerError)
+		return
+	}
+
+	deliveries, unsubscribe := webhook.Deliveries.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case event, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: delivery\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
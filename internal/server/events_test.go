@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/webhook"
+)
+
+func TestHandleWebhookEvents_StreamsDeliveries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(HandleWebhookEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to connect to event stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got '%s'", ct)
+	}
+
+	// Wait for the handler to subscribe before publishing
+	deadline := time.Now().Add(2 * time.Second)
+	for webhook.Deliveries.SubscriberCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	webhook.Deliveries.Publish(webhook.DeliveryEvent{
+		URL:        "https://example.com/hook",
+		EventType:  "message.sent",
+		MessageID:  "msg-1",
+		StatusCode: 200,
+		Attempt:    1,
+		Success:    true,
+	})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("Stream closed before delivery event arrived")
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var event webhook.DeliveryEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+			if event.EventType != "message.sent" || event.StatusCode != 200 || !event.Success || event.Attempt != 1 {
+				t.Errorf("Unexpected event: %+v", event)
+			}
+			return
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timeout waiting for delivery event")
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/sms"
+	"telnyx-mock/internal/webhook"
+)
+
+// ExpirySweepInterval is how often the background sweeper checks for expired messages
+const ExpirySweepInterval = time.Minute
+
+// messageDetailsFromRecord rebuilds the webhook details for a stored message
+func messageDetailsFromRecord(msg database.Message) webhook.MessageDetails {
+	mediaURLs := []string{}
+	if msg.MediaURLs != "" {
+		_ = json.Unmarshal([]byte(msg.MediaURLs), &mediaURLs)
+	}
+
+	// A group message's participants carry their own statuses
+	var recipients, failedRecipients []string
+	if group, err := database.GetMessageRecipients(msg.ID); err == nil {
+		for _, recipient := range group {
+			recipients = append(recipients, recipient.PhoneNumber)
+			if recipient.Status == "failed" {
+				failedRecipients = append(failedRecipients, recipient.PhoneNumber)
+			}
+		}
+	}
+
+	// Messages sent through the API store the type they were classified as (which may be MMS for a
+	// subject alone); older and inbound ones fall back to classifying by media and recipients
+	msgType := msg.Type
+	if msgType == "" {
+		msgType = "SMS"
+		if len(mediaURLs) > 0 || len(recipients) > 1 {
+			msgType = "MMS"
+		}
+	}
+
+	encoding := msg.Encoding
+	if encoding == "" {
+		encoding = "GSM-7"
+	}
+	// The stored parts keep a forced count; older rows are recounted from the text
+	parts := msg.Parts
+	if parts == 0 {
+		parts = sms.CountParts(msg.Content, encoding)
+	}
+	cost := sms.EstimateCost(msgType, parts, database.GetBoolSetting("detailed_cost", false))
+
+	// The stored webhook delay reproduces the timing the message's webhooks were sent with
+	var delay *time.Duration
+	if msg.WebhookDelayMs != nil {
+		d := time.Duration(*msg.WebhookDelayMs) * time.Millisecond
+		delay = &d
+	}
+
+	return webhook.MessageDetails{
+		ID:                 msg.ID,
+		From:               msg.Sender,
+		To:                 msg.Recipient,
+		Recipients:         recipients,
+		FailedRecipients:   failedRecipients,
+		Text:               msg.Content,
+		MediaURLs:          mediaURLs,
+		MessagingProfileID: msg.MessagingProfileID,
+		Type:               msgType,
+		Parts:              parts,
+		Cost:               &cost,
+		WebhookURL:         msg.WebhookURL,
+		WebhookFailoverURL: msg.WebhookFailoverURL,
+		WebhookURLs:        msg.WebhookURLs,
+		Priority:           msg.Priority,
+		Delay:              delay,
+	}
+}
+
+// SweepExpiredMessages transitions undelivered messages past their valid_until to "expired"
+// and fires a message.failed webhook for those with a webhook URL. It returns the number expired.
+func SweepExpiredMessages() (int, error) {
+	expired, err := database.ExpireMessages(time.Now().UTC())
+	if err != nil {
+		database.LogError("message", "Failed to sweep expired messages", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return len(expired), err
+	}
+
+	for _, msg := range expired {
+		database.LogWarning("message", "Message expired before delivery", map[string]interface{}{
+			"message_id":  msg.ID,
+			"to":          msg.Recipient,
+			"valid_until": msg.ValidUntil,
+		})
+		webhook.SendFailureCallback(messageDetailsFromRecord(msg), "expired", webhook.ExpiredReason)
+		forgetManualStatus(msg.ID)
+	}
+
+	return len(expired), nil
+}
+
+// StartExpirySweeper runs SweepExpiredMessages immediately and then on every interval
+// until the returned stop function is called
+func StartExpirySweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if count, err := SweepExpiredMessages(); err == nil && count > 0 {
+				log.Printf("Expired %d message(s) past valid_until", count)
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
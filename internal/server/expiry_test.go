@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/webhook"
+)
+
+func TestSweepExpiredMessages(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	received := make(chan webhook.TelnyxWebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Short validity window that has already elapsed
+	database.InsertMessageWithOptions("expired-id", "+111", "+222", "too late", []string{}, "profile-1", "outbound", database.MessageOptions{
+		ValidUntil: time.Now().UTC().Add(-time.Second),
+		WebhookURL: server.URL,
+	})
+	// Still within its window
+	database.InsertMessageWithOptions("valid-id", "+111", "+333", "on time", []string{}, "profile-1", "outbound", database.MessageOptions{
+		ValidUntil: time.Now().UTC().Add(time.Hour),
+	})
+	// Already delivered messages are never expired
+	database.InsertMessageWithOptions("delivered-id", "+111", "+444", "done", []string{}, "profile-1", "outbound", database.MessageOptions{
+		Status:     "delivered",
+		ValidUntil: time.Now().UTC().Add(-time.Second),
+	})
+
+	count, err := SweepExpiredMessages()
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 expired message, got %d", count)
+	}
+
+	for id, want := range map[string]string{"expired-id": "expired", "valid-id": "queued", "delivered-id": "delivered"} {
+		msg, _ := database.GetMessageByID(id)
+		if msg == nil || msg.Status != want {
+			t.Errorf("Expected %s status '%s', got %+v", id, want, msg)
+		}
+	}
+
+	select {
+	case payload := <-received:
+		if payload.Data.EventType != "message.failed" {
+			t.Errorf("Expected event 'message.failed', got '%s'", payload.Data.EventType)
+		}
+		if payload.Data.Payload["status"] != "expired" {
+			t.Errorf("Expected payload status 'expired', got '%v'", payload.Data.Payload["status"])
+		}
+		errs, ok := payload.Data.Payload["errors"].([]interface{})
+		if !ok || len(errs) == 0 {
+			t.Fatal("Expected errors in failed payload")
+		}
+		if errs[0].(map[string]interface{})["title"] != "Message expired" {
+			t.Errorf("Expected expired reason, got %v", errs[0])
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for message.failed webhook")
+	}
+
+	// A second sweep finds nothing new
+	if count, _ := SweepExpiredMessages(); count != 0 {
+		t.Errorf("Expected second sweep to expire 0 messages, got %d", count)
+	}
+}
+
+func TestSweepExpiredMessages_StopsPendingStatusEvents(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	events := make(chan string, 10)
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		events <- payload.Data.EventType
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer consumer.Close()
+
+	// The message expires while it waits for message.sent
+	database.SetSetting("webhook_initial_delay_ms", "300")
+	id := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Too slow",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          consumer.URL,
+	})["id"].(string)
+	database.DB.Exec("UPDATE messages SET valid_until = ? WHERE id = ?", time.Now().UTC().Add(-time.Second), id)
+	if count, err := SweepExpiredMessages(); err != nil || count != 1 {
+		t.Fatalf("Expected the sweep to expire the message, got %d (%v)", count, err)
+	}
+
+	// Long enough for the default sent and delivered delays to have run out
+	time.Sleep(2500 * time.Millisecond)
+
+	msg, _ := database.GetMessageByID(id)
+	if msg == nil || msg.Status != "expired" {
+		t.Errorf("Expected the message to stay expired, got %+v", msg)
+	}
+	received := []string{}
+	for len(events) > 0 {
+		received = append(received, <-events)
+	}
+	if len(received) != 1 || received[0] != "message.failed" {
+		t.Errorf("Expected only the message.failed expiry webhook, got %v", received)
+	}
+}
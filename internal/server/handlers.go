@@ -2,38 +2,60 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/simrand"
+	"telnyx-mock/internal/sms"
 	"telnyx-mock/internal/validator"
 	"telnyx-mock/internal/webhook"
 )
 
 // isDebugMode checks if debug mode is enabled (env var or database setting)
 func isDebugMode() bool {
-	// Environment variable takes precedence
-	if os.Getenv("SMSSINK_DEBUG") == "true" {
-		return true
+	return database.DebugEnabled()
+}
+
+// omittableResponseFields lists the create response data fields response_omit_fields may drop
+var omittableResponseFields = []string{
+	"id", "record_type", "direction", "messaging_profile_id", "from", "to", "text", "media", "type",
+	"subject", "valid_until", "webhook_url", "webhook_failover_url", "webhook_urls", "use_profile_webhooks", "encoding",
+	"parts", "tags", "cost", "received_at", "sent_at", "completed_at", "created_at", "updated_at",
+	"truncated", "skip_webhooks",
+}
+
+// protectedResponseFields lists the create response data fields extra fields may not overwrite
+var protectedResponseFields = []string{"id", "record_type", "direction", "messaging_profile_id", "from", "to"}
+
+// protectedField returns the first protected field in extra, or "" if there is none
+func protectedField(extra map[string]interface{}) string {
+	for _, field := range protectedResponseFields {
+		if _, ok := extra[field]; ok {
+			return field
+		}
 	}
-	// Otherwise check database setting
-	return database.IsDebugMode()
+	return ""
 }
 
 // HandleCreateMessage handles POST /v2/messages
 func HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		writeCreateError(w, r, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Read body for parsing
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
-		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Failed to read request body.", http.StatusBadRequest)
+		writeCreateError(w, r, "10005", "Invalid parameter", "[SmsSink] Failed to read request body.", http.StatusBadRequest)
 		return
 	}
 
@@ -54,7 +76,7 @@ func HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 			"ip":         r.RemoteAddr,
 			"user_agent": r.UserAgent(),
 		})
-		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload: "+errMsg, http.StatusBadRequest)
+		writeCreateError(w, r, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload: "+errMsg, http.StatusBadRequest)
 		return
 	}
 
@@ -67,84 +89,260 @@ func HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 			"to":          req.NormalizeTo(),
 			"ip":          r.RemoteAddr,
 		})
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(statusCode)
-		json.NewEncoder(w).Encode(errResp)
+		writeErrorResponse(w, r, statusCode, errResp)
 		return
 	}
 
-	// Get normalized 'to' value (handles both string and array formats)
+	// With a media size limit, each media URL is sized with a HEAD request (cached per URL)
+	if maxBytes := database.GetIntSetting("mms_max_media_bytes", 0); maxBytes > 0 {
+		if url, size := oversizedMedia(req.MediaURLs, int64(maxBytes)); url != "" {
+			database.LogError("message", "Validation failed for outbound message: media too large", map[string]interface{}{
+				"url":       url,
+				"size":      size,
+				"max_bytes": maxBytes,
+			})
+			writeCreateError(w, r, "10005", "Invalid parameter", fmt.Sprintf("[SmsSink] The media at '%s' is %d bytes, over the %d byte limit.", url, size, maxBytes), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	// A reused Idempotency-Key replays the original response instead of creating another message
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey != "" {
+		if existing, err := database.GetIdempotencyKey(idempotencyKey); err == nil && existing != nil {
+			database.Log("message", "Replayed response for reused idempotency key", map[string]interface{}{
+				"idempotency_key": idempotencyKey,
+				"message_id":      existing.MessageID,
+			})
+			// The replay answers exactly like the original, including a 201's Location
+			w.Header().Set("Idempotent-Replayed", "true")
+			if existing.Status == http.StatusCreated {
+				w.Header().Set("Location", "/v2/messages/"+existing.MessageID)
+			}
+			if wantsXML(r) {
+				if replayed, err := xmlMessageResponseFromJSON([]byte(existing.Response)); err == nil {
+					writeXML(w, existing.Status, replayed)
+					return
+				}
+			}
+			writeJSON(w, existing.Status, json.RawMessage(existing.Response))
+			return
+		}
+	}
+
+	// Get normalized 'to' value (handles both string and array formats); a group MMS lists
+	// every participant, and to is the first
 	to := req.NormalizeTo()
+	recipients := req.Recipients()
+
+	for _, recipient := range recipients {
+		// Reject sends to numbers that have opted out (e.g. by texting STOP)
+		if database.IsOptedOut(recipient) {
+			database.LogWarning("message", "Outbound message rejected: recipient opted out", map[string]interface{}{
+				"from": req.From,
+				"to":   recipient,
+			})
+			writeCreateError(w, r, "10013", "Recipient opted out", "[SmsSink] Recipient has opted out.", http.StatusForbidden)
+			return
+		}
+
+		// Reject sends to numbers on the maintained blocklist
+		if database.IsBlocked(recipient) {
+			database.LogWarning("message", "Outbound message rejected: recipient is blocked", map[string]interface{}{
+				"from": req.From,
+				"to":   recipient,
+			})
+			writeCreateError(w, r, "10013", "Recipient opted out", "[SmsSink] Recipient has opted out.", http.StatusForbidden)
+			return
+		}
+
+		// Reject sends to countries the account isn't permitted to message
+		if prefix := blockedCountryCode(recipient); prefix != "" {
+			database.LogWarning("message", "Outbound message rejected: destination country blocked", map[string]interface{}{
+				"from":         req.From,
+				"to":           recipient,
+				"country_code": prefix,
+			})
+			writeCreateError(w, r, "10013", "Destination country not permitted", "[SmsSink] Destination country not permitted.", http.StatusForbidden)
+			return
+		}
+	}
 
 	// Generate UUID for message ID
 	messageID := uuid.New().String()
 
+	// With a number pool strategy, the sender is chosen from the profile's pool; req keeps what the
+	// client sent for the debug echo
+	from := req.From
+	if number := poolSender(req.MessagingProfileID); number != "" {
+		database.Log("message", "Sender selected from number pool", map[string]interface{}{
+			"message_id":     messageID,
+			"requested_from": req.From,
+			"from":           number,
+			"profile_id":     req.MessagingProfileID,
+		})
+		from = number
+	}
+
+	// With enforce_10dlc on, long codes must be registered to a campaign in the number pool
+	if unregistered10DLC(from) {
+		database.LogWarning("message", "Outbound message rejected: number not registered for 10DLC", map[string]interface{}{
+			"message_id": messageID,
+			"from":       from,
+			"to":         to,
+		})
+		writeCatalogError(w, r, "40010")
+		return
+	}
+
 	// Prepare media URLs
 	mediaURLs := req.MediaURLs
 	if mediaURLs == nil {
 		mediaURLs = []string{}
 	}
 
-	// Determine message type
+	// Apply the messaging profile's response defaults, if any
+	overrides := profileOverrides(req.MessagingProfileID)
+
+	// Determine message type (media always makes it MMS)
 	msgType := "SMS"
-	if len(mediaURLs) > 0 {
+	if overrides.Type != "" {
+		msgType = overrides.Type
+	}
+	if len(mediaURLs) > 0 || len(recipients) > 1 {
+		msgType = "MMS"
+	}
+	// Some accounts classify any message with a subject as MMS
+	if req.Subject != "" && database.GetBoolSetting("classify_mms_on_subject", false) {
 		msgType = "MMS"
 	}
 
+	encoding := "GSM-7"
+	if overrides.Encoding != "" {
+		encoding = overrides.Encoding
+	}
+
+	// A provider that doesn't concatenate cuts long text off; the cut text is what's stored and billed
+	text, truncated := truncateText(req.Text)
+	if truncated {
+		database.LogWarning("message", "Outbound message text truncated", map[string]interface{}{
+			"message_id":      messageID,
+			"original_length": len([]rune(req.Text)),
+			"length":          len([]rune(text)),
+		})
+	}
+
+	// With auto_detect, text outside the GSM-7 alphabet is sent as UCS-2, unless the profile fixes
+	// the encoding
+	if req.AutoDetect != nil && *req.AutoDetect && overrides.Encoding == "" {
+		encoding = sms.DetectEncoding(text)
+	}
+
+	parts := sms.CountParts(text, encoding)
+
+	// force_parts skips crafting long text for billing tests, but only on a debug instance
+	if req.ForceParts != nil {
+		if isDebugMode() {
+			database.Log("message", "Message parts forced", map[string]interface{}{
+				"message_id": messageID,
+				"parts":      *req.ForceParts,
+				"computed":   parts,
+			})
+			parts = *req.ForceParts
+		} else {
+			database.LogWarning("message", "Ignored force_parts: debug mode is off", map[string]interface{}{
+				"message_id": messageID,
+			})
+		}
+	}
+
+	cost := sms.EstimateCost(msgType, parts, database.GetBoolSetting("detailed_cost", false))
+
+	now := time.Now().UTC()
+	validUntil := now.Add(time.Duration(database.GetMessageValidityHours()) * time.Hour)
+
+	// Reject sends that would take the profile over its daily spend limit
+	spendOK, charged := chargeDailySpend(req.MessagingProfileID, cost, now)
+	if !spendOK {
+		database.LogWarning("message", "Outbound message rejected: daily spend limit exceeded", map[string]interface{}{
+			"message_id": messageID,
+			"profile_id": req.MessagingProfileID,
+			"cost":       cost.Amount,
+		})
+		writeCreateError(w, r, "10015", "Daily spend limit exceeded", "[SmsSink] Daily spend limit exceeded.", http.StatusForbidden)
+		return
+	}
+
 	// Insert into database
-	if err := database.InsertMessage(messageID, req.From, to, req.Text, mediaURLs, req.MessagingProfileID, "outbound"); err != nil {
+	opts := database.MessageOptions{
+		ValidUntil:         validUntil,
+		WebhookURL:         req.WebhookURL,
+		WebhookFailoverURL: req.WebhookFailoverURL,
+		WebhookURLs:        req.WebhookURLs,
+		Cost:               cost.Dollars(),
+		Encoding:           encoding,
+		Type:               msgType,
+		Parts:              parts,
+		Priority:           req.Priority,
+		WebhookDelayMs:     req.WebhookDelayMs,
+	}
+	if len(recipients) > 1 {
+		opts.Recipients = recipients
+	}
+	if err := database.InsertMessageWithOptions(messageID, from, to, text, mediaURLs, req.MessagingProfileID, "outbound", opts); err != nil {
 		database.LogError("message", "Failed to save outbound message to database", map[string]interface{}{
 			"error": err.Error(),
-			"from":  req.From,
+			"from":  from,
 			"to":    to,
 		})
-		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save message.", http.StatusInternalServerError)
+		// The message was never stored, so it mustn't count against the day's spend
+		if charged {
+			refundDailySpend(req.MessagingProfileID, cost, now)
+		}
+		writeCreateError(w, r, "10000", "Internal Server Error", "[SmsSink] Failed to save message.", http.StatusInternalServerError)
 		return
 	}
 
 	// Log successful outbound message
 	database.Log("message", "Outbound message sent successfully", map[string]interface{}{
 		"message_id": messageID,
-		"from":       req.From,
+		"from":       from,
 		"to":         to,
 		"type":       msgType,
-		"has_text":   req.Text != "",
+		"has_text":   text != "",
 		"media_count": len(mediaURLs),
 	})
 
-	now := time.Now().UTC()
+	queued := make([]database.MessageRecipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		queued = append(queued, database.MessageRecipient{PhoneNumber: recipient, Status: "queued"})
+	}
 
 	// Return Telnyx success response format
 	// Include all standard Telnyx response fields for API compatibility
 	// The 'to' field in responses is an array of recipient objects
 	data := map[string]interface{}{
 		"id":                   messageID,
-		"record_type":          "message",
+		"record_type":          database.GetResponseRecordType(),
 		"direction":            "outbound",
 		"messaging_profile_id": req.MessagingProfileID,
 		"from": map[string]interface{}{
-			"phone_number": req.From,
-			"carrier":      "",
-			"line_type":    "",
+			"phone_number":         from,
+			"carrier":              sms.MockCarrier,
+			"line_type":            sms.LineType(from),
+			"messaging_profile_id": req.MessagingProfileID,
 		},
-		"to": []map[string]interface{}{
-			{
-				"phone_number": to,
-				"status":       "queued",
-				"carrier":      "",
-				"line_type":    "",
-			},
-		},
-		"text":       req.Text,
+		"to":         recipientEntries(queued),
+		"text":       text,
 		"media":      mediaURLs, // Telnyx uses 'media' in responses
 		"type":       msgType,
-		"valid_until": now.Add(24 * time.Hour).Format(time.RFC3339),
+		"valid_until": validUntil.Format(time.RFC3339),
 		"webhook_url":          "",
 		"webhook_failover_url": "",
-		"encoding":             "GSM-7",
-		"parts":                1,
+		"encoding":             encoding,
+		"parts":                parts,
 		"tags":                 []string{},
-		"cost":                 nil,
+		"cost":                 cost,
 		"received_at":          nil,
 		"sent_at":              nil,
 		"completed_at":         nil,
@@ -152,6 +350,18 @@ func HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 		"updated_at":           now.Format(time.RFC3339),
 	}
 
+	if req.Subject != "" {
+		data["subject"] = req.Subject
+	}
+	if truncated {
+		data["truncated"] = true
+	}
+
+	// Named senders report the profile's display name for the sending number
+	if displayName := senderDisplayName(req.MessagingProfileID, from); displayName != "" {
+		data["from"].(map[string]interface{})["display_name"] = displayName
+	}
+
 	// Include webhook URLs if provided in request
 	if req.WebhookURL != "" {
 		data["webhook_url"] = req.WebhookURL
@@ -159,32 +369,127 @@ func HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 	if req.WebhookFailoverURL != "" {
 		data["webhook_failover_url"] = req.WebhookFailoverURL
 	}
+	if len(req.WebhookURLs) > 0 {
+		data["webhook_urls"] = req.WebhookURLs
+	}
 	if req.UseProfileWebhooks != nil {
 		data["use_profile_webhooks"] = *req.UseProfileWebhooks
 	}
+	if req.SkipWebhooks {
+		data["skip_webhooks"] = true
+	}
+	// Without auto_detect or a profile encoding, the encoding isn't really known; some consumers
+	// need to see it left out rather than defaulted
+	encodingKnown := overrides.Encoding != "" || (req.AutoDetect != nil && *req.AutoDetect)
+	if !encodingKnown && database.GetBoolSetting("omit_unknown_encoding", false) {
+		delete(data, "encoding")
+	}
+
+	// Inject account-specific extensions, with the profile's taking precedence over the global ones
+	for field, value := range database.GetResponseExtraFields() {
+		data[field] = value
+	}
+	for field, value := range overrides.ExtraFields {
+		data[field] = value
+	}
+
+	// Drop configured fields to reproduce client bugs that only surface when they're absent
+	for _, field := range database.GetStringListSetting("response_omit_fields") {
+		delete(data, field)
+	}
 
 	response := map[string]interface{}{
 		"data": data,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	// Some clients key on 201 Created, which comes with a Location for the new message
+	status := database.GetIntSetting("create_success_status", http.StatusOK)
 
-	// Send status callbacks asynchronously if webhook URL is provided
-	if req.WebhookURL != "" {
-		webhook.SendStatusCallbacks(webhook.MessageDetails{
-			ID:                 messageID,
-			From:               req.From,
-			To:                 to,
-			Text:               req.Text,
-			MediaURLs:          mediaURLs,
-			MessagingProfileID: req.MessagingProfileID,
-			Type:               msgType,
-			WebhookURL:         req.WebhookURL,
-			WebhookFailoverURL: req.WebhookFailoverURL,
+	if idempotencyKey != "" {
+		responseBytes, _ := json.Marshal(response)
+		if err := database.SaveIdempotencyKey(idempotencyKey, messageID, responseBytes, status); err != nil {
+			database.LogError("message", "Failed to save idempotency key", map[string]interface{}{
+				"error":           err.Error(),
+				"idempotency_key": idempotencyKey,
+			})
+		}
+	}
+
+	// Echo the parsed request back to help diagnose client serialization mismatches
+	if r.URL.Query().Get("echo") == "true" && isDebugMode() {
+		response["_debug"] = map[string]interface{}{
+			"received":     req,
+			"unrecognized": unrecognizedFields(bodyBytes),
+		}
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", "/v2/messages/"+messageID)
+	}
+	if wantsXML(r) {
+		writeXML(w, status, xmlMessageResponse{Data: newXMLMessage(data)})
+	} else {
+		writeJSON(w, status, response)
+	}
+
+	// Advance the status asynchronously; status webhooks are only sent when a webhook URL is provided
+	details := webhook.MessageDetails{
+		ID:                 messageID,
+		From:               from,
+		To:                 to,
+		Text:               text,
+		MediaURLs:          mediaURLs,
+		MessagingProfileID: req.MessagingProfileID,
+		Type:               msgType,
+		Parts:              parts,
+		Cost:               &cost,
+		WebhookURL:         req.WebhookURL,
+		WebhookFailoverURL: req.WebhookFailoverURL,
+		WebhookURLs:        req.WebhookURLs,
+		Headers:            req.WebhookHeaders,
+		Priority:           req.Priority,
+	}
+	if req.WebhookDelayMs != nil {
+		delay := time.Duration(*req.WebhookDelayMs) * time.Millisecond
+		details.Delay = &delay
+	}
+	// Skipping webhooks leaves the status progression intact for clients that poll
+	if req.SkipWebhooks {
+		details.WebhookURL, details.WebhookFailoverURL, details.WebhookURLs = "", "", nil
+	}
+	if len(recipients) > 1 {
+		details.Recipients = recipients
+	}
+	// The carrier rejects each matching group participant on its own; the message only fails
+	// when every participant is rejected
+	var rejected []string
+	for _, recipient := range recipients {
+		if carrierRejects(recipient, text) {
+			rejected = append(rejected, recipient)
+		}
+	}
+	if len(rejected) == len(recipients) {
+		details.RejectReason = &webhook.CarrierRejectedReason
+		database.LogWarning("message", "Message will be rejected by carrier", map[string]interface{}{
+			"message_id": messageID,
+			"to":         to,
+		})
+	} else if len(rejected) > 0 {
+		details.FailedRecipients = rejected
+		database.LogWarning("message", "Group message recipients will be rejected by carrier", map[string]interface{}{
+			"message_id": messageID,
+			"to":         rejected,
 		})
 	}
+	if req.ManualStatus {
+		var autoAdvance time.Duration
+		if req.AutoAdvanceAfterMs != nil {
+			autoAdvance = time.Duration(*req.AutoAdvanceAfterMs) * time.Millisecond
+		}
+		holdForManualStatus(details, autoAdvance)
+		return
+	}
+	webhook.SendStatusCallbacks(details)
 }
 
 // HandleListMessages handles GET /api/messages
@@ -194,14 +499,134 @@ func HandleListMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	messages, err := database.GetAllMessages()
+	query := r.URL.Query()
+	filter := database.MessageFilter{
+		Since:              parseTimeParam(query.Get("since")),
+		Until:              parseTimeParam(query.Get("until")),
+		Direction:          query.Get("direction"),
+		MessagingProfileID: query.Get("messaging_profile_id"),
+		IncludeDeleted:     query.Get("include_deleted") == "true",
+		Sort:               query.Get("sort"),
+		Order:              query.Get("order"),
+		Encoding:           query.Get("encoding"),
+	}
+	if filter.Encoding != "" && filter.Encoding != "GSM-7" && filter.Encoding != "UCS-2" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'encoding' parameter must be 'GSM-7' or 'UCS-2'.", http.StatusBadRequest)
+		return
+	}
+
+	// A seq cursor pages forward through new messages, oldest first
+	cursor := query.Get("after_seq")
+	if cursor != "" {
+		afterSeq, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || afterSeq < 0 {
+			validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'after_seq' parameter must be a non-negative integer.", http.StatusBadRequest)
+			return
+		}
+		filter.AfterSeq = afterSeq
+		if filter.Sort == "" {
+			filter.Sort, filter.Order = "seq", "asc"
+		}
+	}
+
+	if query.Get("stream") == "true" {
+		streamMessages(w, filter, includes(r, "messaging_profile"), cursor != "")
+		return
+	}
+
+	messages, err := database.GetMessages(filter)
 	if err != nil {
 		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve messages.", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(messages)
+	if cursor != "" {
+		maxSeq := filter.AfterSeq
+		for _, msg := range messages {
+			maxSeq = max(maxSeq, msg.Seq)
+		}
+		var data interface{} = messages
+		if includes(r, "messaging_profile") {
+			data = embedProfiles(messages)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": data, "max_seq": maxSeq})
+		return
+	}
+
+	if includes(r, "messaging_profile") {
+		writeJSON(w, http.StatusOK, embedProfiles(messages))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// HandleGetMessage handles GET /api/messages/{id}
+func HandleGetMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	msg, err := database.GetMessageByID(chi.URLParam(r, "id"))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve message.", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil || (msg.DeletedAt != nil && r.URL.Query().Get("include_deleted") != "true") {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Message not found.", http.StatusNotFound)
+		return
+	}
+
+	if includes(r, "messaging_profile") {
+		writeJSON(w, http.StatusOK, embedProfiles([]database.Message{*msg})[0])
+		return
+	}
+
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// HandleGetDeliveredPayload handles GET /api/messages/{id}/delivered-payload, returning the
+// message.delivered webhook built from the stored message the same way it is (or would be) sent
+func HandleGetDeliveredPayload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	msg, err := database.GetMessageByID(chi.URLParam(r, "id"))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve message.", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil || msg.DeletedAt != nil {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Message not found.", http.StatusNotFound)
+		return
+	}
+	if msg.Direction != "outbound" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Only outbound messages have a delivered webhook.", http.StatusBadRequest)
+		return
+	}
+
+	// Encoded like a delivery, so webhook_version and webhook_field_map apply
+	body, err := webhook.EncodePayload(webhook.BuildDeliveredPayload(messageDetailsFromRecord(*msg), msg.CreatedAt))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to encode webhook payload.", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, json.RawMessage(body))
+}
+
+// parseTimeParam parses an RFC 3339 query parameter, returning the zero time if it's empty or invalid
+func parseTimeParam(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
 }
 
 // HandleClearMessages handles DELETE /api/messages
@@ -215,9 +640,31 @@ func HandleClearMessages(w http.ResponseWriter, r *http.Request) {
 		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to clear messages.", http.StatusInternalServerError)
 		return
 	}
+	clearManualStatus()
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// HandleDeleteMessage handles DELETE /api/messages/{id}
+func HandleDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status": "success"}`))
+	id := chi.URLParam(r, "id")
+	deleted, err := database.DeleteMessageByID(id)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to delete message.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Message not found.", http.StatusNotFound)
+		return
+	}
+	forgetManualStatus(id)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
 // HandleGetCredentials handles GET /api/credentials
@@ -233,8 +680,7 @@ func HandleGetCredentials(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(cred)
+	writeJSON(w, http.StatusOK, cred)
 }
 
 // HandleSetCredentials handles POST /api/credentials
@@ -258,6 +704,11 @@ func HandleSetCredentials(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if minLength := database.GetIntSetting("min_api_key_length", 0); len(req.APIKey) < minLength {
+		validator.WriteError(w, "10005", "Invalid parameter", fmt.Sprintf("[SmsSink] The 'api_key' parameter must be at least %d characters.", minLength), http.StatusBadRequest)
+		return
+	}
+
 	if err := database.SetCredential(req.APIKey); err != nil {
 		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save credentials.", http.StatusInternalServerError)
 		return
@@ -269,16 +720,15 @@ func HandleSetCredentials(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(cred)
+	writeJSON(w, http.StatusOK, cred)
 }
 
 // InboundWebhookPayload represents the Telnyx webhook payload for inbound messages
 type InboundWebhookPayload struct {
 	Data struct {
-		EventType string `json:"event_type"`
-		Payload   struct {
+		EventType  string `json:"event_type"`
+		OccurredAt string `json:"occurred_at"`
+		Payload    struct {
 			ID                 string   `json:"id"`
 			From               string   `json:"from"`
 			To                 string   `json:"to"`
@@ -286,10 +736,26 @@ type InboundWebhookPayload struct {
 			MediaURLs          []string `json:"media_urls"`
 			MessagingProfileID string   `json:"messaging_profile_id"`
 			Direction          string   `json:"direction"`
+			ReceivedAt         string   `json:"received_at"`
+			Tags               []string `json:"tags"`
 		} `json:"payload"`
 	} `json:"data"`
 }
 
+// receivedAt returns the provider-reported time of the event, preferring the payload's received_at
+// over the envelope's occurred_at; zero if neither is present or parseable (insert time is used)
+func (p InboundWebhookPayload) receivedAt() time.Time {
+	for _, value := range []string{p.Data.Payload.ReceivedAt, p.Data.OccurredAt} {
+		if value == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
 // HandleInboundWebhook handles POST /v2/webhooks/messages (Telnyx webhook format)
 func HandleInboundWebhook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -297,6 +763,17 @@ func HandleInboundWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// inbound_fail_rate rejects a share of requests before anything is stored, so sources can
+	// test their retries
+	if rate := database.GetFloatSetting("inbound_fail_rate", 0); rate > 0 && simrand.Float64() < rate {
+		database.LogWarning("webhook", "Injected inbound webhook failure", map[string]interface{}{
+			"fail_rate": rate,
+			"ip":        r.RemoteAddr,
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Simulated inbound failure.", http.StatusInternalServerError)
+		return
+	}
+
 	// Read body once
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -326,7 +803,26 @@ func HandleInboundWebhook(w http.ResponseWriter, r *http.Request) {
 			mediaURLs = []string{}
 		}
 
-		if err := database.InsertMessage(messageID, from, to, text, mediaURLs, messagingProfileID, "inbound"); err != nil {
+		// Retried webhooks reuse the message ID; inbound_duplicate_mode decides what happens to them
+		duplicateMode := database.GetInboundDuplicateMode()
+		if duplicateMode == "ignore" {
+			if existing, _ := database.GetMessageByID(messageID); existing != nil {
+				database.Log("webhook", "Duplicate inbound message ignored", map[string]interface{}{
+					"message_id": messageID,
+					"from":       from,
+					"to":         to,
+				})
+				writeJSON(w, http.StatusOK, map[string]string{"status": "duplicate"})
+				return
+			}
+		}
+
+		opts := database.MessageOptions{
+			ReceivedAt: webhookPayload.receivedAt(),
+			Replace:    duplicateMode == "replace",
+			Tags:       webhookPayload.Data.Payload.Tags,
+		}
+		if err := database.InsertMessageWithOptions(messageID, from, to, text, mediaURLs, messagingProfileID, "inbound", opts); err != nil {
 			database.LogError("webhook", "Failed to save inbound webhook message", map[string]interface{}{
 				"error":      err.Error(),
 				"message_id": messageID,
@@ -345,8 +841,9 @@ func HandleInboundWebhook(w http.ResponseWriter, r *http.Request) {
 			"media_count": len(mediaURLs),
 		})
 
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status": "received"}`))
+		applyAutoReply(from, to, text, messagingProfileID)
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "received"})
 		return
 	}
 
@@ -401,8 +898,9 @@ func HandleInboundWebhook(w http.ResponseWriter, r *http.Request) {
 		"media_count": len(mediaURLs),
 	})
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status": "received"}`))
+	applyAutoReply(simpleReq.From, to, simpleReq.Text, messagingProfileID)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "received"})
 }
 
 // HandleSimulateInbound handles POST /api/messages/inbound (for UI simulation)
@@ -440,6 +938,10 @@ func HandleSimulateInbound(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Quick manual tests can leave the text out and get the configured placeholder
+	if req.Text == "" && len(req.MediaURLs) == 0 {
+		req.Text = database.GetDefaultInboundText()
+	}
 	if req.Text == "" && len(req.MediaURLs) == 0 {
 		database.LogError("message", "Missing text or media_urls in simulate inbound", map[string]interface{}{
 			"from": req.From,
@@ -474,6 +976,32 @@ func HandleSimulateInbound(w http.ResponseWriter, r *http.Request) {
 		"media_count": len(mediaURLs),
 	})
 
+	applyAutoReply(req.From, req.To, req.Text, messagingProfileID)
+
+	// Report the message to the inbound webhook, followed by a read receipt if they're enabled
+	if url := database.GetInboundWebhookURL(); url != "" {
+		var readDelay *time.Duration
+		if database.GetBoolSetting("inbound_read_receipts", false) {
+			delay := time.Duration(database.GetIntSetting("inbound_read_delay_ms", database.DefaultInboundReadDelayMs)) * time.Millisecond
+			readDelay = &delay
+		}
+		msgType := "SMS"
+		if len(mediaURLs) > 0 {
+			msgType = "MMS"
+		}
+		webhook.SendInboundCallbacks(webhook.MessageDetails{
+			ID:                 messageID,
+			From:               req.From,
+			To:                 req.To,
+			Text:               req.Text,
+			MediaURLs:          mediaURLs,
+			MessagingProfileID: messagingProfileID,
+			Type:               msgType,
+			Parts:              sms.CountParts(req.Text, "GSM-7"),
+			WebhookURL:         url,
+		}, readDelay)
+	}
+
 	response := map[string]interface{}{
 		"id":         messageID,
 		"from":       req.From,
@@ -484,9 +1012,12 @@ func HandleSimulateInbound(w http.ResponseWriter, r *http.Request) {
 		"created_at": time.Now().UTC().Format(time.RFC3339),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	// create_success_status applies here too, pointing Location at the UI API's copy of the message
+	status := database.GetIntSetting("create_success_status", http.StatusOK)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", "/api/messages/"+messageID)
+	}
+	writeJSON(w, status, response)
 }
 
 // HandleGetLogs handles GET /api/logs
@@ -497,8 +1028,7 @@ func HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse query parameters
-	level := r.URL.Query().Get("level")
-	category := r.URL.Query().Get("category")
+	filter := parseLogFilter(r)
 	limitStr := r.URL.Query().Get("limit")
 
 	limit := 100
@@ -508,14 +1038,77 @@ func HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	logs, err := database.GetLogs(level, category, limit)
+	// page[size] or page[number] switches to a paginated {data, meta} response
+	pageNumber, pageSize, paginated, errDetail := parsePage(r)
+	if errDetail != "" {
+		validator.WriteError(w, "10005", "Invalid parameter", errDetail, http.StatusBadRequest)
+		return
+	}
+	if paginated {
+		if r.URL.Query().Get("wait") == "true" {
+			validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Pagination can't be combined with 'wait'.", http.StatusBadRequest)
+			return
+		}
+		writeLogsPage(w, filter, pageNumber, pageSize)
+		return
+	}
+
+	var logs []database.LogEntry
+	var err error
+	if r.URL.Query().Get("wait") == "true" {
+		// Long-poll: block until a log newer than after_id arrives (returned oldest first)
+		afterID, _ := strconv.ParseInt(r.URL.Query().Get("after_id"), 10, 64)
+		timeout := logPollTimeout(r.URL.Query().Get("timeout"))
+		logs, err = waitForLogs(afterID, filter, limit, timeout, r.Context().Done())
+	} else {
+		logs, err = database.FilterLogs(filter, limit)
+	}
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve logs.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, logs)
+}
+
+// parseLogFilter reads the level, category, message_id, since and until query parameters shared
+// by the log endpoints
+func parseLogFilter(r *http.Request) database.LogFilter {
+	query := r.URL.Query()
+	return database.LogFilter{
+		Level:     query.Get("level"),
+		Category:  query.Get("category"),
+		MessageID: query.Get("message_id"),
+		Since:     parseTimeParam(query.Get("since")),
+		Until:     parseTimeParam(query.Get("until")),
+	}
+}
+
+// writeLogsPage writes one page of the logs matching the filter, with meta counting every match
+func writeLogsPage(w http.ResponseWriter, filter database.LogFilter, pageNumber, pageSize int) {
+	total, err := database.CountLogs(filter)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to count logs.", http.StatusInternalServerError)
+		return
+	}
+	logs, err := database.FilterLogsPage(filter, (pageNumber-1)*pageSize, pageSize)
 	if err != nil {
 		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve logs.", http.StatusInternalServerError)
 		return
 	}
+	if logs == nil {
+		logs = []database.LogEntry{}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": logs,
+		"meta": map[string]int{
+			"page_number":   pageNumber,
+			"page_size":     pageSize,
+			"total_pages":   (total + pageSize - 1) / pageSize,
+			"total_results": total,
+		},
+	})
 }
 
 // HandleClearLogs handles DELETE /api/logs
@@ -525,6 +1118,29 @@ func HandleClearLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// With ?before=, only trim logs older than the given time
+	if before := r.URL.Query().Get("before"); before != "" {
+		cutoff, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'before' parameter must be an RFC 3339 timestamp.", http.StatusBadRequest)
+			return
+		}
+
+		deleted, err := database.ClearLogsBefore(cutoff)
+		if err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to clear logs.", http.StatusInternalServerError)
+			return
+		}
+
+		database.Log("system", "Old logs cleared", map[string]interface{}{
+			"before":  cutoff.Format(time.RFC3339),
+			"deleted": deleted,
+		})
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+		return
+	}
+
 	if err := database.ClearAllLogs(); err != nil {
 		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to clear logs.", http.StatusInternalServerError)
 		return
@@ -532,8 +1148,7 @@ func HandleClearLogs(w http.ResponseWriter, r *http.Request) {
 
 	database.Log("system", "All logs cleared", nil)
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status": "success"}`))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
 // parseLimit safely parses a limit string to int
@@ -552,21 +1167,98 @@ func parseLimit(s string) (int, error) {
 	return limit, nil
 }
 
-// HandleGetSettings handles GET /api/settings
+// Bounds for the page[size] query parameter
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 250
+)
+
+// parsePage reads the page[number] and page[size] query parameters, reporting whether either was
+// supplied. errDetail is set when one is invalid.
+func parsePage(r *http.Request) (number, size int, ok bool, errDetail string) {
+	query := r.URL.Query()
+	number, size = 1, DefaultPageSize
+	if value := query.Get("page[number]"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, 0, false, "[SmsSink] The 'page[number]' parameter must be a positive integer."
+		}
+		number, ok = parsed, true
+	}
+	if value := query.Get("page[size]"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > MaxPageSize {
+			return 0, 0, false, fmt.Sprintf("[SmsSink] The 'page[size]' parameter must be between 1 and %d.", MaxPageSize)
+		}
+		size, ok = parsed, true
+	}
+	return number, size, ok, ""
+}
+
+// HandleGetSettings handles GET /api/settings
 func HandleGetSettings(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
 		return
 	}
 
-	debugMode := database.IsDebugMode()
+	writeJSON(w, http.StatusOK, currentSettings())
+}
 
-	response := map[string]interface{}{
-		"debug_mode": debugMode,
+// currentSettings returns all configurable settings with their effective values
+func currentSettings() map[string]interface{} {
+	pattern, token := database.GetCarrierRejectRules()
+	latency := database.GetLatencyConfig()
+	return map[string]interface{}{
+		"debug_mode":                 database.IsDebugMode(),
+		"message_validity_hours":     database.GetMessageValidityHours(),
+		"carrier_reject_pattern":     pattern,
+		"carrier_reject_token":       token,
+		"webhook_custom_headers":     database.GetWebhookCustomHeaders(),
+		"api_latency_mode":           latency.Mode,
+		"api_latency_ms":             latency.MeanMs,
+		"api_latency_stddev_ms":      latency.StddevMs,
+		"random_seed":                database.GetIntSetting("random_seed", 0),
+		"mms_max_media":              database.GetMMSMaxMedia(),
+		"webhook_version":            database.GetWebhookVersion(),
+		"webhook_events":             database.GetWebhookEvents(),
+		"api_rate_limit":             database.GetIntSetting("api_rate_limit", 0),
+		"number_pool_strategy":       database.GetNumberPoolStrategy(),
+		"classify_mms_on_subject":    database.GetBoolSetting("classify_mms_on_subject", false),
+		"detailed_cost":              database.GetBoolSetting("detailed_cost", false),
+		"out_of_order_delivery":      database.GetBoolSetting("out_of_order_delivery", false),
+		"webhook_http_method":        database.GetWebhookHTTPMethod(),
+		"soft_delete":                database.GetBoolSetting("soft_delete", false),
+		"max_logs":                   database.GetIntSetting("max_logs", 0),
+		"response_omit_fields":       database.GetStringListSetting("response_omit_fields"),
+		"webhook_initial_delay_ms":   database.GetIntSetting("webhook_initial_delay_ms", database.DefaultWebhookInitialDelayMs),
+		"inbound_rate_limit":         database.GetIntSetting("inbound_rate_limit", DefaultInboundRateLimit),
+		"response_extra_fields":      database.GetResponseExtraFields(),
+		"inbound_duplicate_mode":     database.GetInboundDuplicateMode(),
+		"retry_after_format":         database.GetRetryAfterFormat(),
+		"webhook_abort_percent":      database.GetIntSetting("webhook_abort_percent", 0),
+		"create_success_status":      database.GetIntSetting("create_success_status", http.StatusOK),
+		"mms_max_media_bytes":        database.GetIntSetting("mms_max_media_bytes", 0),
+		"media_cache_ttl_seconds":    database.GetIntSetting("media_cache_ttl_seconds", DefaultMediaCacheTTLSeconds),
+		"webhook_field_map":          database.GetWebhookFieldMap(),
+		"simulate_text_truncation":   database.GetBoolSetting("simulate_text_truncation", false),
+		"text_truncation_length":     database.GetIntSetting("text_truncation_length", DefaultTextTruncationLength),
+		"response_record_type":       database.GetResponseRecordType(),
+		"webhook_dump_dir":           database.GetWebhookDumpDir(),
+		"default_inbound_text":       database.GetDefaultInboundText(),
+		"min_api_key_length":         database.GetIntSetting("min_api_key_length", 0),
+		"old_api_key_grace_seconds":  database.GetIntSetting("old_api_key_grace_seconds", 0),
+		"enforce_10dlc":              database.GetBoolSetting("enforce_10dlc", false),
+		"max_concurrent_inbound":     database.GetIntSetting("max_concurrent_inbound", DefaultMaxConcurrentInbound),
+		"inbound_fail_rate":          database.GetFloatSetting("inbound_fail_rate", 0),
+		"slow_consumer_threshold_ms": database.GetIntSetting("slow_consumer_threshold_ms", 0),
+		"omit_unknown_encoding":      database.GetBoolSetting("omit_unknown_encoding", false),
+		"blocked_country_codes":      database.GetStringListSetting("blocked_country_codes"),
+		"webhook_duplicate_rate":     database.GetFloatSetting("webhook_duplicate_rate", 0),
+		"inbound_webhook_url":        database.GetInboundWebhookURL(),
+		"inbound_read_receipts":      database.GetBoolSetting("inbound_read_receipts", false),
+		"inbound_read_delay_ms":      database.GetIntSetting("inbound_read_delay_ms", database.DefaultInboundReadDelayMs),
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
 }
 
 // HandleSetSettings handles POST /api/settings
@@ -577,7 +1269,54 @@ func HandleSetSettings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		DebugMode *bool `json:"debug_mode"`
+		DebugMode               *bool                   `json:"debug_mode"`
+		MessageValidityHours    *int                    `json:"message_validity_hours"`
+		CarrierRejectPattern    *string                 `json:"carrier_reject_pattern"`
+		CarrierRejectToken      *string                 `json:"carrier_reject_token"`
+		WebhookCustomHeaders    *map[string]string      `json:"webhook_custom_headers"`
+		APILatencyMode          *string                 `json:"api_latency_mode"`
+		APILatencyMs            *int                    `json:"api_latency_ms"`
+		APILatencyStddevMs      *int                    `json:"api_latency_stddev_ms"`
+		RandomSeed              *int64                  `json:"random_seed"`
+		MMSMaxMedia             *int                    `json:"mms_max_media"`
+		WebhookVersion          *string                 `json:"webhook_version"`
+		WebhookEvents           *[]string               `json:"webhook_events"`
+		APIRateLimit            *int                    `json:"api_rate_limit"`
+		NumberPoolStrategy      *string                 `json:"number_pool_strategy"`
+		ClassifyMMSOnSubject    *bool                   `json:"classify_mms_on_subject"`
+		DetailedCost            *bool                   `json:"detailed_cost"`
+		OutOfOrderDelivery      *bool                   `json:"out_of_order_delivery"`
+		WebhookHTTPMethod       *string                 `json:"webhook_http_method"`
+		SoftDelete              *bool                   `json:"soft_delete"`
+		MaxLogs                 *int                    `json:"max_logs"`
+		ResponseOmitFields      *[]string               `json:"response_omit_fields"`
+		WebhookInitialDelayMs   *int                    `json:"webhook_initial_delay_ms"`
+		InboundRateLimit        *int                    `json:"inbound_rate_limit"`
+		ResponseExtraFields     *map[string]interface{} `json:"response_extra_fields"`
+		InboundDuplicateMode    *string                 `json:"inbound_duplicate_mode"`
+		RetryAfterFormat        *string                 `json:"retry_after_format"`
+		WebhookAbortPercent     *int                    `json:"webhook_abort_percent"`
+		CreateSuccessStatus     *int                    `json:"create_success_status"`
+		MMSMaxMediaBytes        *int                    `json:"mms_max_media_bytes"`
+		MediaCacheTTLSeconds    *int                    `json:"media_cache_ttl_seconds"`
+		WebhookFieldMap         *map[string]string      `json:"webhook_field_map"`
+		SimulateTextTruncation  *bool                   `json:"simulate_text_truncation"`
+		TextTruncationLength    *int                    `json:"text_truncation_length"`
+		ResponseRecordType      *string                 `json:"response_record_type"`
+		WebhookDumpDir          *string                 `json:"webhook_dump_dir"`
+		DefaultInboundText      *string                 `json:"default_inbound_text"`
+		MinAPIKeyLength         *int                    `json:"min_api_key_length"`
+		OldAPIKeyGraceSeconds   *int                    `json:"old_api_key_grace_seconds"`
+		Enforce10DLC            *bool                   `json:"enforce_10dlc"`
+		MaxConcurrentInbound    *int                    `json:"max_concurrent_inbound"`
+		InboundFailRate         *float64                `json:"inbound_fail_rate"`
+		SlowConsumerThresholdMs *int                    `json:"slow_consumer_threshold_ms"`
+		OmitUnknownEncoding     *bool                   `json:"omit_unknown_encoding"`
+		BlockedCountryCodes     *[]string               `json:"blocked_country_codes"`
+		WebhookDuplicateRate    *float64                `json:"webhook_duplicate_rate"`
+		InboundWebhookURL       *string                 `json:"inbound_webhook_url"`
+		InboundReadReceipts     *bool                   `json:"inbound_read_receipts"`
+		InboundReadDelayMs      *int                    `json:"inbound_read_delay_ms"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -585,26 +1324,515 @@ func HandleSetSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Validate every value before saving any, so a bad value doesn't leave a partial update
+	if req.MessageValidityHours != nil && (*req.MessageValidityHours < 1 || *req.MessageValidityHours > 168) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'message_validity_hours' setting must be between 1 and 168.", http.StatusBadRequest)
+		return
+	}
+	if req.CarrierRejectPattern != nil {
+		if _, err := regexp.Compile(*req.CarrierRejectPattern); err != nil {
+			validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'carrier_reject_pattern' setting must be a valid regular expression.", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.WebhookCustomHeaders != nil {
+		for name := range *req.WebhookCustomHeaders {
+			if !validator.ValidHeaderName(name) {
+				validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_custom_headers' setting contains an invalid header name: '"+name+"'.", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if req.APILatencyMode != nil && *req.APILatencyMode != "fixed" && *req.APILatencyMode != "normal" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'api_latency_mode' setting must be 'fixed' or 'normal'.", http.StatusBadRequest)
+		return
+	}
+	// Latency past the API write timeout would drop the connection instead of replying late
+	maxLatencyMs := maxLatencySettingMs()
+	if req.APILatencyMs != nil && (*req.APILatencyMs < 0 || *req.APILatencyMs > maxLatencyMs) {
+		validator.WriteError(w, "10005", "Invalid parameter", fmt.Sprintf("[SmsSink] The 'api_latency_ms' setting must be between 0 and %d, leaving time to reply within the API write timeout.", maxLatencyMs), http.StatusBadRequest)
+		return
+	}
+	if req.APILatencyStddevMs != nil && (*req.APILatencyStddevMs < 0 || *req.APILatencyStddevMs > maxLatencyMs) {
+		validator.WriteError(w, "10005", "Invalid parameter", fmt.Sprintf("[SmsSink] The 'api_latency_stddev_ms' setting must be between 0 and %d, leaving time to reply within the API write timeout.", maxLatencyMs), http.StatusBadRequest)
+		return
+	}
+	if req.MMSMaxMedia != nil && (*req.MMSMaxMedia < 1 || *req.MMSMaxMedia > 50) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'mms_max_media' setting must be between 1 and 50.", http.StatusBadRequest)
+		return
+	}
+	if req.WebhookVersion != nil && *req.WebhookVersion != "v2" && *req.WebhookVersion != "v1" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_version' setting must be 'v2' or 'v1'.", http.StatusBadRequest)
+		return
+	}
+	if req.WebhookEvents != nil {
+		for _, eventType := range *req.WebhookEvents {
+			if !slices.Contains(webhook.EventTypes, eventType) {
+				validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_events' setting contains an unknown event type: '"+eventType+"'.", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if req.APIRateLimit != nil && (*req.APIRateLimit < 0 || *req.APIRateLimit > 100000) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'api_rate_limit' setting must be between 0 and 100000.", http.StatusBadRequest)
+		return
+	}
+	if req.NumberPoolStrategy != nil && *req.NumberPoolStrategy != "none" && *req.NumberPoolStrategy != "round_robin" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'number_pool_strategy' setting must be 'none' or 'round_robin'.", http.StatusBadRequest)
+		return
+	}
+	if req.WebhookHTTPMethod != nil && *req.WebhookHTTPMethod != http.MethodPost && *req.WebhookHTTPMethod != http.MethodPut {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_http_method' setting must be 'POST' or 'PUT'.", http.StatusBadRequest)
+		return
+	}
+	if req.MaxLogs != nil && *req.MaxLogs < 0 {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'max_logs' setting must be 0 (unlimited) or greater.", http.StatusBadRequest)
+		return
+	}
+	if req.ResponseOmitFields != nil {
+		for _, field := range *req.ResponseOmitFields {
+			if !slices.Contains(omittableResponseFields, field) {
+				validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'response_omit_fields' setting contains an unknown field: '"+field+"'.", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if req.WebhookInitialDelayMs != nil && (*req.WebhookInitialDelayMs < 0 || *req.WebhookInitialDelayMs > 60000) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_initial_delay_ms' setting must be between 0 and 60000.", http.StatusBadRequest)
+		return
+	}
+	if req.InboundRateLimit != nil && (*req.InboundRateLimit < 0 || *req.InboundRateLimit > 100000) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'inbound_rate_limit' setting must be between 0 and 100000.", http.StatusBadRequest)
+		return
+	}
+	if req.ResponseExtraFields != nil {
+		if field := protectedField(*req.ResponseExtraFields); field != "" {
+			validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'response_extra_fields' setting cannot overwrite the protected field '"+field+"'.", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.InboundDuplicateMode != nil && *req.InboundDuplicateMode != "error" && *req.InboundDuplicateMode != "ignore" && *req.InboundDuplicateMode != "replace" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'inbound_duplicate_mode' setting must be 'error', 'ignore' or 'replace'.", http.StatusBadRequest)
+		return
+	}
+	if req.RetryAfterFormat != nil && *req.RetryAfterFormat != "seconds" && *req.RetryAfterFormat != "http-date" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'retry_after_format' setting must be 'seconds' or 'http-date'.", http.StatusBadRequest)
+		return
+	}
+	if req.WebhookAbortPercent != nil && (*req.WebhookAbortPercent < 0 || *req.WebhookAbortPercent > 100) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_abort_percent' setting must be between 0 and 100.", http.StatusBadRequest)
+		return
+	}
+	if req.CreateSuccessStatus != nil && *req.CreateSuccessStatus != http.StatusOK && *req.CreateSuccessStatus != http.StatusCreated {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'create_success_status' setting must be 200 or 201.", http.StatusBadRequest)
+		return
+	}
+	if req.MMSMaxMediaBytes != nil && *req.MMSMaxMediaBytes < 0 {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'mms_max_media_bytes' setting must not be negative.", http.StatusBadRequest)
+		return
+	}
+	if req.MediaCacheTTLSeconds != nil && (*req.MediaCacheTTLSeconds < 0 || *req.MediaCacheTTLSeconds > 3600) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'media_cache_ttl_seconds' setting must be between 0 and 3600.", http.StatusBadRequest)
+		return
+	}
+	if req.WebhookFieldMap != nil {
+		if err := webhook.ValidateFieldMap(*req.WebhookFieldMap); err != nil {
+			validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_field_map' setting is invalid: "+err.Error()+".", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.TextTruncationLength != nil && (*req.TextTruncationLength < 1 || *req.TextTruncationLength > 10000) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'text_truncation_length' setting must be between 1 and 10000.", http.StatusBadRequest)
+		return
+	}
+	if req.ResponseRecordType != nil && *req.ResponseRecordType == "" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'response_record_type' setting must not be empty.", http.StatusBadRequest)
+		return
+	}
+	if req.MinAPIKeyLength != nil && (*req.MinAPIKeyLength < 0 || *req.MinAPIKeyLength > 256) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'min_api_key_length' setting must be between 0 and 256.", http.StatusBadRequest)
+		return
+	}
+	if req.OldAPIKeyGraceSeconds != nil && (*req.OldAPIKeyGraceSeconds < 0 || *req.OldAPIKeyGraceSeconds > 86400) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'old_api_key_grace_seconds' setting must be between 0 and 86400.", http.StatusBadRequest)
+		return
+	}
+	if req.MaxConcurrentInbound != nil && (*req.MaxConcurrentInbound < 0 || *req.MaxConcurrentInbound > 100000) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'max_concurrent_inbound' setting must be between 0 and 100000.", http.StatusBadRequest)
+		return
+	}
+	if req.InboundFailRate != nil && (*req.InboundFailRate < 0 || *req.InboundFailRate > 1) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'inbound_fail_rate' setting must be between 0 and 1.", http.StatusBadRequest)
+		return
+	}
+	if req.SlowConsumerThresholdMs != nil && (*req.SlowConsumerThresholdMs < 0 || *req.SlowConsumerThresholdMs > 5000) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'slow_consumer_threshold_ms' setting must be between 0 and 5000.", http.StatusBadRequest)
+		return
+	}
+	if req.BlockedCountryCodes != nil {
+		for _, prefix := range *req.BlockedCountryCodes {
+			if !isCountryCodePrefix(prefix) {
+				validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'blocked_country_codes' setting contains an invalid country code: '"+prefix+"'. Use E.164 prefixes like '+44'.", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if req.WebhookDuplicateRate != nil && (*req.WebhookDuplicateRate < 0 || *req.WebhookDuplicateRate > 1) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'webhook_duplicate_rate' setting must be between 0 and 1.", http.StatusBadRequest)
+		return
+	}
+	if req.InboundReadDelayMs != nil && (*req.InboundReadDelayMs < 0 || *req.InboundReadDelayMs > 60000) {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'inbound_read_delay_ms' setting must be between 0 and 60000.", http.StatusBadRequest)
+		return
+	}
+
 	if req.DebugMode != nil {
 		value := "false"
 		if *req.DebugMode {
 			value = "true"
 		}
-		if err := database.SetSetting("debug_mode", value); err != nil {
+		if err := database.SetSettingAudited("debug_mode", value); err != nil {
 			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
 			return
 		}
-		database.Log("system", "Debug mode changed", map[string]interface{}{
-			"debug_mode": *req.DebugMode,
-		})
 	}
 
-	// Return updated settings
-	debugMode := database.IsDebugMode()
-	response := map[string]interface{}{
-		"debug_mode": debugMode,
+	if req.MessageValidityHours != nil {
+		if err := database.SetSettingAudited("message_validity_hours", strconv.Itoa(*req.MessageValidityHours)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.CarrierRejectPattern != nil {
+		if err := database.SetSettingAudited("carrier_reject_pattern", *req.CarrierRejectPattern); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.CarrierRejectToken != nil {
+		if err := database.SetSettingAudited("carrier_reject_token", *req.CarrierRejectToken); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookCustomHeaders != nil {
+		headersJSON, _ := json.Marshal(*req.WebhookCustomHeaders)
+		if err := database.SetSettingAudited("webhook_custom_headers", string(headersJSON)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.APILatencyMode != nil {
+		if err := database.SetSettingAudited("api_latency_mode", *req.APILatencyMode); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.APILatencyMs != nil {
+		if err := database.SetSettingAudited("api_latency_ms", strconv.Itoa(*req.APILatencyMs)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.APILatencyStddevMs != nil {
+		if err := database.SetSettingAudited("api_latency_stddev_ms", strconv.Itoa(*req.APILatencyStddevMs)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.RandomSeed != nil {
+		if err := database.SetSettingAudited("random_seed", strconv.FormatInt(*req.RandomSeed, 10)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+		simrand.Seed(*req.RandomSeed)
+	}
+
+	if req.MMSMaxMedia != nil {
+		if err := database.SetSettingAudited("mms_max_media", strconv.Itoa(*req.MMSMaxMedia)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookVersion != nil {
+		if err := database.SetSettingAudited("webhook_version", *req.WebhookVersion); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookEvents != nil {
+		eventsJSON, _ := json.Marshal(*req.WebhookEvents)
+		if err := database.SetSettingAudited("webhook_events", string(eventsJSON)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.APIRateLimit != nil {
+		if err := database.SetSettingAudited("api_rate_limit", strconv.Itoa(*req.APIRateLimit)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.NumberPoolStrategy != nil {
+		if err := database.SetSettingAudited("number_pool_strategy", *req.NumberPoolStrategy); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.ClassifyMMSOnSubject != nil {
+		if err := database.SetSettingAudited("classify_mms_on_subject", strconv.FormatBool(*req.ClassifyMMSOnSubject)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.DetailedCost != nil {
+		if err := database.SetSettingAudited("detailed_cost", strconv.FormatBool(*req.DetailedCost)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if req.OutOfOrderDelivery != nil {
+		if err := database.SetSettingAudited("out_of_order_delivery", strconv.FormatBool(*req.OutOfOrderDelivery)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookHTTPMethod != nil {
+		if err := database.SetSettingAudited("webhook_http_method", *req.WebhookHTTPMethod); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.SoftDelete != nil {
+		if err := database.SetSettingAudited("soft_delete", strconv.FormatBool(*req.SoftDelete)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.MaxLogs != nil {
+		if err := database.SetSettingAudited("max_logs", strconv.Itoa(*req.MaxLogs)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.ResponseOmitFields != nil {
+		omitJSON, _ := json.Marshal(*req.ResponseOmitFields)
+		if err := database.SetSettingAudited("response_omit_fields", string(omitJSON)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookInitialDelayMs != nil {
+		if err := database.SetSettingAudited("webhook_initial_delay_ms", strconv.Itoa(*req.WebhookInitialDelayMs)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.InboundRateLimit != nil {
+		if err := database.SetSettingAudited("inbound_rate_limit", strconv.Itoa(*req.InboundRateLimit)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.ResponseExtraFields != nil {
+		extraJSON, _ := json.Marshal(*req.ResponseExtraFields)
+		if err := database.SetSettingAudited("response_extra_fields", string(extraJSON)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.InboundDuplicateMode != nil {
+		if err := database.SetSettingAudited("inbound_duplicate_mode", *req.InboundDuplicateMode); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.RetryAfterFormat != nil {
+		if err := database.SetSettingAudited("retry_after_format", *req.RetryAfterFormat); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookAbortPercent != nil {
+		if err := database.SetSettingAudited("webhook_abort_percent", strconv.Itoa(*req.WebhookAbortPercent)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.CreateSuccessStatus != nil {
+		if err := database.SetSettingAudited("create_success_status", strconv.Itoa(*req.CreateSuccessStatus)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.MMSMaxMediaBytes != nil {
+		if err := database.SetSettingAudited("mms_max_media_bytes", strconv.Itoa(*req.MMSMaxMediaBytes)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.MediaCacheTTLSeconds != nil {
+		if err := database.SetSettingAudited("media_cache_ttl_seconds", strconv.Itoa(*req.MediaCacheTTLSeconds)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookFieldMap != nil {
+		fieldMapJSON, _ := json.Marshal(*req.WebhookFieldMap)
+		if err := database.SetSettingAudited("webhook_field_map", string(fieldMapJSON)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.SimulateTextTruncation != nil {
+		if err := database.SetSettingAudited("simulate_text_truncation", strconv.FormatBool(*req.SimulateTextTruncation)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.TextTruncationLength != nil {
+		if err := database.SetSettingAudited("text_truncation_length", strconv.Itoa(*req.TextTruncationLength)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.ResponseRecordType != nil {
+		if err := database.SetSettingAudited("response_record_type", *req.ResponseRecordType); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookDumpDir != nil {
+		if err := database.SetSettingAudited("webhook_dump_dir", *req.WebhookDumpDir); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.DefaultInboundText != nil {
+		if err := database.SetSettingAudited("default_inbound_text", *req.DefaultInboundText); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.MinAPIKeyLength != nil {
+		if err := database.SetSettingAudited("min_api_key_length", strconv.Itoa(*req.MinAPIKeyLength)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.OldAPIKeyGraceSeconds != nil {
+		if err := database.SetSettingAudited("old_api_key_grace_seconds", strconv.Itoa(*req.OldAPIKeyGraceSeconds)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.Enforce10DLC != nil {
+		if err := database.SetSettingAudited("enforce_10dlc", strconv.FormatBool(*req.Enforce10DLC)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.MaxConcurrentInbound != nil {
+		if err := database.SetSettingAudited("max_concurrent_inbound", strconv.Itoa(*req.MaxConcurrentInbound)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.InboundFailRate != nil {
+		if err := database.SetSettingAudited("inbound_fail_rate", strconv.FormatFloat(*req.InboundFailRate, 'f', -1, 64)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.SlowConsumerThresholdMs != nil {
+		if err := database.SetSettingAudited("slow_consumer_threshold_ms", strconv.Itoa(*req.SlowConsumerThresholdMs)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.OmitUnknownEncoding != nil {
+		if err := database.SetSettingAudited("omit_unknown_encoding", strconv.FormatBool(*req.OmitUnknownEncoding)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.BlockedCountryCodes != nil {
+		blockedJSON, _ := json.Marshal(*req.BlockedCountryCodes)
+		if err := database.SetSettingAudited("blocked_country_codes", string(blockedJSON)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.WebhookDuplicateRate != nil {
+		if err := database.SetSettingAudited("webhook_duplicate_rate", strconv.FormatFloat(*req.WebhookDuplicateRate, 'f', -1, 64)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.InboundWebhookURL != nil {
+		if err := database.SetSettingAudited("inbound_webhook_url", *req.InboundWebhookURL); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.InboundReadReceipts != nil {
+		if err := database.SetSettingAudited("inbound_read_receipts", strconv.FormatBool(*req.InboundReadReceipts)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.InboundReadDelayMs != nil {
+		if err := database.SetSettingAudited("inbound_read_delay_ms", strconv.Itoa(*req.InboundReadDelayMs)); err != nil {
+			validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Return updated settings
+	writeJSON(w, http.StatusOK, currentSettings())
 }
@@ -2,28 +2,79 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/simrand"
+	"telnyx-mock/internal/sms"
+	"telnyx-mock/internal/webhook"
 )
 
 func setupTestDB(t *testing.T) func() {
-	testDBPath := "test_handlers.db"
+	// A fresh path per test, so webhook goroutines outliving a test can't touch the next test's file
+	testDBPath := filepath.Join(t.TempDir(), "test_handlers.db")
 	err := database.InitDB(testDBPath)
 	if err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
 
 	return func() {
+		// Webhook goroutines read the global DB, so they must finish before the next test swaps it
+		clearManualStatus()
+		webhook.CancelPending()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := webhook.Drain(ctx); err != nil {
+			t.Errorf("Webhook goroutines still running after the test: %v", err)
+		}
 		database.CloseDB()
 		os.Remove(testDBPath)
 	}
 }
 
+// createTestMessage sends a valid outbound message and returns the response data object
+func createTestMessage(t *testing.T, profileID string) map[string]interface{} {
+	t.Helper()
+
+	return sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": profileID,
+	})
+}
+
+// sendTestMessage posts body to HandleCreateMessage and returns the response's data object
+func sendTestMessage(t *testing.T, body map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	return response["data"].(map[string]interface{})
+}
+
 func TestHandleCreateMessage_Success(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
@@ -464,6 +515,135 @@ func TestHandleListMessages_Empty(t *testing.T) {
 	}
 }
 
+func TestHandleListMessages_Filters(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	database.InsertMessageWithOptions("old-out", "+111", "+222", "old", []string{}, "profile-1", "outbound", database.MessageOptions{CreatedAt: base.Add(-2 * time.Hour)})
+	database.InsertMessageWithOptions("in-window-out", "+111", "+222", "a", []string{}, "profile-1", "outbound", database.MessageOptions{CreatedAt: base})
+	database.InsertMessageWithOptions("in-window-in", "+222", "+111", "b", []string{}, "profile-1", "inbound", database.MessageOptions{CreatedAt: base.Add(time.Minute)})
+	database.InsertMessageWithOptions("in-window-other", "+111", "+333", "c", []string{}, "profile-2", "outbound", database.MessageOptions{CreatedAt: base.Add(2 * time.Minute)})
+	database.InsertMessageWithOptions("new-out", "+111", "+222", "new", []string{}, "profile-1", "outbound", database.MessageOptions{CreatedAt: base.Add(2 * time.Hour)})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"since=2024-01-01T11:30:00Z&until=2024-01-01T12:30:00Z", []string{"in-window-other", "in-window-in", "in-window-out"}},
+		{"since=2024-01-01T11:30:00Z&until=2024-01-01T12:30:00Z&direction=outbound", []string{"in-window-other", "in-window-out"}},
+		{"since=2024-01-01T11:30:00Z&until=2024-01-01T12:30:00Z&direction=outbound&messaging_profile_id=profile-1", []string{"in-window-out"}},
+		{"since=2024-01-01T13:00:00Z", []string{"new-out"}},
+		// Invalid timestamps are ignored
+		{"since=yesterday&until=not-a-time&messaging_profile_id=profile-2", []string{"in-window-other"}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/messages?"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		HandleListMessages(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", tt.query, http.StatusOK, rr.Code)
+		}
+
+		var messages []database.Message
+		json.Unmarshal(rr.Body.Bytes(), &messages)
+
+		ids := []string{}
+		for _, msg := range messages {
+			ids = append(ids, msg.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, ids)
+		}
+	}
+}
+
+func TestHandleListMessages_Encoding(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.UpsertProfile(database.MessagingProfile{
+		ID:                "profile-ucs2",
+		Name:              "Unicode account",
+		ResponseOverrides: database.ResponseOverrides{Encoding: "UCS-2"},
+	})
+	gsm := createTestMessage(t, "profile-1")["id"].(string)
+	ucs := createTestMessage(t, "profile-ucs2")["id"].(string)
+	database.InsertMessage("inbound-1", "+222", "+111", "in", []string{}, "profile-1", "inbound")
+
+	list := func(query string) []string {
+		rr := httptest.NewRecorder()
+		HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages?"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", query, http.StatusOK, rr.Code)
+		}
+		var messages []database.Message
+		json.Unmarshal(rr.Body.Bytes(), &messages)
+		ids := []string{}
+		for _, msg := range messages {
+			ids = append(ids, msg.ID)
+		}
+		return ids
+	}
+
+	if ids := list("encoding=UCS-2"); len(ids) != 1 || ids[0] != ucs {
+		t.Errorf("Expected only the UCS-2 message, got %v", ids)
+	}
+	if ids := list("encoding=GSM-7"); len(ids) != 1 || ids[0] != gsm {
+		t.Errorf("Expected only the GSM-7 message, got %v", ids)
+	}
+	if ids := list(""); len(ids) != 3 {
+		t.Errorf("Expected all 3 messages without a filter, got %v", ids)
+	}
+
+	msg, _ := database.GetMessageByID(ucs)
+	if msg.Encoding != "UCS-2" {
+		t.Errorf("Expected the stored encoding 'UCS-2', got %q", msg.Encoding)
+	}
+
+	rr := httptest.NewRecorder()
+	HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages?encoding=UTF-8", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown encoding, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleListMessages_FromLineType(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created := sendTestMessage(t, map[string]interface{}{
+		"from":                 "12345",
+		"to":                   "+15557654321",
+		"text":                 "Your code is 1234",
+		"messaging_profile_id": "profile-1",
+	})
+
+	rr := httptest.NewRecorder()
+	HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages", nil))
+	var messages []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &messages)
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+
+	from, ok := messages[0]["from"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a from object, got %v", messages[0]["from"])
+	}
+	if from["phone_number"] != "12345" || from["line_type"] != "Short Code" || from["carrier"] != "SmsSink Mock Carrier" {
+		t.Errorf("Unexpected from %v", from)
+	}
+
+	// The list agrees with the create response
+	createdFrom := created["from"].(map[string]interface{})
+	if createdFrom["line_type"] != from["line_type"] || createdFrom["carrier"] != from["carrier"] {
+		t.Errorf("Create response from %v disagrees with list from %v", createdFrom, from)
+	}
+}
+
 func TestHandleClearMessages(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
@@ -487,6 +667,159 @@ func TestHandleClearMessages(t *testing.T) {
 	}
 }
 
+func TestHandleClearLogs(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.Log("system", "entry one", nil)
+	database.Log("system", "entry two", nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/logs", nil)
+	rr := httptest.NewRecorder()
+	HandleClearLogs(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	logs, _ := database.GetLogs("", "system", 10)
+	for _, l := range logs {
+		if l.Message == "entry one" || l.Message == "entry two" {
+			t.Errorf("Expected '%s' to be cleared", l.Message)
+		}
+	}
+}
+
+func TestHandleGetLogs_MessageID(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.Log("message", "Message created", map[string]interface{}{"message_id": "msg-a"})
+	database.Log("message", "Message created", map[string]interface{}{"message_id": "msg-b"})
+	database.Log("webhook", "Webhook sent successfully", map[string]interface{}{"message_id": "msg-a", "url": "https://example.com"})
+	database.Log("system", "No details", nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/logs?message_id=msg-a", nil)
+	rr := httptest.NewRecorder()
+	HandleGetLogs(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var logs []database.LogEntry
+	json.Unmarshal(rr.Body.Bytes(), &logs)
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 logs for msg-a, got %d: %+v", len(logs), logs)
+	}
+	for _, l := range logs {
+		if !strings.Contains(l.Details, `"message_id":"msg-a"`) {
+			t.Errorf("Expected only msg-a entries, got %s", l.Details)
+		}
+	}
+
+	// Combines with the other filters
+	req = httptest.NewRequest(http.MethodGet, "/api/logs?message_id=msg-a&category=webhook", nil)
+	rr = httptest.NewRecorder()
+	HandleGetLogs(rr, req)
+	json.Unmarshal(rr.Body.Bytes(), &logs)
+	if len(logs) != 1 || logs[0].Category != "webhook" {
+		t.Errorf("Expected only the webhook entry for msg-a, got %+v", logs)
+	}
+}
+
+func TestHandleGetLogs_Paginated(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for i := 0; i < 45; i++ {
+		database.Log("paging", fmt.Sprintf("entry %d", i), nil)
+	}
+	database.LogWarning("paging", "warning entry", nil)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		HandleGetLogs(rr, httptest.NewRequest(http.MethodGet, "/api/logs?"+query, nil))
+		return rr
+	}
+	type page struct {
+		Data []database.LogEntry `json:"data"`
+		Meta struct {
+			PageNumber   int `json:"page_number"`
+			PageSize     int `json:"page_size"`
+			TotalPages   int `json:"total_pages"`
+			TotalResults int `json:"total_results"`
+		} `json:"meta"`
+	}
+
+	rr := get("category=paging&level=info&page[size]=20&page[number]=3")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var last page
+	json.Unmarshal(rr.Body.Bytes(), &last)
+	if last.Meta.PageNumber != 3 || last.Meta.PageSize != 20 || last.Meta.TotalPages != 3 || last.Meta.TotalResults != 45 {
+		t.Errorf("Unexpected meta: %+v", last.Meta)
+	}
+	if len(last.Data) != 5 || last.Data[4].Message != "entry 0" {
+		t.Errorf("Expected the 5 oldest entries on the last page, got %+v", last.Data)
+	}
+
+	// page[number] alone uses the default size, and pages past the end are empty
+	var beyond page
+	rr = get("category=paging&page[number]=4")
+	json.Unmarshal(rr.Body.Bytes(), &beyond)
+	if beyond.Meta.PageSize != DefaultPageSize || beyond.Meta.TotalResults != 46 || beyond.Meta.TotalPages != 3 || beyond.Data == nil || len(beyond.Data) != 0 {
+		t.Errorf("Expected an empty fourth page of 46 results, got %s", rr.Body.String())
+	}
+
+	for _, query := range []string{"page[size]=0", "page[size]=251", "page[number]=0", "page[number]=x", "page[size]=5&wait=true"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, query, rr.Code)
+		}
+	}
+
+	// The legacy limit parameter still returns a bare array
+	var logs []database.LogEntry
+	if err := json.Unmarshal(get("category=paging&limit=10").Body.Bytes(), &logs); err != nil || len(logs) != 10 {
+		t.Errorf("Expected a bare array of 10 logs, got %d (%v)", len(logs), err)
+	}
+}
+
+func TestHandleClearLogs_Before(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.Log("system", "kept entry", nil)
+
+	// A cutoff in the past leaves the entry alone
+	before := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	req := httptest.NewRequest(http.MethodDelete, "/api/logs?before="+before, nil)
+	rr := httptest.NewRecorder()
+	HandleClearLogs(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	logs, _ := database.GetLogs("", "", 10)
+	found := false
+	for _, l := range logs {
+		if l.Message == "kept entry" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected logs newer than the cutoff to be kept")
+	}
+
+	// Invalid timestamps are rejected rather than deleting everything
+	req = httptest.NewRequest(http.MethodDelete, "/api/logs?before=yesterday", nil)
+	rr = httptest.NewRecorder()
+	HandleClearLogs(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid 'before', got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
 func TestHandleGetCredentials(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
@@ -531,6 +864,35 @@ func TestHandleSetCredentials(t *testing.T) {
 	}
 }
 
+func TestHandleSetCredentials_MinLength(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	setKey := func(key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		HandleSetCredentials(rr, httptest.NewRequest(http.MethodPost, "/api/credentials", strings.NewReader(`{"api_key": "`+key+`"}`)))
+		return rr
+	}
+
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"min_api_key_length": 16}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = setKey("short-key")
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"10005"`) {
+		t.Errorf("Expected a 10005 rejection for a short key, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if cred, _ := database.GetCredential(); cred.APIKey != "test-token" {
+		t.Errorf("Expected the rejected key not to be saved, got '%s'", cred.APIKey)
+	}
+
+	if rr := setKey("a-long-enough-api-key"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a long enough key, got %d", http.StatusOK, rr.Code)
+	}
+}
+
 func TestHandleInboundWebhook_SimpleFormat(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
@@ -562,6 +924,160 @@ func TestHandleInboundWebhook_SimpleFormat(t *testing.T) {
 	}
 }
 
+func TestHandleInboundWebhook_ReceivedAt(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	post := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		HandleInboundWebhook(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+
+	post(`{"data": {"event_type": "message.received", "occurred_at": "2024-01-01T11:00:00Z",
+		"payload": {"id": "with-received", "from": "+1234567890", "to": "+0987654321", "text": "hi", "received_at": "2024-01-01T12:00:00.5Z"}}}`)
+	post(`{"data": {"event_type": "message.received", "occurred_at": "2024-01-01T11:00:00Z",
+		"payload": {"id": "with-occurred", "from": "+1234567890", "to": "+0987654321", "text": "hi"}}}`)
+	post(`{"data": {"event_type": "message.received",
+		"payload": {"id": "with-neither", "from": "+1234567890", "to": "+0987654321", "text": "hi"}}}`)
+
+	expected := map[string]time.Time{
+		"with-received": time.Date(2024, 1, 1, 12, 0, 0, 500000000, time.UTC),
+		"with-occurred": time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+	}
+	for id, want := range expected {
+		msg, _ := database.GetMessageByID(id)
+		if msg == nil {
+			t.Fatalf("Expected message %s to be stored", id)
+		}
+		if !msg.ReceivedAt.Equal(want) {
+			t.Errorf("%s: expected received_at %v, got %v", id, want, msg.ReceivedAt)
+		}
+	}
+
+	msg, _ := database.GetMessageByID("with-neither")
+	if msg == nil || !msg.ReceivedAt.Equal(msg.CreatedAt) {
+		t.Errorf("Expected received_at to fall back to created_at, got %+v", msg)
+	}
+}
+
+func TestHandleInboundWebhook_Tags(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, body := range []string{
+		`{"data": {"event_type": "message.received",
+			"payload": {"id": "tagged", "from": "+1234567890", "to": "+0987654321", "text": "hi", "tags": ["vip", "campaign-7"]}}}`,
+		`{"data": {"event_type": "message.received",
+			"payload": {"id": "untagged", "from": "+1234567890", "to": "+0987654321", "text": "hi"}}}`,
+	} {
+		rr := httptest.NewRecorder()
+		HandleInboundWebhook(rr, httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", strings.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+
+	msg, _ := database.GetMessageByID("tagged")
+	if msg == nil || strings.Join(msg.Tags, ",") != "vip,campaign-7" {
+		t.Errorf("Expected tags [vip campaign-7] to be stored, got %+v", msg)
+	}
+	msg, _ = database.GetMessageByID("untagged")
+	if msg == nil || msg.Tags != nil {
+		t.Errorf("Expected no tags, got %+v", msg)
+	}
+
+	// Tags are surfaced on the message through the API
+	router := chi.NewRouter()
+	router.Get("/api/messages/{id}", HandleGetMessage)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/messages/tagged", nil))
+	if !strings.Contains(rr.Body.String(), `"tags":["vip","campaign-7"]`) {
+		t.Errorf("Expected tags in the message response, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleInboundWebhook_FailRate(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// With seed 6 the first draw (0.36) fails at a 0.5 rate and the second (0.84) doesn't
+	database.SetSetting("inbound_fail_rate", "0.5")
+	simrand.Seed(6)
+
+	send := func() *httptest.ResponseRecorder {
+		body := `{"from": "+1234567890", "to": "+0987654321", "text": "Inbound message"}`
+		rr := httptest.NewRecorder()
+		HandleInboundWebhook(rr, httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := send(); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusInternalServerError, rr.Code, rr.Body.String())
+	}
+	if messages, _ := database.GetAllMessages(); len(messages) != 0 {
+		t.Fatalf("Expected the failed request not to be stored, got %d messages", len(messages))
+	}
+	logs, _ := database.FilterLogs(database.LogFilter{Level: "warning", Category: "webhook"}, 10)
+	if len(logs) != 1 || logs[0].Message != "Injected inbound webhook failure" {
+		t.Errorf("Expected the injected failure to be logged, got %+v", logs)
+	}
+
+	// The source's retry gets through
+	if rr := send(); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d on retry, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if messages, _ := database.GetAllMessages(); len(messages) != 1 {
+		t.Errorf("Expected the retried request to be stored, got %d messages", len(messages))
+	}
+}
+
+func TestHandleInboundWebhook_DuplicateIDs(t *testing.T) {
+	post := func(text string) *httptest.ResponseRecorder {
+		body := `{"data": {"event_type": "message.received", "payload": {"id": "dup-1", "from": "+1234567890", "to": "+0987654321", "text": "` + text + `"}}}`
+		rr := httptest.NewRecorder()
+		HandleInboundWebhook(rr, httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", strings.NewReader(body)))
+		return rr
+	}
+
+	tests := []struct {
+		mode       string
+		wantStatus int
+		wantText   string
+	}{
+		{"error", http.StatusInternalServerError, "first"},
+		{"ignore", http.StatusOK, "first"},
+		{"replace", http.StatusOK, "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cleanup := setupTestDB(t)
+			defer cleanup()
+
+			database.SetSetting("inbound_duplicate_mode", tt.mode)
+			if rr := post("first"); rr.Code != http.StatusOK {
+				t.Fatalf("Expected the first delivery to succeed, got %d", rr.Code)
+			}
+
+			if rr := post("second"); rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d for the duplicate, got %d. Body: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			messages, _ := database.GetAllMessages()
+			if len(messages) != 1 {
+				t.Fatalf("Expected exactly 1 stored message, got %d", len(messages))
+			}
+			if messages[0].Content != tt.wantText {
+				t.Errorf("Expected stored text '%s', got '%s'", tt.wantText, messages[0].Content)
+			}
+		})
+	}
+}
+
 func TestHandleSimulateInbound(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
@@ -591,31 +1107,1611 @@ func TestHandleSimulateInbound(t *testing.T) {
 	}
 }
 
-func TestMethodNotAllowed(t *testing.T) {
+func TestHandleSimulateInbound_DefaultText(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
 
-	tests := []struct {
-		handler func(http.ResponseWriter, *http.Request)
-		method  string
-		path    string
-	}{
-		{HandleCreateMessage, http.MethodGet, "/v2/messages"},
-		{HandleListMessages, http.MethodPost, "/api/messages"},
-		{HandleClearMessages, http.MethodGet, "/api/messages"},
-		{HandleGetCredentials, http.MethodPost, "/api/credentials"},
-		{HandleSetCredentials, http.MethodGet, "/api/credentials"},
-		{HandleInboundWebhook, http.MethodGet, "/v2/webhooks/messages"},
-		{HandleSimulateInbound, http.MethodGet, "/api/messages/inbound"},
+	simulate := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		HandleSimulateInbound(rr, httptest.NewRequest(http.MethodPost, "/api/messages/inbound", strings.NewReader(`{"from": "+1234567890", "to": "+0987654321"}`)))
+		return rr
 	}
 
-	for _, tc := range tests {
-		req := httptest.NewRequest(tc.method, tc.path, nil)
-		rr := httptest.NewRecorder()
-		tc.handler(rr, req)
+	if rr := simulate(); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without text or a default, got %d", http.StatusBadRequest, rr.Code)
+	}
 
-		if rr.Code != http.StatusMethodNotAllowed {
-			t.Errorf("%s %s: Expected status %d, got %d", tc.method, tc.path, http.StatusMethodNotAllowed, rr.Code)
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"default_inbound_text": "Placeholder"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = simulate()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["text"] != "Placeholder" {
+		t.Errorf("Expected the default text in the response, got %v", response["text"])
+	}
+	msg, err := database.GetMessageByID(response["id"].(string))
+	if err != nil || msg == nil || msg.Content != "Placeholder" {
+		t.Errorf("Expected the default text to be stored, got %+v (%v)", msg, err)
+	}
+}
+
+func TestHandleSimulateInbound_CreateSuccessStatus(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("create_success_status", "201")
+
+	rr := httptest.NewRecorder()
+	HandleSimulateInbound(rr, httptest.NewRequest(http.MethodPost, "/api/messages/inbound", strings.NewReader(`{"from": "+1234567890", "to": "+0987654321", "text": "Hi"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if want := "/api/messages/" + response["id"].(string); rr.Header().Get("Location") != want {
+		t.Errorf("Expected Location '%s', got '%s'", want, rr.Header().Get("Location"))
+	}
+}
+
+func TestHandleSimulateInbound_ReadReceipts(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var events []webhook.TelnyxWebhookPayload
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		events = append(events, payload)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer consumer.Close()
+
+	database.SetSetting("inbound_webhook_url", consumer.URL)
+	database.SetSetting("inbound_read_receipts", "true")
+	database.SetSetting("inbound_read_delay_ms", "50")
+
+	rr := httptest.NewRecorder()
+	HandleSimulateInbound(rr, httptest.NewRequest(http.MethodPost, "/api/messages/inbound", strings.NewReader(`{"from": "+1234567890", "to": "+0987654321", "text": "Read me"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || events[0].Data.EventType != "message.received" || events[1].Data.EventType != "message.read" {
+		types := []string{}
+		for _, event := range events {
+			types = append(types, event.Data.EventType)
+		}
+		t.Fatalf("Expected message.received then message.read, got %v", types)
+	}
+	for _, event := range events {
+		if event.Data.Payload["id"] != response["id"] || event.Data.Payload["direction"] != "inbound" {
+			t.Errorf("Unexpected %s payload %v", event.Data.EventType, event.Data.Payload)
+		}
+	}
+	if _, ok := events[1].Data.Payload["read_at"]; !ok {
+		t.Error("Expected read_at in the message.read payload")
+	}
+}
+
+func TestHandleSimulateInbound_WebhookEvents(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	received := make(chan string, 4)
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload.Data.EventType
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer consumer.Close()
+
+	// Inbound events are valid webhook_events entries and pass the filter like outbound ones
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"webhook_events": ["message.delivered", "message.read"]}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	database.SetSetting("inbound_webhook_url", consumer.URL)
+	database.SetSetting("inbound_read_receipts", "true")
+	database.SetSetting("inbound_read_delay_ms", "0")
+
+	rr = httptest.NewRecorder()
+	HandleSimulateInbound(rr, httptest.NewRequest(http.MethodPost, "/api/messages/inbound", strings.NewReader(`{"from": "+1234567890", "to": "+0987654321", "text": "Hi"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	select {
+	case eventType := <-received:
+		if eventType != "message.read" {
+			t.Errorf("Expected only 'message.read', got '%s'", eventType)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for message.read")
+	}
+	select {
+	case eventType := <-received:
+		t.Errorf("Expected no further webhooks, got '%s'", eventType)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tests := []struct {
+		handler func(http.ResponseWriter, *http.Request)
+		method  string
+		path    string
+	}{
+		{HandleCreateMessage, http.MethodGet, "/v2/messages"},
+		{HandleListMessages, http.MethodPost, "/api/messages"},
+		{HandleClearMessages, http.MethodGet, "/api/messages"},
+		{HandleGetCredentials, http.MethodPost, "/api/credentials"},
+		{HandleSetCredentials, http.MethodGet, "/api/credentials"},
+		{HandleInboundWebhook, http.MethodGet, "/v2/webhooks/messages"},
+		{HandleSimulateInbound, http.MethodGet, "/api/messages/inbound"},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rr := httptest.NewRecorder()
+		tc.handler(rr, req)
+
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: Expected status %d, got %d", tc.method, tc.path, http.StatusMethodNotAllowed, rr.Code)
+		}
+	}
+}
+
+func TestHandleCreateMessage_ValidUntilWindow(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := map[string]interface{}{"message_validity_hours": 2}
+	bodyBytes, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader(bodyBytes))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	data := createTestMessage(t, "profile-123")
+
+	createdAt, err := time.Parse(time.RFC3339, data["created_at"].(string))
+	if err != nil {
+		t.Fatalf("Failed to parse created_at: %v", err)
+	}
+	validUntil, err := time.Parse(time.RFC3339, data["valid_until"].(string))
+	if err != nil {
+		t.Fatalf("Failed to parse valid_until: %v", err)
+	}
+
+	if got := validUntil.Sub(createdAt); got != 2*time.Hour {
+		t.Errorf("Expected valid_until to be 2h after created_at, got %v", got)
+	}
+}
+
+func TestHandleSetSettings_MessageValidityHoursRange(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, hours := range []int{0, 169} {
+		bodyBytes, _ := json.Marshal(map[string]interface{}{"message_validity_hours": hours})
+		req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader(bodyBytes))
+		rr := httptest.NewRecorder()
+		HandleSetSettings(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("message_validity_hours=%d: expected status %d, got %d", hours, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	if got := database.GetMessageValidityHours(); got != database.DefaultMessageValidityHours {
+		t.Errorf("Expected default validity of %d hours to be kept, got %d", database.DefaultMessageValidityHours, got)
+	}
+}
+
+func TestHandleSetSettings_AuditLog(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("message_validity_hours", "24")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"message_validity_hours": 48}`))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	logs, _ := database.FilterLogs(database.LogFilter{Level: "info", Category: "system"}, 10)
+	if len(logs) != 1 || logs[0].Message != "Setting changed" {
+		t.Fatalf("Expected one 'Setting changed' entry, got %+v", logs)
+	}
+	var details map[string]interface{}
+	json.Unmarshal([]byte(logs[0].Details), &details)
+	if details["key"] != "message_validity_hours" || details["old_value"] != "24" || details["new_value"] != "48" {
+		t.Errorf("Unexpected audit details %v", details)
+	}
+}
+
+func TestWebhookCustomHeaders_SettingAndPerMessage(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader([]byte(`{"webhook_custom_headers": {"X-Tenant-Id": "global", "X-Env": "test"}}`)))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	headers := make(chan http.Header, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	body := map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          server.URL,
+		"webhook_headers":      map[string]string{"X-Tenant-Id": "acme"},
+	}
+	bodyBytes, _ := json.Marshal(body)
+	req = httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr = httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	select {
+	case h := <-headers:
+		if h.Get("X-Tenant-Id") != "acme" {
+			t.Errorf("Expected per-message X-Tenant-Id 'acme', got '%s'", h.Get("X-Tenant-Id"))
+		}
+		if h.Get("X-Env") != "test" {
+			t.Errorf("Expected global X-Env 'test', got '%s'", h.Get("X-Env"))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for webhook")
+	}
+
+	// Wait for the remaining status callback so it doesn't outlive the test database
+	<-headers
+}
+
+func TestHandleSetSettings_InvalidWebhookHeaderName(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader([]byte(`{"webhook_custom_headers": {"Bad Header": "x"}}`)))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestWebhookEvents_OnlyDelivered(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader([]byte(`{"webhook_events": ["message.delivered"]}`)))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	received := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload["data"]["event_type"].(string)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	body := map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          server.URL,
+	}
+	bodyBytes, _ := json.Marshal(body)
+	req = httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr = httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+
+	select {
+	case eventType := <-received:
+		if eventType != "message.delivered" {
+			t.Errorf("Expected only 'message.delivered', got '%s'", eventType)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for delivered webhook")
+	}
+
+	select {
+	case eventType := <-received:
+		t.Errorf("Expected no further webhooks, got '%s'", eventType)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestHandleSetSettings_UnknownWebhookEvent(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader([]byte(`{"webhook_events": ["message.exploded"]}`)))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleCreateMessage_ClassifyMMSOnSubject(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	send := func() map[string]interface{} {
+		body := map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 "Hello",
+			"subject":              "Weekly update",
+			"messaging_profile_id": "profile-1",
+		}
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response["data"].(map[string]interface{})
+	}
+
+	if data := send(); data["type"] != "SMS" {
+		t.Errorf("Expected type 'SMS' with the setting off, got '%v'", data["type"])
+	}
+
+	database.SetSetting("classify_mms_on_subject", "true")
+	data := send()
+	if data["type"] != "MMS" {
+		t.Errorf("Expected subject-only message classified 'MMS', got '%v'", data["type"])
+	}
+	if data["subject"] != "Weekly update" {
+		t.Errorf("Expected subject echoed in response, got '%v'", data["subject"])
+	}
+
+	// The stored message keeps its classification, so it's reported and billed the same later
+	router := chi.NewRouter()
+	router.Get("/v2/messages/{id}", HandleRetrieveMessage)
+	req := httptest.NewRequest(http.MethodGet, "/v2/messages/"+data["id"].(string), nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var retrieved struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &retrieved)
+	if retrieved.Data["type"] != "MMS" {
+		t.Errorf("Expected the retrieved message to be 'MMS', got '%v'", retrieved.Data["type"])
+	}
+	if fmt.Sprint(retrieved.Data["cost"]) != fmt.Sprint(data["cost"]) {
+		t.Errorf("Expected the retrieved cost %v to match the create response %v", retrieved.Data["cost"], data["cost"])
+	}
+}
+
+func TestHandleCreateMessage_DetailedCost(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 strings.Repeat("a", 400),
+		"messaging_profile_id": "profile-1",
+	}
+
+	data := sendTestMessage(t, body)
+	if data["parts"] != float64(3) {
+		t.Errorf("Expected 3 parts, got %v", data["parts"])
+	}
+	cost := data["cost"].(map[string]interface{})
+	if cost["amount"] != "0.0120" || cost["currency"] != "USD" {
+		t.Errorf("Expected cost 0.0120 USD, got %v", cost)
+	}
+	if _, ok := cost["breakdown"]; ok {
+		t.Errorf("Expected no breakdown by default, got %v", cost["breakdown"])
+	}
+
+	database.SetSetting("detailed_cost", "true")
+	data = sendTestMessage(t, body)
+	breakdown, ok := data["cost"].(map[string]interface{})["breakdown"].([]interface{})
+	if !ok || len(breakdown) != 3 {
+		t.Fatalf("Expected 3 breakdown entries, got %v", data["cost"])
+	}
+	last := breakdown[2].(map[string]interface{})
+	if last["part"] != float64(3) || last["amount"] != "0.0040" {
+		t.Errorf("Unexpected breakdown entry: %v", last)
+	}
+}
+
+func TestWebhookHTTPMethod(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	methods := make(chan string, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods <- r.Method
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"webhook_http_method": "PATCH"}`))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unsupported method, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"webhook_http_method": "PUT"}`))
+	rr = httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+	})
+
+	select {
+	case method := <-methods:
+		if method != http.MethodPut {
+			t.Errorf("Expected webhook delivered with PUT, got %s", method)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for webhook")
+	}
+}
+
+func TestHandleCreateMessage_WebhookDelayOverride(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	type arrival struct {
+		text string
+		at   time.Time
+	}
+	arrivals := make(chan arrival, 8)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		data := payload["data"].(map[string]interface{})
+		if data["event_type"] == "message.sent" {
+			text := data["payload"].(map[string]interface{})["text"].(string)
+			arrivals <- arrival{text, time.Now()}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	start := time.Now()
+	for _, m := range []struct {
+		text  string
+		delay int
+	}{{"slow", 1500}, {"fast", 0}} {
+		sendTestMessage(t, map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 m.text,
+			"messaging_profile_id": "profile-1",
+			"webhook_url":          receiver.URL,
+			"webhook_delay_ms":     m.delay,
+		})
+	}
+
+	got := map[string]time.Duration{}
+	for len(got) < 2 {
+		select {
+		case a := <-arrivals:
+			got[a.text] = a.at.Sub(start)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for webhooks, got %v", got)
+		}
+	}
+	if got["fast"] >= 400*time.Millisecond {
+		t.Errorf("Expected the 0ms message's webhook almost immediately, got %v", got["fast"])
+	}
+	if got["slow"] < 1500*time.Millisecond {
+		t.Errorf("Expected the 1500ms message's webhook after at least 1.5s, got %v", got["slow"])
+	}
+}
+
+func TestHandleCreateMessage_WebhookDelayOutOfRange(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-1",
+		"webhook_delay_ms":     -1,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestHandleDeleteMessage_SoftDelete(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	router := chi.NewRouter()
+	router.Delete("/api/messages/{id}", HandleDeleteMessage)
+
+	database.SetSetting("soft_delete", "true")
+	database.InsertMessage("msg-1", "+111", "+222", "test", []string{}, "profile-1", "outbound")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/messages/msg-1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	list := func(query string) []map[string]interface{} {
+		rr := httptest.NewRecorder()
+		HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages"+query, nil))
+		var messages []map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &messages)
+		return messages
+	}
+	if messages := list(""); len(messages) != 0 {
+		t.Errorf("Expected soft-deleted message hidden by default, got %d", len(messages))
+	}
+	messages := list("?include_deleted=true")
+	if len(messages) != 1 || messages[0]["deleted_at"] == nil {
+		t.Errorf("Expected soft-deleted message with deleted_at, got %v", messages)
+	}
+
+	// Deleting it again reports not found
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/messages/msg-1", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHandleCreateMessage_ResponseOmitFields(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"response_omit_fields": ["cost", "bogus"]}`))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unknown field, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"response_omit_fields": ["cost", "tags"]}`))
+	rr = httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	data := createTestMessage(t, "profile-1")
+	for _, field := range []string{"cost", "tags"} {
+		if _, ok := data[field]; ok {
+			t.Errorf("Expected '%s' to be omitted from the response", field)
+		}
+	}
+	if _, ok := data["parts"]; !ok {
+		t.Error("Expected fields not listed to remain in the response")
+	}
+}
+
+func TestHandleCreateMessage_ResponseExtraFields(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"response_extra_fields": {"id": "hijacked"}}`))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a protected field, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"response_extra_fields": {"account_tier": "gold", "region": "us"}}`))
+	rr = httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	database.UpsertProfile(database.MessagingProfile{
+		ID:                "profile-eu",
+		ResponseOverrides: database.ResponseOverrides{ExtraFields: map[string]interface{}{"region": "eu"}},
+	})
+
+	data := createTestMessage(t, "profile-1")
+	if data["account_tier"] != "gold" || data["region"] != "us" {
+		t.Errorf("Expected the global extra fields in the response, got account_tier=%v region=%v", data["account_tier"], data["region"])
+	}
+
+	data = createTestMessage(t, "profile-eu")
+	if data["account_tier"] != "gold" || data["region"] != "eu" {
+		t.Errorf("Expected the profile's extra fields to take precedence, got account_tier=%v region=%v", data["account_tier"], data["region"])
+	}
+}
+
+func TestHandleListMessages_Sort(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	base := time.Now().UTC().Add(-time.Hour)
+	for i, sender := range []string{"+300", "+100", "+200"} {
+		database.InsertMessageWithOptions(fmt.Sprintf("msg-%d", i), sender, "+999", "test", []string{}, "profile-1", "outbound",
+			database.MessageOptions{CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	list := func(query string) []string {
+		rr := httptest.NewRecorder()
+		HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages"+query, nil))
+		var messages []map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &messages)
+		ids := []string{}
+		for _, m := range messages {
+			ids = append(ids, m["id"].(string))
+		}
+		return ids
+	}
+
+	if got := list("?sort=created_at&order=asc"); strings.Join(got, ",") != "msg-0,msg-1,msg-2" {
+		t.Errorf("Expected ascending created_at order, got %v", got)
+	}
+	if got := list("?sort=sender&order=asc"); strings.Join(got, ",") != "msg-1,msg-2,msg-0" {
+		t.Errorf("Expected ascending sender order, got %v", got)
+	}
+	// Unknown columns fall back to the default created_at DESC rather than reaching the query
+	if got := list("?sort=content;DROP%20TABLE%20messages"); strings.Join(got, ",") != "msg-2,msg-1,msg-0" {
+		t.Errorf("Expected default order for an invalid sort column, got %v", got)
+	}
+}
+
+func TestWebhookInitialDelay(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	arrivals := make(chan time.Time, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrivals <- time.Now()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	database.SetSetting("webhook_initial_delay_ms", "1200")
+
+	start := time.Now()
+	sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+	})
+
+	select {
+	case at := <-arrivals:
+		if waited := at.Sub(start); waited < 1200*time.Millisecond {
+			t.Errorf("Expected the first webhook after at least 1.2s, got %v", waited)
+		}
+	case <-time.After(4 * time.Second):
+		t.Fatal("Timeout waiting for webhook")
+	}
+}
+
+func TestHandleCreateMessage_PriorityDeliversFirst(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// The normal message is due to be sent at 300ms, before the high-priority one's events at 400ms
+	// and 800ms, but it's held until the high-priority message has none left
+	database.SetSetting("webhook_initial_delay_ms", "300")
+
+	var mu sync.Mutex
+	order := []string{}
+	done := make(chan struct{}, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		data := payload["data"].(map[string]interface{})
+		text := data["payload"].(map[string]interface{})["text"].(string)
+		mu.Lock()
+		order = append(order, text+"."+strings.TrimPrefix(data["event_type"].(string), "message."))
+		mu.Unlock()
+		done <- struct{}{}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "normal",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+	})
+	sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "high",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"priority":             "high",
+		"webhook_delay_ms":     400,
+	})
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for webhooks, got %v", order)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"high.sent", "high.delivered", "normal.sent", "normal.delivered"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected event order %v, got %v", want, order)
+	}
+}
+
+func TestHandleGetDeliveredPayload_MatchesWebhook(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	delivered := make(chan map[string]interface{}, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if data := payload["data"].(map[string]interface{}); data["event_type"] == "message.delivered" {
+			delivered <- data["payload"].(map[string]interface{})
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Diff me",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+	})
+	id := data["id"].(string)
+
+	var sent map[string]interface{}
+	select {
+	case sent = <-delivered:
+	case <-time.After(4 * time.Second):
+		t.Fatal("Timeout waiting for message.delivered webhook")
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/messages/{id}/delivered-payload", HandleGetDeliveredPayload)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/messages/"+id+"/delivered-payload", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	responseData := response["data"].(map[string]interface{})
+	if responseData["event_type"] != "message.delivered" {
+		t.Errorf("Expected event_type 'message.delivered', got %v", responseData["event_type"])
+	}
+	built := responseData["payload"].(map[string]interface{})
+
+	// Timestamps come from the stored created_at rather than the delivery clock
+	for _, field := range []string{"sent_at", "completed_at"} {
+		if built[field] == nil {
+			t.Errorf("Expected %s in the built payload", field)
+		}
+		delete(built, field)
+		delete(sent, field)
+	}
+	builtJSON, _ := json.Marshal(built)
+	sentJSON, _ := json.Marshal(sent)
+	if string(builtJSON) != string(sentJSON) {
+		t.Errorf("Built payload differs from the delivered webhook:\nbuilt: %s\nsent:  %s", builtJSON, sentJSON)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/messages/missing/delivered-payload", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown message, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHandleGetDeliveredPayload_KeepsSendTiming(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("webhook_initial_delay_ms", "4000")
+
+	router := chi.NewRouter()
+	router.Get("/api/messages/{id}/delivered-payload", HandleGetDeliveredPayload)
+
+	for _, tc := range []struct {
+		name           string
+		extra          map[string]interface{}
+		sent, complete time.Duration
+	}{
+		// Priority orders delivery without changing a message's own timing
+		{"high priority", map[string]interface{}{"priority": "high"}, 4 * time.Second, 5500 * time.Millisecond},
+		// A per-message delay replaces the timing: 1.5s before each event
+		{"webhook delay", map[string]interface{}{"webhook_delay_ms": 1500}, 1500 * time.Millisecond, 3 * time.Second},
+	} {
+		body := map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 "Timed",
+			"messaging_profile_id": "profile-1",
+		}
+		for field, value := range tc.extra {
+			body[field] = value
+		}
+		id := sendTestMessage(t, body)["id"].(string)
+		msg, _ := database.GetMessageByID(id)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/messages/"+id+"/delivered-payload", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d. Body: %s", tc.name, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var response webhook.TelnyxWebhookPayload
+		json.Unmarshal(rr.Body.Bytes(), &response)
+
+		if want := msg.CreatedAt.Add(tc.sent).UTC().Format(time.RFC3339); response.Data.Payload["sent_at"] != want {
+			t.Errorf("%s: expected sent_at %s, got %v", tc.name, want, response.Data.Payload["sent_at"])
+		}
+		if want := msg.CreatedAt.Add(tc.complete).UTC().Format(time.RFC3339); response.Data.Payload["completed_at"] != want {
+			t.Errorf("%s: expected completed_at %s, got %v", tc.name, want, response.Data.Payload["completed_at"])
+		}
+	}
+}
+
+func TestHandleGetDeliveredPayload_EncodedLikeWebhook(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("webhook_version", "v1")
+	database.SetSetting("webhook_field_map", `{"id": "message_id"}`)
+
+	id := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Legacy",
+		"messaging_profile_id": "profile-1",
+	})["id"].(string)
+
+	router := chi.NewRouter()
+	router.Get("/api/messages/{id}/delivered-payload", HandleGetDeliveredPayload)
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/messages/"+id+"/delivered-payload", nil))
+		return rr
+	}
+
+	rr := get()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var payload map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &payload)
+	if payload["event_type"] != "message.delivered" || payload["data"] != nil {
+		t.Errorf("Expected the flat v1 shape, got %s", rr.Body.String())
+	}
+	if payload["message_id"] != id || payload["id"] != nil {
+		t.Errorf("Expected 'id' renamed to 'message_id', got %s", rr.Body.String())
+	}
+
+	// A soft-deleted message is gone as far as the API is concerned
+	database.SetSetting("soft_delete", "true")
+	database.DeleteMessageByID(id)
+	if rr := get(); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a soft-deleted message, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHandleListMessages_AfterSeqCursor(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	poll := func(afterSeq int64) ([]string, int64) {
+		rr := httptest.NewRecorder()
+		HandleListMessages(rr, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/messages?after_seq=%d", afterSeq), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var response struct {
+			Data   []database.Message `json:"data"`
+			MaxSeq int64              `json:"max_seq"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		ids := []string{}
+		for _, msg := range response.Data {
+			ids = append(ids, msg.ID)
+		}
+		return ids, response.MaxSeq
+	}
+
+	database.InsertMessage("a", "+111", "+222", "a", nil, "profile-1", "outbound")
+	database.InsertMessage("b", "+111", "+222", "b", nil, "profile-1", "inbound")
+
+	ids, cursor := poll(0)
+	if strings.Join(ids, ",") != "a,b" {
+		t.Fatalf("Expected [a b] oldest first, got %v", ids)
+	}
+
+	// Nothing new: the cursor stays put
+	ids, next := poll(cursor)
+	if len(ids) != 0 || next != cursor {
+		t.Errorf("Expected no messages and max_seq %d, got %v and %d", cursor, ids, next)
+	}
+
+	database.InsertMessage("c", "+111", "+222", "c", nil, "profile-1", "outbound")
+	ids, next = poll(cursor)
+	if strings.Join(ids, ",") != "c" || next <= cursor {
+		t.Errorf("Expected only [c] and an advanced cursor, got %v and %d (was %d)", ids, next, cursor)
+	}
+
+	rr := httptest.NewRecorder()
+	HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages?after_seq=abc", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid cursor, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestWebhookAbortPercent_ConnectionError(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var hits int32
+	var mu sync.Mutex
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	deliveries, unsubscribe := webhook.Deliveries.Subscribe()
+	defer unsubscribe()
+
+	database.SetSetting("webhook_abort_percent", "100")
+	sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Dropped",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+	})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-deliveries:
+			if event.Success || event.StatusCode != 0 || !strings.HasPrefix(event.Error, "connection error") {
+				t.Errorf("Expected an aborted attempt reported as a connection error, got %+v", event)
+			}
+		case <-time.After(4 * time.Second):
+			t.Fatal("Timeout waiting for delivery attempts")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 0 {
+		t.Errorf("Expected aborted attempts never to reach the receiver, got %d requests", hits)
+	}
+}
+
+func TestHandleCreateMessage_CreateSuccessStatus(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	create := func() *httptest.ResponseRecorder {
+		body := `{"from": "+1234567890", "to": "+0987654321", "text": "hi", "messaging_profile_id": "profile-1"}`
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		return rr
+	}
+
+	rr := create()
+	if rr.Code != http.StatusOK || rr.Header().Get("Location") != "" {
+		t.Errorf("Expected 200 without Location by default, got %d with Location %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"create_success_status": 202}`))
+	rr = httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unsupported code, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"create_success_status": 201}`))
+	rr = httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = create()
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+	var response map[string]map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if want := "/v2/messages/" + response["data"]["id"].(string); rr.Header().Get("Location") != want {
+		t.Errorf("Expected Location %q, got %q", want, rr.Header().Get("Location"))
+	}
+}
+
+func TestWebhookFieldMap_RenamesPayloadKeys(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, body := range []string{
+		`{"webhook_field_map": {"id": "text"}}`,
+		`{"webhook_field_map": {"id": "message_id", "text": "message_id"}}`,
+		`{"webhook_field_map": {"id": "event_type"}}`,
+		`{"webhook_field_map": {"id": ""}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		HandleSetSettings(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for colliding map %s, got %d", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"webhook_field_map": {"id": "message_id"}, "webhook_initial_delay_ms": 0}`))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	received := make(chan []byte, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+		received <- body.Bytes()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Renamed",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+	})
+
+	select {
+	case body := <-received:
+		var payload webhook.TelnyxWebhookPayload
+		json.Unmarshal(body, &payload)
+		if payload.Data.Payload["message_id"] != data["id"] {
+			t.Errorf("Expected message_id '%v', got '%v'", data["id"], payload.Data.Payload["message_id"])
+		}
+		if _, ok := payload.Data.Payload["id"]; ok {
+			t.Error("Expected 'id' to be renamed away")
+		}
+		if payload.Data.ID == "" {
+			t.Error("Expected the event's own id to be left alone")
+		}
+	case <-time.After(4 * time.Second):
+		t.Fatal("Timeout waiting for webhook")
+	}
+}
+
+func TestHandleCreateMessage_SkipWebhooks(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	received := make(chan string, 8)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload.Data.Payload["id"].(string)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	skipped := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Skipped",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+		"skip_webhooks":        true,
+	})
+	if skipped["skip_webhooks"] != true {
+		t.Errorf("Expected skip_webhooks to be echoed, got %v", skipped["skip_webhooks"])
+	}
+	sent := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Sent",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+	})
+	if _, ok := sent["skip_webhooks"]; ok {
+		t.Error("Expected skip_webhooks to be absent when not requested")
+	}
+
+	// Both messages advance together, so once the other's callbacks are in the skipped one's would be too
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-received:
+			if id == skipped["id"] {
+				t.Fatal("Expected no webhook for the message with skip_webhooks")
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timeout waiting for webhook")
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case id := <-received:
+		if id == skipped["id"] {
+			t.Fatal("Expected no webhook for the message with skip_webhooks")
+		}
+	default:
+	}
+
+	msg, err := database.GetMessageByID(skipped["id"].(string))
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if msg.Status != "delivered" {
+		t.Errorf("Expected the skipped message's status to still advance to delivered, got %q", msg.Status)
+	}
+}
+
+func TestHandleCreateMessage_WebhookFanOut(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	received := map[string][]string{}
+	consumer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload webhook.TelnyxWebhookPayload
+			json.NewDecoder(r.Body).Decode(&payload)
+			mu.Lock()
+			received[name] = append(received[name], payload.Data.EventType)
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	first, second := consumer("first"), consumer("second")
+	defer first.Close()
+	defer second.Close()
+
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Fan-out",
+		"messaging_profile_id": "profile-1",
+		"webhook_urls":         []string{first.URL, second.URL},
+		"webhook_delay_ms":     0,
+	})
+	if urls, _ := data["webhook_urls"].([]interface{}); len(urls) != 2 {
+		t.Errorf("Expected webhook_urls to be echoed, got %v", data["webhook_urls"])
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		done := len(received["first"]) == 2 && len(received["second"]) == 2
+		mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range []string{"first", "second"} {
+		if got := received[name]; len(got) != 2 || got[0] != "message.sent" || got[1] != "message.delivered" {
+			t.Errorf("Expected %s consumer to get sent then delivered, got %v", name, got)
+		}
+	}
+
+	// The array replaces webhook_url/webhook_failover_url rather than mixing with them
+	bodyBytes, _ := json.Marshal(map[string]interface{}{
+		"from":         "+1234567890",
+		"to":           "+0987654321",
+		"text":         "Ambiguous",
+		"webhook_url":  first.URL,
+		"webhook_urls": []string{second.URL},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d combining webhook_url and webhook_urls, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestHandleCreateMessage_ForceParts(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Short",
+		"messaging_profile_id": "profile-1",
+		"force_parts":          3,
+	}
+
+	// Ignored outside debug mode
+	data := sendTestMessage(t, body)
+	if data["parts"] != float64(1) {
+		t.Errorf("Expected force_parts to be ignored without debug mode, got parts %v", data["parts"])
+	}
+
+	database.SetSetting("debug_mode", "true")
+	database.SetSetting("detailed_cost", "true")
+	data = sendTestMessage(t, body)
+	if data["parts"] != float64(3) {
+		t.Errorf("Expected parts 3, got %v", data["parts"])
+	}
+	cost := data["cost"].(map[string]interface{})
+	if cost["amount"] != sms.FormatAmount(3*sms.SMSPartPrice) {
+		t.Errorf("Expected the cost of 3 parts, got %v", cost["amount"])
+	}
+	if breakdown, _ := cost["breakdown"].([]interface{}); len(breakdown) != 3 {
+		t.Errorf("Expected a 3-part breakdown, got %v", cost["breakdown"])
+	}
+
+	// The forced count is stored, so polling reports the parts that were billed
+	router := chi.NewRouter()
+	router.Get("/v2/messages/{id}", HandleRetrieveMessage)
+	req := httptest.NewRequest(http.MethodGet, "/v2/messages/"+data["id"].(string), nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var retrieved struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &retrieved)
+	if retrieved.Data["parts"] != float64(3) {
+		t.Errorf("Expected the retrieved message to report 3 parts, got %v", retrieved.Data["parts"])
+	}
+	if amount := retrieved.Data["cost"].(map[string]interface{})["amount"]; amount != cost["amount"] {
+		t.Errorf("Expected the retrieved cost %v to match the create response %v", amount, cost["amount"])
+	}
+}
+
+func TestHandleCreateMessage_OmitUnknownEncoding(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Present by default
+	if data := createTestMessage(t, "profile-1"); data["encoding"] != "GSM-7" {
+		t.Errorf("Expected encoding 'GSM-7' by default, got %v", data["encoding"])
+	}
+
+	database.SetSetting("omit_unknown_encoding", "true")
+	if data := createTestMessage(t, "profile-1"); data["encoding"] != nil {
+		t.Errorf("Expected no encoding without detection, got %v", data["encoding"])
+	}
+
+	// Detection makes it known again
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-1",
+		"auto_detect":          true,
+	})
+	if data["encoding"] != "GSM-7" {
+		t.Errorf("Expected encoding 'GSM-7' with auto_detect, got %v", data["encoding"])
+	}
+
+	// Text outside the GSM-7 alphabet is detected as UCS-2 and segmented as such
+	data = sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 strings.Repeat("こ", 71),
+		"messaging_profile_id": "profile-1",
+		"auto_detect":          true,
+	})
+	if data["encoding"] != "UCS-2" {
+		t.Errorf("Expected encoding 'UCS-2' for non-GSM text with auto_detect, got %v", data["encoding"])
+	}
+	if data["parts"] != float64(2) {
+		t.Errorf("Expected 2 UCS-2 parts, got %v", data["parts"])
+	}
+}
+
+func TestHandleCreateMessage_ResponseRecordType(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if data := createTestMessage(t, "profile-1"); data["record_type"] != "message" {
+		t.Errorf("Expected default record_type 'message', got %v", data["record_type"])
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"response_record_type": ""}`))
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an empty record type, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(`{"response_record_type": "sms_message"}`))
+	rr = httptest.NewRecorder()
+	HandleSetSettings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if data := createTestMessage(t, "profile-1"); data["record_type"] != "sms_message" {
+		t.Errorf("Expected overridden record_type 'sms_message', got %v", data["record_type"])
+	}
+}
+
+func TestSlowConsumerThreshold(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("slow_consumer_threshold_ms", "50")
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Slow consumer",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          slow.URL,
+		"webhook_delay_ms":     0,
+	})
+
+	var warnings []database.LogEntry
+	deadline := time.Now().Add(3 * time.Second)
+	for len(warnings) < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		warnings, _ = database.FilterLogs(database.LogFilter{Level: "warning", Category: "webhook"}, 10)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("Expected a slow consumer warning for each webhook, got %+v", warnings)
+	}
+
+	var details map[string]interface{}
+	json.Unmarshal([]byte(warnings[0].Details), &details)
+	if warnings[0].Message != "Slow webhook consumer" || details["url"] != slow.URL || details["threshold_ms"] != float64(50) {
+		t.Errorf("Unexpected warning %s %v", warnings[0].Message, details)
+	}
+	if duration, _ := details["duration_ms"].(float64); duration < 150 {
+		t.Errorf("Expected duration_ms of at least 150, got %v", details["duration_ms"])
+	}
+}
+
+func TestWebhookDuplicateRate(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Each delivery draws once from simrand, so replaying the seed gives the number of duplicates
+	// to expect, whichever message's delivery ends up taking each draw
+	const messages = 5
+	const seed = 1
+	database.SetSetting("webhook_duplicate_rate", "0.5")
+	simrand.Seed(seed)
+	duplicates := 0
+	for i := 0; i < messages*2; i++ {
+		if simrand.Float64() < 0.5 {
+			duplicates++
+		}
+	}
+	if duplicates == 0 || duplicates == messages*2 {
+		t.Fatalf("Seed %d should duplicate some but not all deliveries, got %d of %d", seed, duplicates, messages*2)
+	}
+	simrand.Seed(seed)
+
+	var mu sync.Mutex
+	var received []webhook.TelnyxWebhookPayload
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		received = append(received, payload)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer consumer.Close()
+
+	for i := 0; i < messages; i++ {
+		sendTestMessage(t, map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 "Duplicate me",
+			"messaging_profile_id": "profile-1",
+			"webhook_url":          consumer.URL,
+			"webhook_delay_ms":     0,
+		})
+	}
+
+	// message.sent and message.delivered for each message, plus the drawn duplicates
+	want := messages*2 + duplicates
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= want {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Leave time for any unexpected extra delivery to arrive
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != want {
+		t.Fatalf("Expected %d deliveries, got %d", want, len(received))
+	}
+	seen := map[string]int{}
+	for _, payload := range received {
+		seen[payload.Data.ID]++
+	}
+	if len(seen) != messages*2 {
+		t.Errorf("Expected %d distinct event IDs, got %d", messages*2, len(seen))
+	}
+	repeated := 0
+	for id, count := range seen {
+		switch count {
+		case 1:
+		case 2:
+			repeated++
+		default:
+			t.Errorf("Expected event %s delivered at most twice, got %d", id, count)
+		}
+	}
+	if repeated != duplicates {
+		t.Errorf("Expected %d duplicated events, got %d", duplicates, repeated)
+	}
+}
+
+func TestWebhookDumpDir(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	dir := filepath.Join(t.TempDir(), "dumps")
+	body, _ := json.Marshal(map[string]string{"webhook_dump_dir": dir})
+	rr := httptest.NewRecorder()
+	HandleSetSettings(rr, httptest.NewRequest(http.MethodPost, "/api/settings", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	delivered := make(chan struct{}, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		delivered <- struct{}{}
+	}))
+	defer receiver.Close()
+
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Dumped",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+	})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-delivered:
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timeout waiting for webhook")
+		}
+	}
+
+	for _, event := range []string{"message.sent", "message.delivered"} {
+		contents, err := os.ReadFile(filepath.Join(dir, data["id"].(string)+"_"+event+".json"))
+		if err != nil {
+			t.Fatalf("Expected %s payload to be dumped: %v", event, err)
+		}
+		var payload webhook.TelnyxWebhookPayload
+		if err := json.Unmarshal(contents, &payload); err != nil {
+			t.Fatalf("Expected dumped %s payload to be JSON: %v", event, err)
+		}
+		if payload.Data.EventType != event || payload.Data.Payload["id"] != data["id"] {
+			t.Errorf("Unexpected dumped payload for %s: %s", event, contents)
 		}
 	}
 }
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"runtime"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+	"telnyx-mock/internal/webhook"
+)
+
+// HandleDebugHealth handles GET /api/debug/health (debug mode only), reporting the counters that
+// grow when something leaks: running goroutines, webhook goroutines still in flight and the
+// database connection pool
+func HandleDebugHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isDebugMode() {
+		validator.WriteError(w, "10010", "Forbidden", "[SmsSink] This endpoint is only available in debug mode.", http.StatusForbidden)
+		return
+	}
+
+	pool := database.PoolStats()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"goroutines":         runtime.NumGoroutine(),
+		"webhooks_in_flight": webhook.InFlight(),
+		"db": map[string]interface{}{
+			"open_connections": pool.OpenConnections,
+			"in_use":           pool.InUse,
+			"idle":             pool.Idle,
+			"wait_count":       pool.WaitCount,
+			"wait_duration_ms": pool.WaitDuration.Milliseconds(),
+		},
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleDebugHealth(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		HandleDebugHealth(rr, httptest.NewRequest(http.MethodGet, "/api/debug/health", nil))
+		return rr
+	}
+
+	if rr := get(); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d outside debug mode, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	database.SetSetting("debug_mode", "true")
+
+	// The message's status goroutine waits out the initial delay, so it's still in flight
+	createTestMessage(t, "profile-123")
+
+	rr := get()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var health struct {
+		Goroutines       int `json:"goroutines"`
+		WebhooksInFlight int `json:"webhooks_in_flight"`
+		DB               struct {
+			OpenConnections *int `json:"open_connections"`
+			InUse           *int `json:"in_use"`
+			Idle            *int `json:"idle"`
+		} `json:"db"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &health)
+
+	if health.Goroutines < 2 {
+		t.Errorf("Expected the goroutine count to include the status goroutine, got %d", health.Goroutines)
+	}
+	if health.WebhooksInFlight < 1 {
+		t.Errorf("Expected at least 1 webhook goroutine in flight, got %d", health.WebhooksInFlight)
+	}
+	if health.DB.OpenConnections == nil || *health.DB.OpenConnections < 1 || health.DB.InUse == nil || health.DB.Idle == nil {
+		t.Errorf("Expected DB pool stats with an open connection, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// HandleListIdempotency handles GET /api/idempotency, listing unexpired keys
+func HandleListIdempotency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keys, err := database.GetIdempotencyKeys()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve idempotency keys.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// HandleClearIdempotency handles DELETE /api/idempotency (debug mode only)
+func HandleClearIdempotency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isDebugMode() {
+		validator.WriteError(w, "10010", "Forbidden", "[SmsSink] This endpoint is only available in debug mode.", http.StatusForbidden)
+		return
+	}
+
+	deleted, err := database.ClearIdempotencyKeys()
+	if err != nil {
+		database.LogError("system", "Failed to clear idempotency keys", map[string]interface{}{
+			"error": err.Error(),
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to clear idempotency keys.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Idempotency keys cleared", map[string]interface{}{
+		"deleted": deleted,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"deleted": deleted,
+	})
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func sendIdempotentMessage(t *testing.T, key string) (map[string]interface{}, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	bodyBytes, _ := json.Marshal(map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-1",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Idempotency-Key", key)
+	rr := httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	return response["data"].(map[string]interface{}), rr
+}
+
+func TestIdempotency_ReuseAndClear(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	database.SetSetting("debug_mode", "true")
+
+	first, _ := sendIdempotentMessage(t, "key-1")
+	replayed, rr := sendIdempotentMessage(t, "key-1")
+	if replayed["id"] != first["id"] {
+		t.Errorf("Expected reused key to replay message %v, got %v", first["id"], replayed["id"])
+	}
+	if rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected Idempotent-Replayed header on replay")
+	}
+	if messages, _ := database.GetAllMessages(); len(messages) != 1 {
+		t.Errorf("Expected 1 stored message, got %d", len(messages))
+	}
+
+	rr = httptest.NewRecorder()
+	HandleListIdempotency(rr, httptest.NewRequest(http.MethodGet, "/api/idempotency", nil))
+	var keys []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &keys)
+	if len(keys) != 1 || keys[0]["key"] != "key-1" || keys[0]["message_id"] != first["id"] {
+		t.Errorf("Expected key-1 listed for message %v, got %v", first["id"], keys)
+	}
+
+	rr = httptest.NewRecorder()
+	HandleClearIdempotency(rr, httptest.NewRequest(http.MethodDelete, "/api/idempotency", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	fresh, rr := sendIdempotentMessage(t, "key-1")
+	if fresh["id"] == first["id"] {
+		t.Error("Expected a fresh message after clearing idempotency keys")
+	}
+	if rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("Expected no Idempotent-Replayed header on a fresh insert")
+	}
+}
+
+func TestHandleClearIdempotency_RequiresDebug(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	rr := httptest.NewRecorder()
+	HandleClearIdempotency(rr, httptest.NewRequest(http.MethodDelete, "/api/idempotency", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestIdempotency_ReplayKeepsCreatedStatus(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	database.SetSetting("create_success_status", "201")
+
+	send := func() *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 "Test message",
+			"messaging_profile_id": "profile-1",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Idempotency-Key", "key-created")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		return rr
+	}
+
+	first := send()
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+
+	// The replay answers like the original even once the setting has changed
+	database.SetSetting("create_success_status", "200")
+	replayed := send()
+	if replayed.Code != http.StatusCreated {
+		t.Errorf("Expected the replay to keep status %d, got %d", http.StatusCreated, replayed.Code)
+	}
+	if location := replayed.Header().Get("Location"); location == "" || location != first.Header().Get("Location") {
+		t.Errorf("Expected the replay's Location %q, got %q", first.Header().Get("Location"), location)
+	}
+}
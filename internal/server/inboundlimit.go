@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// DefaultMaxConcurrentInbound is the inbound processing limit when not configured; high enough
+// that normal test traffic never hits it
+const DefaultMaxConcurrentInbound = 1000
+
+// inboundInFlight counts the inbound webhook requests being processed
+var inboundInFlight atomic.Int64
+
+// acquireInbound takes a processing slot if fewer than limit are in use; limit <= 0 means no limit
+func acquireInbound(limit int) bool {
+	if inboundInFlight.Add(1) > int64(limit) && limit > 0 {
+		inboundInFlight.Add(-1)
+		return false
+	}
+	return true
+}
+
+// releaseInbound frees a slot taken by acquireInbound
+func releaseInbound() {
+	inboundInFlight.Add(-1)
+}
+
+// InboundConcurrencyMiddleware enforces the max_concurrent_inbound setting (0 = off), shedding
+// inbound webhooks that arrive while that many are already being processed with a 503 and
+// Retry-After, the way a provider under load would
+func InboundConcurrencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := database.GetIntSetting("max_concurrent_inbound", DefaultMaxConcurrentInbound)
+		if !acquireInbound(limit) {
+			database.LogWarning("webhook", "Inbound webhook shed: too many concurrent requests", map[string]interface{}{
+				"limit": limit,
+				"path":  r.URL.Path,
+				"ip":    r.RemoteAddr,
+			})
+			setRetryAfter(w, 1, time.Now())
+			validator.WriteError(w, "10016", "Service unavailable", "[SmsSink] Too many inbound messages are being processed. Retry later.", http.StatusServiceUnavailable)
+			return
+		}
+		defer releaseInbound()
+
+		next.ServeHTTP(w, r)
+	})
+}
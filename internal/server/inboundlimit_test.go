@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestInboundConcurrencyMiddleware_ShedsLoad(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("max_concurrent_inbound", "3")
+
+	// Requests that get a slot hold it until released, so the overlap is deterministic
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	handler := InboundConcurrencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		HandleInboundWebhook(w, r)
+	}))
+
+	const total = 10
+	responses := make([]*httptest.ResponseRecorder, total)
+	shed := make(chan struct{}, total)
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"from": "+15551234567", "to": "+15557654321", "text": "hi %d"}`, i)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", strings.NewReader(body)))
+			responses[i] = rr
+			if rr.Code == http.StatusServiceUnavailable {
+				shed <- struct{}{}
+			}
+		}(i)
+	}
+
+	// Three requests are processing and the rest are shed before the slots free up
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	for i := 0; i < total-3; i++ {
+		<-shed
+	}
+	close(release)
+	wg.Wait()
+
+	accepted, rejected := 0, 0
+	for _, rr := range responses {
+		switch rr.Code {
+		case http.StatusOK:
+			accepted++
+		case http.StatusServiceUnavailable:
+			rejected++
+			if rr.Header().Get("Retry-After") != "1" {
+				t.Errorf("Expected Retry-After '1', got '%s'", rr.Header().Get("Retry-After"))
+			}
+		default:
+			t.Errorf("Unexpected status %d. Body: %s", rr.Code, rr.Body.String())
+		}
+	}
+	if accepted != 3 || rejected != total-3 {
+		t.Errorf("Expected 3 accepted and %d shed, got %d and %d", total-3, accepted, rejected)
+	}
+	if messages, _ := database.GetAllMessages(); len(messages) != 3 {
+		t.Errorf("Expected only the accepted requests to be stored, got %d messages", len(messages))
+	}
+
+	// Slots are freed once requests finish
+	rr := httptest.NewRecorder()
+	InboundConcurrencyMiddleware(http.HandlerFunc(HandleInboundWebhook)).ServeHTTP(rr,
+		httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", strings.NewReader(`{"from": "+15551234567", "to": "+15557654321", "text": "later"}`)))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d after the burst, got %d", http.StatusOK, rr.Code)
+	}
+}
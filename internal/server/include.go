@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"telnyx-mock/internal/database"
+)
+
+// includes reports whether the comma-separated ?include= query parameter lists name
+func includes(r *http.Request, name string) bool {
+	return slices.Contains(strings.Split(r.URL.Query().Get("include"), ","), name)
+}
+
+// messageWithProfile is a stored message with its messaging profile embedded
+type messageWithProfile struct {
+	database.Message
+	MessagingProfile *database.MessagingProfile `json:"messaging_profile,omitempty"`
+}
+
+// embedProfiles attaches each message's messaging profile, looking every profile up once.
+// Messages whose profile is unknown are returned without one.
+func embedProfiles(messages []database.Message) []messageWithProfile {
+	embed := profileEmbedder()
+	embedded := make([]messageWithProfile, 0, len(messages))
+	for _, msg := range messages {
+		embedded = append(embedded, embed(msg))
+	}
+	return embedded
+}
+
+// profileEmbedder returns a function attaching a message's messaging profile, caching each profile
+// across calls; embedProfiles and the streamed listing share it
+func profileEmbedder() func(database.Message) messageWithProfile {
+	profiles := map[string]*database.MessagingProfile{}
+	return func(msg database.Message) messageWithProfile {
+		profile, seen := profiles[msg.MessagingProfileID]
+		if !seen {
+			profile, _ = database.GetProfile(msg.MessagingProfileID)
+			profiles[msg.MessagingProfileID] = profile
+		}
+		return messageWithProfile{Message: msg, MessagingProfile: profile}
+	}
+}
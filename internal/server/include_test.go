@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+)
+
+func TestIncludeMessagingProfile(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.UpsertProfile(database.MessagingProfile{ID: "profile-known", Name: "Known account"})
+	database.InsertMessage("msg-known", "+111", "+222", "test", []string{}, "profile-known", "outbound")
+	database.InsertMessage("msg-unknown", "+111", "+222", "test", []string{}, "profile-missing", "outbound")
+
+	router := chi.NewRouter()
+	router.Get("/api/messages", HandleListMessages)
+	router.Get("/api/messages/{id}", HandleGetMessage)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	var msg map[string]interface{}
+	json.Unmarshal(get("/api/messages/msg-known?include=messaging_profile").Body.Bytes(), &msg)
+	profile, ok := msg["messaging_profile"].(map[string]interface{})
+	if !ok || profile["name"] != "Known account" {
+		t.Errorf("Expected embedded profile 'Known account', got %v", msg["messaging_profile"])
+	}
+
+	msg = nil
+	json.Unmarshal(get("/api/messages/msg-unknown?include=messaging_profile").Body.Bytes(), &msg)
+	if _, ok := msg["messaging_profile"]; ok {
+		t.Errorf("Expected no profile for an unknown messaging_profile_id, got %v", msg["messaging_profile"])
+	}
+
+	msg = nil
+	json.Unmarshal(get("/api/messages/msg-known").Body.Bytes(), &msg)
+	if _, ok := msg["messaging_profile"]; ok {
+		t.Error("Expected no profile without ?include=messaging_profile")
+	}
+
+	var messages []map[string]interface{}
+	json.Unmarshal(get("/api/messages?include=messaging_profile").Body.Bytes(), &messages)
+	embedded := 0
+	for _, m := range messages {
+		if _, ok := m["messaging_profile"]; ok {
+			embedded++
+		}
+	}
+	if len(messages) != 2 || embedded != 1 {
+		t.Errorf("Expected 2 messages with 1 embedded profile, got %d messages and %d profiles", len(messages), embedded)
+	}
+
+	if rr := get("/api/messages/does-not-exist"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
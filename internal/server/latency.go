@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/simrand"
+)
+
+// apiWriteTimeout is the API server's WriteTimeout, set by SetAPIWriteTimeout; 0 means none
+var apiWriteTimeout time.Duration
+
+// latencyHeadroom is the time left under the write timeout for the handler itself to reply
+const latencyHeadroom = time.Second
+
+// maxSettableLatencyMs bounds api_latency_ms and api_latency_stddev_ms when there's no write timeout
+const maxSettableLatencyMs = 60000
+
+// SetAPIWriteTimeout records the API server's WriteTimeout, so simulated latency never holds a
+// response past it and the server drops the connection instead of replying late
+func SetAPIWriteTimeout(d time.Duration) {
+	apiWriteTimeout = d
+}
+
+// maxLatency returns the longest simulated delay that still leaves time to reply before the write
+// timeout, or 0 when there's no timeout
+func maxLatency() time.Duration {
+	if apiWriteTimeout <= 0 {
+		return 0
+	}
+	if apiWriteTimeout <= 2*latencyHeadroom {
+		return apiWriteTimeout / 2
+	}
+	return apiWriteTimeout - latencyHeadroom
+}
+
+// maxLatencySettingMs is the largest api_latency_ms or api_latency_stddev_ms accepted
+func maxLatencySettingMs() int {
+	if limit := maxLatency(); limit > 0 && limit.Milliseconds() < maxSettableLatencyMs {
+		return int(limit.Milliseconds())
+	}
+	return maxSettableLatencyMs
+}
+
+// LatencyMiddleware delays each response according to the configured latency distribution
+func LatencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay := sampleLatency(database.GetLatencyConfig()); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sampleLatency draws a single delay from the latency config, never negative and never so long that
+// the response would miss the write timeout
+func sampleLatency(cfg database.LatencyConfig) time.Duration {
+	ms := float64(cfg.MeanMs)
+	if cfg.Mode == "normal" && cfg.StddevMs > 0 {
+		ms += simrand.NormFloat64() * float64(cfg.StddevMs)
+	}
+	if ms <= 0 {
+		return 0
+	}
+	delay := time.Duration(ms * float64(time.Millisecond))
+	if limit := maxLatency(); limit > 0 && delay > limit {
+		return limit
+	}
+	return delay
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/simrand"
+)
+
+func TestSampleLatency_NormalMeanNearConfigured(t *testing.T) {
+	simrand.Seed(1)
+	cfg := database.LatencyConfig{Mode: "normal", MeanMs: 200, StddevMs: 50}
+
+	const samples = 5000
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		d := sampleLatency(cfg)
+		if d < 0 {
+			t.Fatalf("Expected non-negative latency, got %v", d)
+		}
+		total += d
+	}
+
+	meanMs := float64(total/samples) / float64(time.Millisecond)
+	if math.Abs(meanMs-200) > 5 {
+		t.Errorf("Expected mean latency near 200ms, got %.2fms", meanMs)
+	}
+}
+
+func TestSampleLatency_FixedIgnoresStddev(t *testing.T) {
+	cfg := database.LatencyConfig{Mode: "fixed", MeanMs: 30, StddevMs: 50}
+	for i := 0; i < 10; i++ {
+		if d := sampleLatency(cfg); d != 30*time.Millisecond {
+			t.Fatalf("Expected fixed 30ms, got %v", d)
+		}
+	}
+
+	if d := sampleLatency(database.LatencyConfig{Mode: "fixed"}); d != 0 {
+		t.Errorf("Expected no latency by default, got %v", d)
+	}
+}
+
+func TestLatencyMiddleware_DelaysResponse(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("api_latency_ms", "50")
+
+	handler := LatencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v2/messages", nil))
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms delay, got %v", elapsed)
+	}
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestAPILatency_BoundedByWriteTimeout(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	SetAPIWriteTimeout(15 * time.Second)
+	defer SetAPIWriteTimeout(0)
+
+	// Delays beyond the timeout are cut short so the reply still goes out
+	cfg := database.LatencyConfig{Mode: "fixed", MeanMs: 20000}
+	if d := sampleLatency(cfg); d != 14*time.Second {
+		t.Errorf("Expected latency capped at 14s, got %v", d)
+	}
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"api_latency_ms": 14000}`, http.StatusOK},
+		{`{"api_latency_ms": 20000}`, http.StatusBadRequest},
+		{`{"api_latency_stddev_ms": 20000}`, http.StatusBadRequest},
+	} {
+		rr := httptest.NewRecorder()
+		HandleSetSettings(rr, httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(tc.body)))
+		if rr.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d. Body: %s", tc.body, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
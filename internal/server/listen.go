@@ -0,0 +1,21 @@
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+// Listen binds a TCP listener on addr and returns it with the port actually bound, so ":0" can be
+// used to let the OS pick a free port
+func Listen(addr string) (net.Listener, int, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return listener, listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// FormatPorts renders the bound ports as KEY=value lines that test harnesses can parse
+func FormatPorts(apiPort, uiPort int) string {
+	return fmt.Sprintf("API_PORT=%d\nUI_PORT=%d\n", apiPort, uiPort)
+}
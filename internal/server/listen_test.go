@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestListen_ReportsDynamicPort(t *testing.T) {
+	listener, port, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	if port == 0 {
+		t.Fatal("Expected the OS-assigned port, got 0")
+	}
+
+	// The reported port is the one actually accepting connections
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("Failed to connect to reported port %d: %v", port, err)
+	}
+	conn.Close()
+}
+
+func TestFormatPorts(t *testing.T) {
+	if got, want := FormatPorts(40001, 40002), "API_PORT=40001\nUI_PORT=40002\n"; got != want {
+		t.Errorf("FormatPorts = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// logExportColumns is the header row of a log export
+var logExportColumns = []string{"id", "created_at", "level", "category", "message", "details"}
+
+// HandleExportLogs handles GET /api/logs/export, streaming the logs matching the level, category,
+// message_id, since and until filters as a CSV attachment, oldest first
+func HandleExportLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter := parseLogFilter(r)
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="smssink-logs.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	// Rows are written as they're read; once the header is out, an error can only be logged
+	writer := csv.NewWriter(w)
+	writer.Write(logExportColumns)
+	err := database.EachLog(filter, func(entry database.LogEntry) error {
+		return writer.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+			entry.Level,
+			entry.Category,
+			entry.Message,
+			entry.Details,
+		})
+	})
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if err != nil {
+		database.LogError("system", "Failed to export logs", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
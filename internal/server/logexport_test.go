@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleExportLogs(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.Log("export", "info entry", nil)
+	database.LogWarning("export", "warning entry", map[string]interface{}{"message_id": "msg-1"})
+	database.LogWarning("other", "other warning", nil)
+
+	rr := httptest.NewRecorder()
+	HandleExportLogs(rr, httptest.NewRequest(http.MethodGet, "/api/logs/export?category=export&level=warning", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment;") {
+		t.Errorf("Expected an attachment Content-Disposition, got '%s'", got)
+	}
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if strings.Join(records[0], ",") != "id,created_at,level,category,message,details" {
+		t.Errorf("Unexpected header %v", records[0])
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected the header and 1 row, got %v", records)
+	}
+	row := records[1]
+	if row[2] != "warning" || row[3] != "export" || row[4] != "warning entry" || !strings.Contains(row[5], `"message_id":"msg-1"`) {
+		t.Errorf("Unexpected row %v", row)
+	}
+
+	// Time filters exclude everything logged before since
+	rr = httptest.NewRecorder()
+	since := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	HandleExportLogs(rr, httptest.NewRequest(http.MethodGet, "/api/logs/export?since="+since, nil))
+	records, _ = csv.NewReader(rr.Body).ReadAll()
+	if len(records) != 1 {
+		t.Errorf("Expected only the header with a future since, got %v", records)
+	}
+}
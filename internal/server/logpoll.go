@@ -0,0 +1,62 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+// Long-poll timeouts for GET /api/logs?wait=true; clients may shorten the default with ?timeout=seconds
+const (
+	defaultLogPollTimeout = 30 * time.Second
+	maxLogPollTimeout     = 60 * time.Second
+)
+
+// logPollTimeout parses the timeout query parameter (seconds), falling back to the default
+func logPollTimeout(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return defaultLogPollTimeout
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if timeout > maxLogPollTimeout {
+		return maxLogPollTimeout
+	}
+	return timeout
+}
+
+// waitForLogs returns logs with an id greater than afterID, blocking until at least one
+// matching entry is written, the timeout elapses (empty result) or done is closed
+func waitForLogs(afterID int64, filter database.LogFilter, limit int, timeout time.Duration, done <-chan struct{}) ([]database.LogEntry, error) {
+	// Subscribe before querying so an entry written in between isn't missed
+	entries, unsubscribe := database.LogEvents.Subscribe()
+	defer unsubscribe()
+
+	logs, err := database.GetLogsAfter(afterID, filter, limit)
+	if err != nil || len(logs) > 0 {
+		return logs, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case entry := <-entries:
+			if entry.ID <= afterID || (filter.Level != "" && entry.Level != filter.Level) || (filter.Category != "" && entry.Category != filter.Category) {
+				continue
+			}
+			// Re-read from the database to pick up anything else written alongside it. A
+			// message_id filter is left to the query, which may find nothing and keep waiting.
+			logs, err := database.GetLogsAfter(afterID, filter, limit)
+			if err != nil || len(logs) > 0 {
+				return logs, err
+			}
+		case <-timer.C:
+			return []database.LogEntry{}, nil
+		case <-done:
+			return []database.LogEntry{}, nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleGetLogs_LongPoll(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.Log("system", "Existing entry", nil)
+	existing, _ := database.GetLogs("", "", 1)
+	afterID := existing[0].ID
+
+	result := make(chan []database.LogEntry, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/logs?wait=true&after_id=%d&category=message&timeout=5", afterID), nil)
+		rr := httptest.NewRecorder()
+		HandleGetLogs(rr, req)
+
+		var logs []database.LogEntry
+		json.Unmarshal(rr.Body.Bytes(), &logs)
+		result <- logs
+	}()
+
+	// Give the long-poll a moment to start waiting, then send a message
+	time.Sleep(100 * time.Millisecond)
+	createTestMessage(t, "profile-1")
+
+	select {
+	case logs := <-result:
+		if len(logs) == 0 {
+			t.Fatal("Expected the long-poll to return the new log")
+		}
+		if logs[0].ID <= afterID {
+			t.Errorf("Expected a log newer than %d, got %d", afterID, logs[0].ID)
+		}
+		if logs[0].Message != "Outbound message sent successfully" {
+			t.Errorf("Expected the outbound message log, got '%s'", logs[0].Message)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for long-poll to return")
+	}
+}
+
+func TestHandleGetLogs_LongPollTimeout(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.Log("system", "Existing entry", nil)
+	existing, _ := database.GetLogs("", "", 1)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/logs?wait=true&after_id=%d&timeout=1", existing[0].ID), nil)
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	HandleGetLogs(rr, req)
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the long-poll to wait for the timeout, returned after %v", elapsed)
+	}
+	if rr.Body.String() != "[]\n" {
+		t.Errorf("Expected '[]' after timeout, got '%s'", rr.Body.String())
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+// LogPruneInterval is how often old logs are cleaned up and the log table is trimmed to max_logs
+const LogPruneInterval = time.Minute
+
+// logRetentionDays matches the startup cleanup in database.InitDB
+const logRetentionDays = 7
+
+// PruneLogs removes logs older than the retention window and then trims the table to the
+// newest max_logs entries (0 means unlimited), returning how many entries were trimmed
+func PruneLogs() (int64, error) {
+	if err := database.CleanupOldLogs(logRetentionDays); err != nil {
+		return 0, err
+	}
+	return database.TrimLogs(database.GetIntSetting("max_logs", 0))
+}
+
+// StartLogPruner runs PruneLogs immediately and then on every interval
+// until the returned stop function is called
+func StartLogPruner(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if count, err := PruneLogs(); err != nil {
+				log.Printf("Failed to prune logs: %v", err)
+			} else if count > 0 {
+				log.Printf("Trimmed %d log entries beyond max_logs", count)
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestPruneLogs_MaxLogs(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for i := 0; i < 30; i++ {
+		database.Log("system", fmt.Sprintf("entry %d", i), nil)
+	}
+
+	// Unlimited by default
+	if trimmed, _ := PruneLogs(); trimmed != 0 {
+		t.Errorf("Expected nothing trimmed without max_logs, got %d", trimmed)
+	}
+
+	database.SetSetting("max_logs", "10")
+	if _, err := PruneLogs(); err != nil {
+		t.Fatalf("Failed to prune logs: %v", err)
+	}
+
+	logs, _ := database.GetLogs("", "", 100)
+	if len(logs) != 10 {
+		t.Fatalf("Expected 10 logs after trimming, got %d", len(logs))
+	}
+	if logs[0].Message != "entry 29" {
+		t.Errorf("Expected the newest entry to be kept, got '%s'", logs[0].Message)
+	}
+}
+
+func TestInsertLog_MaxLogsBound(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("max_logs", "10")
+
+	// The bound holds as entries are written, without waiting for the pruner
+	for i := 0; i < 50; i++ {
+		database.Log("system", fmt.Sprintf("entry %d", i), nil)
+		logs, _ := database.GetLogs("", "", 100)
+		if len(logs) > 10 {
+			t.Fatalf("Expected at most 10 logs after writing entry %d, got %d", i, len(logs))
+		}
+	}
+
+	logs, _ := database.GetLogs("", "", 100)
+	if len(logs) != 10 || logs[0].Message != "entry 49" || logs[9].Message != "entry 40" {
+		t.Errorf("Expected the newest 10 entries, got %d ending with '%s'", len(logs), logs[0].Message)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// HandleVacuum handles POST /api/maintenance/vacuum (debug mode only)
+func HandleVacuum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isDebugMode() {
+		validator.WriteError(w, "10010", "Forbidden", "[SmsSink] This endpoint is only available in debug mode.", http.StatusForbidden)
+		return
+	}
+
+	before, after, err := database.Vacuum()
+	if err != nil {
+		database.LogError("system", "Failed to vacuum database", map[string]interface{}{
+			"error": err.Error(),
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to vacuum database.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Database vacuumed", map[string]interface{}{
+		"size_before": before,
+		"size_after":  after,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"size_before": before,
+		"size_after":  after,
+	})
+}
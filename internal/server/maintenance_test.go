@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleVacuum(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("debug_mode", "true")
+	for i := 0; i < 50; i++ {
+		database.Log("system", "Filler log entry", map[string]interface{}{"i": i})
+	}
+	database.ClearAllLogs()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/maintenance/vacuum", nil)
+	rr := httptest.NewRecorder()
+	HandleVacuum(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response map[string]int64
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["size_before"] <= 0 || response["size_after"] <= 0 {
+		t.Errorf("Expected positive file sizes, got %v", response)
+	}
+	if response["size_after"] > response["size_before"] {
+		t.Errorf("Expected vacuum not to grow the file, got %v", response)
+	}
+}
+
+func TestHandleVacuum_RequiresDebugMode(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/maintenance/vacuum", nil)
+	rr := httptest.NewRecorder()
+	HandleVacuum(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+	"telnyx-mock/internal/webhook"
+)
+
+// manualMessage is a message created with manual_status, whose status only moves when set via
+// /api/messages/{id}/status or when its auto-advance timer fires
+type manualMessage struct {
+	sendMu      sync.Mutex // Held across a whole transition, so updates apply and send in order
+	mu          sync.Mutex // Guards the fields below; never held while a webhook is sent
+	details     webhook.MessageDetails
+	autoAdvance time.Duration // Zero when the message never advances on its own
+	sentAt      time.Time
+	timer       *time.Timer
+}
+
+// manualMessages holds the manually driven messages created since the last reset, by ID
+var manualMessages = struct {
+	mu      sync.Mutex
+	entries map[string]*manualMessage
+}{entries: map[string]*manualMessage{}}
+
+// nextStatus returns the status a message auto-advances to from status, or "" when it's final
+func nextStatus(status string) string {
+	switch status {
+	case "queued":
+		return "sent"
+	case "sent":
+		return "delivered"
+	}
+	return ""
+}
+
+// canTransition reports whether a message may move from one status to another by hand
+func canTransition(from, to string) bool {
+	switch to {
+	case "sent":
+		return from == "queued"
+	case "delivered":
+		return from == "sent"
+	case "failed":
+		return from == "queued" || from == "sent"
+	}
+	return false
+}
+
+// holdForManualStatus registers a newly created queued message for manual status updates,
+// starting its auto-advance timer if it has one
+func holdForManualStatus(details webhook.MessageDetails, autoAdvance time.Duration) {
+	m := &manualMessage{details: details, autoAdvance: autoAdvance}
+	manualMessages.mu.Lock()
+	manualMessages.entries[details.ID] = m
+	manualMessages.mu.Unlock()
+
+	m.mu.Lock()
+	m.schedule("queued")
+	m.mu.Unlock()
+}
+
+// schedule replaces any pending auto-advance with one moving the message on from status. The
+// caller holds m.mu.
+func (m *manualMessage) schedule(status string) {
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	next := nextStatus(status)
+	if m.autoAdvance == 0 || next == "" {
+		return
+	}
+	m.timer = time.AfterFunc(m.autoAdvance, func() {
+		// A manual update that won the race leaves the message in a status this can't follow
+		if applied, err := m.advance(next); err == nil {
+			database.Log("message", "Message status auto-advanced", map[string]interface{}{
+				"message_id": m.details.ID,
+				"status":     applied,
+			})
+		}
+	})
+}
+
+// errInvalidTransition is returned when a message's current status can't move to the requested one
+type errInvalidTransition struct {
+	from, to string
+}
+
+func (e errInvalidTransition) Error() string {
+	return "[SmsSink] A message in status '" + e.from + "' can't move to '" + e.to + "'."
+}
+
+// advance moves the message to status, sending its webhook, and re-arms the auto-advance timer.
+// The stored status is checked first, so a message that expired meanwhile stays expired. A
+// carrier-rejected message is never sent, so moving it on fails it instead; advance returns the
+// status the message moved to. Messages that reach a final status are no longer held.
+func (m *manualMessage) advance(status string) (string, error) {
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+
+	m.mu.Lock()
+	msg, err := database.GetMessageByID(m.details.ID)
+	if err != nil {
+		m.mu.Unlock()
+		return "", err
+	}
+	if msg == nil || !canTransition(msg.Status, status) {
+		from := "deleted"
+		if msg != nil {
+			from = msg.Status
+		}
+		m.mu.Unlock()
+		if nextStatus(from) == "" {
+			forgetManualStatus(m.details.ID)
+		}
+		return "", errInvalidTransition{from: from, to: status}
+	}
+
+	if m.details.RejectReason != nil && status != "failed" {
+		status = "failed"
+	}
+	now := time.Now().UTC()
+	if status == "sent" {
+		m.sentAt = now
+	}
+	details, sentAt := m.details, m.sentAt
+	m.schedule(status)
+	m.mu.Unlock()
+
+	webhook.SendStatusUpdate(details, status, sentAt)
+	if nextStatus(status) == "" {
+		forgetManualStatus(details.ID)
+	}
+	return status, nil
+}
+
+// forgetManualStatus stops holding message id for manual status updates
+func forgetManualStatus(id string) {
+	manualMessages.mu.Lock()
+	m := manualMessages.entries[id]
+	delete(manualMessages.entries, id)
+	manualMessages.mu.Unlock()
+
+	if m != nil {
+		m.mu.Lock()
+		if m.timer != nil {
+			m.timer.Stop()
+			m.timer = nil
+		}
+		m.mu.Unlock()
+	}
+}
+
+// clearManualStatus stops every pending auto-advance and forgets all manually driven messages
+func clearManualStatus() {
+	manualMessages.mu.Lock()
+	entries := manualMessages.entries
+	manualMessages.entries = map[string]*manualMessage{}
+	manualMessages.mu.Unlock()
+
+	for _, m := range entries {
+		m.mu.Lock()
+		if m.timer != nil {
+			m.timer.Stop()
+		}
+		m.mu.Unlock()
+	}
+}
+
+// HandleSetMessageStatus handles POST /api/messages/{id}/status, moving a message created with
+// manual_status to "sent", "delivered" or "failed" and sending the matching webhook. Any pending
+// auto-advance is replaced by one for the next transition.
+func HandleSetMessageStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+	if req.Status != "sent" && req.Status != "delivered" && req.Status != "failed" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'status' parameter must be 'sent', 'delivered' or 'failed'.", http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	manualMessages.mu.Lock()
+	m := manualMessages.entries[id]
+	manualMessages.mu.Unlock()
+	if m == nil {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] No message with manual_status found.", http.StatusNotFound)
+		return
+	}
+
+	status, err := m.advance(req.Status)
+	if err != nil {
+		if transition, ok := err.(errInvalidTransition); ok {
+			validator.WriteError(w, "10005", "Invalid parameter", transition.Error(), http.StatusBadRequest)
+			return
+		}
+		database.LogError("message", "Failed to update message status", map[string]interface{}{
+			"error":      err.Error(),
+			"message_id": id,
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to update message status.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("message", "Message status set manually", map[string]interface{}{
+		"message_id": id,
+		"status":     status,
+	})
+
+	msg, err := database.GetMessageByID(id)
+	if err != nil || msg == nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve message.", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
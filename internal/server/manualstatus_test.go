@@ -0,0 +1,257 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/webhook"
+)
+
+// setTestStatus posts a manual status update for id and returns the response status
+func setTestStatus(t *testing.T, id, status string) int {
+	t.Helper()
+
+	router := chi.NewRouter()
+	router.Post("/api/messages/{id}/status", HandleSetMessageStatus)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/messages/"+id+"/status", strings.NewReader(`{"status": "`+status+`"}`)))
+	return rr.Code
+}
+
+// storedStatus returns the stored status of message id
+func storedStatus(t *testing.T, id string) string {
+	t.Helper()
+
+	msg, err := database.GetMessageByID(id)
+	if err != nil || msg == nil {
+		t.Fatalf("Failed to get message %s: %v", id, err)
+	}
+	return msg.Status
+}
+
+func TestHandleSetMessageStatus_Manual(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	defer clearManualStatus()
+
+	events := make(chan string, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		events <- payload.Data.EventType
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Manual",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"manual_status":        true,
+	})
+	id := data["id"].(string)
+
+	if code := setTestStatus(t, id, "delivered"); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d delivering a queued message, got %d", http.StatusBadRequest, code)
+	}
+	if code := setTestStatus(t, id, "sent"); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if got := <-events; got != "message.sent" {
+		t.Errorf("Expected message.sent webhook, got %s", got)
+	}
+	if code := setTestStatus(t, id, "failed"); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if got := <-events; got != "message.failed" {
+		t.Errorf("Expected message.failed webhook, got %s", got)
+	}
+	if status := storedStatus(t, id); status != "failed" {
+		t.Errorf("Expected stored status 'failed', got %q", status)
+	}
+
+	// Messages without manual_status advance on their own and can't be driven by hand
+	other := createTestMessage(t, "profile-1")
+	if code := setTestStatus(t, other["id"].(string), "sent"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a message without manual_status, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestHandleSetMessageStatus_AutoAdvance(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	defer clearManualStatus()
+
+	send := func(autoAdvanceMs int) string {
+		return sendTestMessage(t, map[string]interface{}{
+			"from":                  "+1234567890",
+			"to":                    "+0987654321",
+			"text":                  "Auto",
+			"messaging_profile_id":  "profile-1",
+			"manual_status":         true,
+			"auto_advance_after_ms": autoAdvanceMs,
+		})["id"].(string)
+	}
+
+	// Left alone, the message moves queued → sent → delivered one timeout apart
+	auto := send(50)
+	if status := storedStatus(t, auto); status != "queued" {
+		t.Errorf("Expected the message to start queued, got %q", status)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for storedStatus(t, auto) != "delivered" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status := storedStatus(t, auto); status != "delivered" {
+		t.Errorf("Expected the message to auto-advance to delivered, got %q", status)
+	}
+
+	// A manual update before the timeout wins, and a final status stops the timer
+	overridden := send(200)
+	if code := setTestStatus(t, overridden, "failed"); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if status := storedStatus(t, overridden); status != "failed" {
+		t.Errorf("Expected the manual status to stick, got %q", status)
+	}
+}
+
+// heldForManualStatus reports whether message id is still held for manual status updates
+func heldForManualStatus(id string) bool {
+	manualMessages.mu.Lock()
+	defer manualMessages.mu.Unlock()
+	return manualMessages.entries[id] != nil
+}
+
+func TestHandleSetMessageStatus_CarrierReject(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	defer clearManualStatus()
+
+	database.SetSetting("carrier_reject_pattern", `^\+1555`)
+
+	events := make(chan webhook.TelnyxWebhookPayload, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		events <- payload
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	id := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+15551234567",
+		"text":                 "Manual",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"manual_status":        true,
+	})["id"].(string)
+
+	// The carrier rejects the message, so it fails rather than being sent
+	if code := setTestStatus(t, id, "sent"); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	payload := <-events
+	if payload.Data.EventType != "message.failed" {
+		t.Errorf("Expected message.failed webhook, got %s", payload.Data.EventType)
+	}
+	if errs, _ := payload.Data.Payload["errors"].([]interface{}); len(errs) == 0 || errs[0].(map[string]interface{})["code"] != "30006" {
+		t.Errorf("Expected error code 30006, got %v", payload.Data.Payload["errors"])
+	}
+	if status := storedStatus(t, id); status != "failed" {
+		t.Errorf("Expected stored status 'failed', got %q", status)
+	}
+}
+
+func TestHandleSetMessageStatus_ForgetsFinishedMessages(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	defer clearManualStatus()
+
+	send := func() string {
+		return sendTestMessage(t, map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 "Manual",
+			"messaging_profile_id": "profile-1",
+			"manual_status":        true,
+		})["id"].(string)
+	}
+
+	// A final status stops holding the message
+	failed := send()
+	if code := setTestStatus(t, failed, "failed"); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if heldForManualStatus(failed) {
+		t.Error("Expected a failed message to no longer be held")
+	}
+
+	// So does deleting the message
+	deleted := send()
+	router := chi.NewRouter()
+	router.Delete("/api/messages/{id}", HandleDeleteMessage)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/messages/"+deleted, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if heldForManualStatus(deleted) {
+		t.Error("Expected a deleted message to no longer be held")
+	}
+}
+
+func TestHandleSetMessageStatus_SlowWebhookDoesNotBlock(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	defer clearManualStatus()
+
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	id := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Manual",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"manual_status":        true,
+	})["id"].(string)
+
+	done := make(chan int, 1)
+	go func() { done <- setTestStatus(t, id, "sent") }()
+	<-arrived
+
+	// While the webhook is outstanding, the held messages can still be cleared
+	cleared := make(chan struct{})
+	go func() {
+		clearManualStatus()
+		close(cleared)
+	}()
+	select {
+	case <-cleared:
+	case <-time.After(time.Second):
+		t.Error("Expected clearing to not wait for an outstanding webhook")
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, code)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+// DefaultMediaCacheTTLSeconds is how long a media URL's size is remembered when not configured
+const DefaultMediaCacheTTLSeconds = 60
+
+// mediaHeadTimeout bounds each HEAD request made to size a media URL
+const mediaHeadTimeout = 5 * time.Second
+
+// maxMediaCacheEntries bounds the media size cache, so a long-running mock sent ever-new media URLs
+// doesn't grow without limit
+const maxMediaCacheEntries = 1000
+
+// mediaEntry is a cached media size
+type mediaEntry struct {
+	size    int64
+	expires time.Time
+}
+
+// mediaSizes caches media sizes by URL, so repeated sends of the same media skip the HEAD request
+var mediaSizes = struct {
+	mu      sync.Mutex
+	entries map[string]mediaEntry
+}{entries: map[string]mediaEntry{}}
+
+// mediaSize returns the Content-Length of url, from the cache while it is fresh, otherwise via a
+// HEAD request. ok is false when the size can't be determined; those results aren't cached.
+func mediaSize(url string, now time.Time) (size int64, ok bool) {
+	mediaSizes.mu.Lock()
+	entry, cached := mediaSizes.entries[url]
+	mediaSizes.mu.Unlock()
+	if cached && now.Before(entry.expires) {
+		return entry.size, true
+	}
+
+	client := &http.Client{Timeout: mediaHeadTimeout}
+	resp, err := client.Head(url)
+	if err != nil {
+		database.LogWarning("message", "Failed to fetch media metadata", map[string]interface{}{
+			"url":   url,
+			"error": err.Error(),
+		})
+		return 0, false
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.ContentLength < 0 {
+		return 0, false
+	}
+
+	if ttl := database.GetIntSetting("media_cache_ttl_seconds", DefaultMediaCacheTTLSeconds); ttl > 0 {
+		cacheMediaSize(url, mediaEntry{size: resp.ContentLength, expires: now.Add(time.Duration(ttl) * time.Second)}, now)
+	}
+	return resp.ContentLength, true
+}
+
+// cacheMediaSize stores a media size. A full cache first drops its expired entries, then the one
+// closest to expiring, so it never holds more than maxMediaCacheEntries.
+func cacheMediaSize(url string, entry mediaEntry, now time.Time) {
+	mediaSizes.mu.Lock()
+	defer mediaSizes.mu.Unlock()
+
+	if _, ok := mediaSizes.entries[url]; !ok && len(mediaSizes.entries) >= maxMediaCacheEntries {
+		for cached, e := range mediaSizes.entries {
+			if !now.Before(e.expires) {
+				delete(mediaSizes.entries, cached)
+			}
+		}
+		if len(mediaSizes.entries) >= maxMediaCacheEntries {
+			var oldest string
+			for cached, e := range mediaSizes.entries {
+				if oldest == "" || e.expires.Before(mediaSizes.entries[oldest].expires) {
+					oldest = cached
+				}
+			}
+			delete(mediaSizes.entries, oldest)
+		}
+	}
+	mediaSizes.entries[url] = entry
+}
+
+// oversizedMedia returns the first URL whose size exceeds maxBytes, and its size. Media whose
+// size can't be determined is allowed through.
+func oversizedMedia(urls []string, maxBytes int64) (string, int64) {
+	now := time.Now()
+	for _, url := range urls {
+		if size, ok := mediaSize(url, now); ok && size > maxBytes {
+			return url, size
+		}
+	}
+	return "", 0
+}
+
+// clearMediaCache forgets every cached media size
+func clearMediaCache() {
+	mediaSizes.mu.Lock()
+	mediaSizes.entries = map[string]mediaEntry{}
+	mediaSizes.mu.Unlock()
+}
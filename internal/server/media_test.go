@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleCreateMessage_MediaSizeCached(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	clearMediaCache()
+
+	var heads atomic.Int32
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		size := "1000"
+		if strings.HasSuffix(r.URL.Path, "/large.jpg") {
+			size = "5000"
+		}
+		w.Header().Set("Content-Length", size)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer media.Close()
+
+	database.SetSetting("mms_max_media_bytes", "2000")
+
+	send := func(url string) int {
+		body := `{"from": "+1234567890", "to": "+0987654321", "media_urls": ["` + url + `"], "messaging_profile_id": "profile-1"}`
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := send(media.URL + "/small.jpg"); code != http.StatusOK {
+			t.Fatalf("Send %d: expected status %d, got %d", i+1, http.StatusOK, code)
+		}
+	}
+	if heads.Load() != 1 {
+		t.Errorf("Expected the second send to reuse the cached size, got %d HEAD requests", heads.Load())
+	}
+
+	if code := send(media.URL + "/large.jpg"); code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for oversized media, got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	// With caching off, every send sizes the media again
+	database.SetSetting("media_cache_ttl_seconds", "0")
+	clearMediaCache()
+	heads.Store(0)
+	send(media.URL + "/small.jpg")
+	send(media.URL + "/small.jpg")
+	if heads.Load() != 2 {
+		t.Errorf("Expected 2 HEAD requests with caching off, got %d", heads.Load())
+	}
+}
+
+func TestCacheMediaSize_Bounded(t *testing.T) {
+	clearMediaCache()
+	defer clearMediaCache()
+
+	now := time.Now()
+	for i := 0; i < maxMediaCacheEntries; i++ {
+		cacheMediaSize(fmt.Sprintf("https://example.com/%d.jpg", i), mediaEntry{size: 1, expires: now.Add(time.Duration(i+1) * time.Second)}, now)
+	}
+
+	// A full cache makes room by dropping the entry closest to expiring
+	cacheMediaSize("https://example.com/new.jpg", mediaEntry{size: 1, expires: now.Add(time.Hour)}, now)
+	if len(mediaSizes.entries) != maxMediaCacheEntries {
+		t.Errorf("Expected the cache to stay at %d entries, got %d", maxMediaCacheEntries, len(mediaSizes.entries))
+	}
+	if _, ok := mediaSizes.entries["https://example.com/0.jpg"]; ok {
+		t.Error("Expected the entry closest to expiring to be evicted")
+	}
+
+	// Ten seconds on, the nine remaining expired entries go at once to make room
+	later := now.Add(10 * time.Second)
+	cacheMediaSize("https://example.com/later.jpg", mediaEntry{size: 1, expires: later.Add(time.Hour)}, later)
+	if _, ok := mediaSizes.entries["https://example.com/5.jpg"]; ok {
+		t.Error("Expected expired entries to be dropped")
+	}
+	if len(mediaSizes.entries) != maxMediaCacheEntries-8 {
+		t.Errorf("Expected %d entries after dropping the expired ones, got %d", maxMediaCacheEntries-8, len(mediaSizes.entries))
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/sms"
+	"telnyx-mock/internal/validator"
+)
+
+// HandleListPoolNumbers handles GET /api/profiles/{id}/numbers
+func HandleListPoolNumbers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	numbers, err := database.GetPoolNumbers(chi.URLParam(r, "id"))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve pool numbers.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, numbers)
+}
+
+// HandleAddPoolNumber handles POST /api/profiles/{id}/numbers
+func HandleAddPoolNumber(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		PhoneNumber        string `json:"phone_number"`
+		DisplayName        string `json:"display_name"`
+		CampaignRegistered bool   `json:"campaign_registered"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+	if req.PhoneNumber == "" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'phone_number' parameter is required.", http.StatusBadRequest)
+		return
+	}
+
+	profileID := chi.URLParam(r, "id")
+	if err := database.AddPoolNumber(profileID, req.PhoneNumber, req.DisplayName, req.CampaignRegistered); err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to add pool number.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Number added to pool", map[string]interface{}{
+		"profile_id":          profileID,
+		"phone_number":        req.PhoneNumber,
+		"display_name":        req.DisplayName,
+		"campaign_registered": req.CampaignRegistered,
+	})
+
+	numbers, err := database.GetPoolNumbers(profileID)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve pool numbers.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, numbers)
+}
+
+// HandleDeletePoolNumber handles DELETE /api/profiles/{id}/numbers/{phone_number}
+func HandleDeletePoolNumber(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profileID := chi.URLParam(r, "id")
+	phoneNumber := chi.URLParam(r, "phone_number")
+	removed, err := database.RemovePoolNumber(profileID, phoneNumber)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to remove pool number.", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Number is not in this profile's pool.", http.StatusNotFound)
+		return
+	}
+
+	database.Log("system", "Number removed from pool", map[string]interface{}{
+		"profile_id":   profileID,
+		"phone_number": phoneNumber,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// poolSender picks the sending number from the profile's pool when a pool strategy is enabled,
+// returning "" to keep the requested from
+func poolSender(messagingProfileID string) string {
+	if database.GetNumberPoolStrategy() != "round_robin" {
+		return ""
+	}
+
+	number, err := database.NextPoolNumber(messagingProfileID)
+	if err != nil {
+		database.LogError("message", "Failed to pick number from pool", map[string]interface{}{
+			"error":      err.Error(),
+			"profile_id": messagingProfileID,
+		})
+		return ""
+	}
+	return number
+}
+
+// senderDisplayName returns the display name the profile maps the sending number to, or "" if
+// none is configured
+func senderDisplayName(messagingProfileID, from string) string {
+	displayName, err := database.GetPoolDisplayName(messagingProfileID, from)
+	if err != nil {
+		database.LogError("message", "Failed to look up sender display name", map[string]interface{}{
+			"error":      err.Error(),
+			"profile_id": messagingProfileID,
+			"from":       from,
+		})
+		return ""
+	}
+	return displayName
+}
+
+// isLongCode reports whether a number is a US/Canada 10-digit long code rather than a toll-free
+// number or short code
+func isLongCode(number string) bool {
+	return len(number) == 12 && strings.HasPrefix(number, "+1") && !sms.IsTollFree(number)
+}
+
+// unregistered10DLC reports whether enforce_10dlc is on and from is a long code that isn't
+// registered to a campaign in any number pool
+func unregistered10DLC(from string) bool {
+	if !database.GetBoolSetting("enforce_10dlc", false) || !isLongCode(from) {
+		return false
+	}
+
+	registered, err := database.IsCampaignRegistered(from)
+	if err != nil {
+		database.LogError("message", "Failed to look up 10DLC registration", map[string]interface{}{
+			"error": err.Error(),
+			"from":  from,
+		})
+		return false
+	}
+	return !registered
+}
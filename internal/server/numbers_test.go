@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+func TestNumberPool_RoundRobin(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("number_pool_strategy", "round_robin")
+	database.AddPoolNumber("profile-pool", "+15550000001", "", false)
+	database.AddPoolNumber("profile-pool", "+15550000002", "", false)
+	database.AddPoolNumber("profile-pool", "+15550000003", "", false)
+
+	expected := []string{"+15550000001", "+15550000002", "+15550000003", "+15550000001"}
+	for i, want := range expected {
+		data := createTestMessage(t, "profile-pool")
+		from := data["from"].(map[string]interface{})
+		if from["phone_number"] != want {
+			t.Errorf("Send %d: expected from '%s', got '%v'", i+1, want, from["phone_number"])
+		}
+		if from["messaging_profile_id"] != "profile-pool" {
+			t.Errorf("Send %d: expected from.messaging_profile_id 'profile-pool', got '%v'", i+1, from["messaging_profile_id"])
+		}
+
+		// The chosen number is recorded as the sender
+		msg, _ := database.GetMessageByID(data["id"].(string))
+		if msg == nil || msg.Sender != want {
+			t.Errorf("Send %d: expected stored sender '%s', got %+v", i+1, want, msg)
+		}
+	}
+
+	numbers, _ := database.GetPoolNumbers("profile-pool")
+	if numbers[0].UseCount != 2 || numbers[1].UseCount != 1 {
+		t.Errorf("Expected use counts [2 1 1], got %+v", numbers)
+	}
+}
+
+func TestNumberPool_EchoShowsRequestedFrom(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("debug_mode", "true")
+	database.SetSetting("number_pool_strategy", "round_robin")
+	database.AddPoolNumber("profile-pool", "+15550000001", "", false)
+
+	body := []byte(`{"from": "+1234567890", "to": "+0987654321", "text": "Hi", "messaging_profile_id": "profile-pool"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages?echo=true", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			From map[string]interface{} `json:"from"`
+		} `json:"data"`
+		Debug struct {
+			Received map[string]interface{} `json:"received"`
+		} `json:"_debug"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response.Data.From["phone_number"] != "+15550000001" {
+		t.Errorf("Expected the pool number as sender, got %v", response.Data.From["phone_number"])
+	}
+	// The echo shows what the client sent, not the sender the pool picked
+	if response.Debug.Received["from"] != "+1234567890" {
+		t.Errorf("Expected the echoed from '+1234567890', got %v", response.Debug.Received["from"])
+	}
+}
+
+func TestNumberPool_OffKeepsRequestedFrom(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.AddPoolNumber("profile-pool", "+15550000001", "", false)
+
+	data := createTestMessage(t, "profile-pool")
+	if from := data["from"].(map[string]interface{}); from["phone_number"] != "+1234567890" {
+		t.Errorf("Expected requested from '+1234567890', got '%v'", from["phone_number"])
+	}
+}
+
+func TestHandleCreateMessage_SenderDisplayName(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.AddPoolNumber("profile-named", "+1234567890", "Acme Alerts", false)
+
+	// Mapped sender
+	data := createTestMessage(t, "profile-named")
+	from := data["from"].(map[string]interface{})
+	if from["display_name"] != "Acme Alerts" {
+		t.Errorf("Expected display_name 'Acme Alerts', got '%v'", from["display_name"])
+	}
+
+	// Unmapped sender
+	data = sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1555000999",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-named",
+	})
+	from = data["from"].(map[string]interface{})
+	if _, ok := from["display_name"]; ok {
+		t.Errorf("Expected no display_name for an unmapped sender, got '%v'", from["display_name"])
+	}
+
+	// The mapping belongs to the profile
+	data = createTestMessage(t, "profile-other")
+	if _, ok := data["from"].(map[string]interface{})["display_name"]; ok {
+		t.Error("Expected no display_name for another profile's send")
+	}
+}
+
+func TestHandleCreateMessage_Enforce10DLC(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("enforce_10dlc", "true")
+	database.AddPoolNumber("profile-10dlc", "+15550000001", "", true)
+	database.AddPoolNumber("profile-10dlc", "+15550000002", "", false)
+
+	send := func(from string) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(map[string]interface{}{
+			"from":                 from,
+			"to":                   "+15557778888",
+			"text":                 "Hello",
+			"messaging_profile_id": "profile-10dlc",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		return rr
+	}
+
+	// Registered sender
+	if rr := send("+15550000001"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for a registered number, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// Unregistered senders, whether in the pool or not
+	for _, from := range []string{"+15550000002", "+15550000003"} {
+		rr := send(from)
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d for %s, got %d. Body: %s", http.StatusForbidden, from, rr.Code, rr.Body.String())
+		}
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		errObj := response["errors"].([]interface{})[0].(map[string]interface{})
+		if errObj["code"] != "40010" {
+			t.Errorf("Expected error code '40010', got '%v'", errObj["code"])
+		}
+		want := validator.ErrorCatalog["40010"]
+		if errObj["title"] != want.Title || errObj["detail"] != want.Detail {
+			t.Errorf("Expected the catalog's title and detail for 40010, got %v", errObj)
+		}
+	}
+
+	// Toll-free numbers aren't subject to 10DLC
+	if rr := send("+18005550000"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a toll-free number, got %d", http.StatusOK, rr.Code)
+	}
+
+	// Off by default
+	database.SetSetting("enforce_10dlc", "false")
+	if rr := send("+15550000002"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d with enforcement off, got %d", http.StatusOK, rr.Code)
+	}
+}
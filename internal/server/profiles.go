@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// HandleListProfiles handles GET /api/profiles
+func HandleListProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profiles, err := database.GetProfiles()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve messaging profiles.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+// HandleGetProfile handles GET /api/profiles/{id}
+func HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := database.GetProfile(chi.URLParam(r, "id"))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve messaging profile.", http.StatusInternalServerError)
+		return
+	}
+	if profile == nil {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Messaging profile not found.", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// HandleSaveProfile handles POST /api/profiles (create or update)
+func HandleSaveProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req database.MessagingProfile
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+
+	if req.ID == "" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'id' parameter is required.", http.StatusBadRequest)
+		return
+	}
+
+	overrides := req.ResponseOverrides
+	if overrides.Type != "" && overrides.Type != "SMS" && overrides.Type != "MMS" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'response_overrides.type' must be 'SMS' or 'MMS'.", http.StatusBadRequest)
+		return
+	}
+	if overrides.Encoding != "" && overrides.Encoding != "GSM-7" && overrides.Encoding != "UCS-2" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'response_overrides.encoding' must be 'GSM-7' or 'UCS-2'.", http.StatusBadRequest)
+		return
+	}
+	if field := protectedField(overrides.ExtraFields); field != "" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'response_overrides.extra_fields' cannot overwrite the protected field '"+field+"'.", http.StatusBadRequest)
+		return
+	}
+
+	if req.DailySpendLimit < 0 {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'daily_spend_limit' must not be negative.", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.UpsertProfile(req); err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save messaging profile.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Messaging profile saved", map[string]interface{}{
+		"profile_id": req.ID,
+		"name":       req.Name,
+	})
+
+	profile, err := database.GetProfile(req.ID)
+	if err != nil || profile == nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve saved messaging profile.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// HandleDeleteProfile handles DELETE /api/profiles/{id}
+func HandleDeleteProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	deleted, err := database.DeleteProfile(id)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to delete messaging profile.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Messaging profile not found.", http.StatusNotFound)
+		return
+	}
+
+	database.Log("system", "Messaging profile deleted", map[string]interface{}{
+		"profile_id": id,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// profileOverrides returns the response overrides for a messaging profile, or none if it's unknown
+func profileOverrides(messagingProfileID string) database.ResponseOverrides {
+	profile, err := database.GetProfile(messagingProfileID)
+	if err != nil {
+		database.LogError("message", "Failed to look up messaging profile", map[string]interface{}{
+			"error":      err.Error(),
+			"profile_id": messagingProfileID,
+		})
+		return database.ResponseOverrides{}
+	}
+	if profile == nil {
+		return database.ResponseOverrides{}
+	}
+	return profile.ResponseOverrides
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestProfileOverrides_DifferentDefaults(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.UpsertProfile(database.MessagingProfile{
+		ID:   "profile-mms",
+		Name: "MMS account",
+		ResponseOverrides: database.ResponseOverrides{
+			Type:     "MMS",
+			Encoding: "UCS-2",
+		},
+	})
+	database.UpsertProfile(database.MessagingProfile{
+		ID:   "profile-plain",
+		Name: "Plain account",
+	})
+
+	data := createTestMessage(t, "profile-mms")
+	if data["type"] != "MMS" {
+		t.Errorf("Expected type 'MMS' for profile-mms, got '%v'", data["type"])
+	}
+	if data["encoding"] != "UCS-2" {
+		t.Errorf("Expected encoding 'UCS-2' for profile-mms, got '%v'", data["encoding"])
+	}
+
+	data = createTestMessage(t, "profile-plain")
+	if data["type"] != "SMS" {
+		t.Errorf("Expected type 'SMS' for profile-plain, got '%v'", data["type"])
+	}
+	if data["encoding"] != "GSM-7" {
+		t.Errorf("Expected encoding 'GSM-7' for profile-plain, got '%v'", data["encoding"])
+	}
+}
+
+func TestHandleSaveProfile_InvalidOverride(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := map[string]interface{}{
+		"id":                 "profile-123",
+		"response_overrides": map[string]string{"type": "FAX"},
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/profiles", bytes.NewReader(bodyBytes))
+	rr := httptest.NewRecorder()
+	HandleSaveProfile(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
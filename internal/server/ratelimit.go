@@ -0,0 +1,119 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// Header values reported when the limiter is off, so clients that pace themselves still see a budget
+const (
+	staticRateLimit     = 1000
+	staticRateRemaining = 1000
+	staticRateReset     = 0
+)
+
+// rateLimiter is a token bucket refilled continuously at limitPerMinute tokens per minute
+type rateLimiter struct {
+	mu             sync.Mutex
+	limitPerMinute int
+	tokens         float64
+	last           time.Time
+}
+
+// messageLimiter limits requests to the message-sending endpoints
+var messageLimiter = &rateLimiter{}
+
+// inboundLimiter limits requests to the inbound webhook endpoints
+var inboundLimiter = &rateLimiter{}
+
+// DefaultInboundRateLimit is the inbound webhook limit (requests per minute) when not configured;
+// high enough that normal test traffic never hits it
+const DefaultInboundRateLimit = 6000
+
+// take reconfigures the bucket if the limit changed, refills it, and tries to consume one token.
+// It returns whether the request is allowed, the tokens remaining, and the seconds until the bucket is full.
+func (l *rateLimiter) take(limitPerMinute int, now time.Time) (allowed bool, remaining int, reset int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limitPerMinute != l.limitPerMinute {
+		l.limitPerMinute = limitPerMinute
+		l.tokens = float64(limitPerMinute)
+		l.last = now
+	}
+
+	ratePerSecond := float64(limitPerMinute) / 60
+	l.tokens = math.Min(float64(limitPerMinute), l.tokens+now.Sub(l.last).Seconds()*ratePerSecond)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		allowed = true
+	}
+
+	reset = int(math.Ceil((float64(limitPerMinute) - l.tokens) / ratePerSecond))
+	return allowed, int(l.tokens), reset
+}
+
+// RateLimitMiddleware enforces the api_rate_limit setting (requests per minute, 0 = off) and
+// reports the bucket state in X-Rate-Limit-* headers on every response
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return rateLimit(next, messageLimiter, "api_rate_limit", 0, "message")
+}
+
+// InboundRateLimitMiddleware enforces the inbound_rate_limit setting (requests per minute,
+// 0 = off) on the inbound webhook endpoints so a misconfigured source can't flood the database
+func InboundRateLimitMiddleware(next http.Handler) http.Handler {
+	return rateLimit(next, inboundLimiter, "inbound_rate_limit", DefaultInboundRateLimit, "webhook")
+}
+
+// rateLimit wraps next with a token bucket whose per-minute limit is read from setting on each request
+func rateLimit(next http.Handler, limiter *rateLimiter, setting string, defaultLimit int, category string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := database.GetIntSetting(setting, defaultLimit)
+		if limit <= 0 {
+			setRateLimitHeaders(w, staticRateLimit, staticRateRemaining, staticRateReset)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, remaining, reset := limiter.take(limit, time.Now())
+		setRateLimitHeaders(w, limit, remaining, reset)
+
+		if !allowed {
+			database.LogWarning(category, "Request rate limited", map[string]interface{}{
+				"limit": limit,
+				"path":  r.URL.Path,
+				"ip":    r.RemoteAddr,
+			})
+			setRetryAfter(w, int(math.Ceil(60/float64(limit))), time.Now())
+			validator.WriteError(w, "10011", "Too many requests", "[SmsSink] Rate limit exceeded.", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setRateLimitHeaders writes the X-Rate-Limit-* headers
+func setRateLimitHeaders(w http.ResponseWriter, limit, remaining, reset int) {
+	w.Header().Set("X-Rate-Limit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-Rate-Limit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-Rate-Limit-Reset", strconv.Itoa(reset))
+}
+
+// setRetryAfter writes the Retry-After header for a wait of seconds, rendered per the
+// retry_after_format setting: delta "seconds" (default) or an "http-date" that many seconds after now
+func setRetryAfter(w http.ResponseWriter, seconds int, now time.Time) {
+	value := strconv.Itoa(seconds)
+	if database.GetRetryAfterFormat() == "http-date" {
+		value = now.Add(time.Duration(seconds) * time.Second).UTC().Format(http.TimeFormat)
+	}
+	w.Header().Set("Retry-After", value)
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestRateLimitMiddleware_HeadersDecrement(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	messageLimiter = &rateLimiter{}
+	database.SetSetting("api_rate_limit", "3")
+
+	handler := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i, expected := range []string{"2", "1", "0"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v2/messages", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusOK, rr.Code)
+		}
+		if rr.Header().Get("X-Rate-Limit-Limit") != "3" {
+			t.Errorf("Request %d: expected limit '3', got '%s'", i+1, rr.Header().Get("X-Rate-Limit-Limit"))
+		}
+		if got := rr.Header().Get("X-Rate-Limit-Remaining"); got != expected {
+			t.Errorf("Request %d: expected remaining '%s', got '%s'", i+1, expected, got)
+		}
+		if rr.Header().Get("X-Rate-Limit-Reset") == "" {
+			t.Errorf("Request %d: expected a reset header", i+1)
+		}
+	}
+
+	// Bucket is empty
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v2/messages", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d once the bucket is empty, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on rate-limited response")
+	}
+}
+
+func TestRateLimitMiddleware_StaticHeadersWhenOff(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v2/messages", nil))
+
+	if rr.Header().Get("X-Rate-Limit-Limit") != "1000" || rr.Header().Get("X-Rate-Limit-Remaining") != "1000" {
+		t.Errorf("Expected static rate limit headers, got %v", rr.Header())
+	}
+}
+
+func TestRateLimiter_Refills(t *testing.T) {
+	limiter := &rateLimiter{}
+	now := time.Now()
+
+	limiter.take(60, now) // 60/min = one token per second
+	for i := 0; i < 59; i++ {
+		limiter.take(60, now)
+	}
+	if allowed, _, _ := limiter.take(60, now); allowed {
+		t.Fatal("Expected bucket to be empty")
+	}
+	if allowed, _, _ := limiter.take(60, now.Add(time.Second)); !allowed {
+		t.Error("Expected a token after one second")
+	}
+}
+
+func TestInboundRateLimitMiddleware_ExceedsLimit(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	inboundLimiter = &rateLimiter{}
+	handler := InboundRateLimitMiddleware(http.HandlerFunc(HandleInboundWebhook))
+	send := func() *httptest.ResponseRecorder {
+		body := `{"from": "+15551234567", "to": "+15557654321", "text": "hi"}`
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v2/webhooks/messages", strings.NewReader(body)))
+		return rr
+	}
+
+	// The default limit is high enough for normal traffic
+	if rr := send(); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d under the default limit, got %d", http.StatusOK, rr.Code)
+	}
+
+	database.SetSetting("inbound_rate_limit", "2")
+	for i := 0; i < 2; i++ {
+		if rr := send(); rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusOK, rr.Code)
+		}
+	}
+
+	rr := send()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d over the limit, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected Retry-After '30', got '%s'", rr.Header().Get("Retry-After"))
+	}
+	if messages, _ := database.GetAllMessages(); len(messages) != 3 {
+		t.Errorf("Expected the rate-limited request not to be stored, got %d messages", len(messages))
+	}
+}
+
+func TestSetRetryAfter_Formats(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", "30"},
+		{"seconds", "30"},
+		{"http-date", "Mon, 01 Jan 2024 12:00:30 GMT"},
+	}
+
+	for _, tt := range tests {
+		database.SetSetting("retry_after_format", tt.format)
+		rr := httptest.NewRecorder()
+		setRetryAfter(rr, 30, now)
+		if got := rr.Header().Get("Retry-After"); got != tt.want {
+			t.Errorf("Format %q: expected Retry-After %q, got %q", tt.format, tt.want, got)
+		}
+	}
+
+	// The rendered date parses back with the standard HTTP date parser
+	database.SetSetting("retry_after_format", "http-date")
+	rr := httptest.NewRecorder()
+	setRetryAfter(rr, 30, now)
+	if parsed, err := http.ParseTime(rr.Header().Get("Retry-After")); err != nil || !parsed.Equal(now.Add(30*time.Second)) {
+		t.Errorf("Expected a parseable HTTP date 30s ahead, got %v (%v)", parsed, err)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// RawRequestBufferSize is how many API requests the raw-request buffer keeps
+const RawRequestBufferSize = 50
+
+// MaxRawRequestBody is the most body bytes captured per request; larger requests can't be replayed
+const MaxRawRequestBody = 1 << 20
+
+// rawRequest is an API request captured byte for byte
+type rawRequest struct {
+	method     string
+	uri        string
+	header     http.Header
+	body       []byte
+	truncated  bool
+	capturedAt time.Time
+}
+
+// rawRequests is a ring buffer of the most recent API requests, newest last
+var rawRequests = struct {
+	mu      sync.Mutex
+	entries []rawRequest
+}{}
+
+// replayKey marks a request as a replay so it isn't captured a second time
+type replayKey struct{}
+
+// replayHandler is the API handler chain replays are dispatched through; set by SetReplayHandler
+var replayHandler http.Handler
+
+// SetReplayHandler sets the handler chain captured requests are replayed through, normally the
+// API router so replays see the same middleware as the original request
+func SetReplayHandler(h http.Handler) {
+	replayHandler = h
+}
+
+// RawRequestCaptureMiddleware records each API request's method, URI, headers and body in the
+// raw-request buffer while debug mode is on
+func RawRequestCaptureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isDebugMode() || r.Context().Value(replayKey{}) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, MaxRawRequestBody+1))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Hand the handler the bytes already read followed by anything past the capture limit
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		captured := rawRequest{
+			method:     r.Method,
+			uri:        r.URL.RequestURI(),
+			header:     r.Header.Clone(),
+			body:       body,
+			capturedAt: time.Now().UTC(),
+		}
+		if len(body) > MaxRawRequestBody {
+			captured.body, captured.truncated = body[:MaxRawRequestBody], true
+		}
+
+		rawRequests.mu.Lock()
+		rawRequests.entries = append(rawRequests.entries, captured)
+		if len(rawRequests.entries) > RawRequestBufferSize {
+			rawRequests.entries = rawRequests.entries[len(rawRequests.entries)-RawRequestBufferSize:]
+		}
+		rawRequests.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rawRequestAt returns the captured request at index, where 0 is the most recent
+func rawRequestAt(index int) (rawRequest, bool) {
+	rawRequests.mu.Lock()
+	defer rawRequests.mu.Unlock()
+	if index < 0 || index >= len(rawRequests.entries) {
+		return rawRequest{}, false
+	}
+	return rawRequests.entries[len(rawRequests.entries)-1-index], true
+}
+
+// clearRawRequests empties the raw-request buffer
+func clearRawRequests() {
+	rawRequests.mu.Lock()
+	rawRequests.entries = nil
+	rawRequests.mu.Unlock()
+}
+
+// HandleListRawRequests handles GET /api/requests/raw, newest first. Authorization headers are
+// redacted; replays still send the captured value.
+func HandleListRawRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isDebugMode() {
+		validator.WriteError(w, "10010", "Forbidden", "[SmsSink] This endpoint is only available in debug mode.", http.StatusForbidden)
+		return
+	}
+
+	rawRequests.mu.Lock()
+	entries := make([]map[string]interface{}, len(rawRequests.entries))
+	for i, req := range rawRequests.entries {
+		header := req.header.Clone()
+		if header.Get("Authorization") != "" {
+			header.Set("Authorization", "[redacted]")
+		}
+		entries[len(entries)-1-i] = map[string]interface{}{
+			"index":       len(entries) - 1 - i,
+			"method":      req.method,
+			"uri":         req.uri,
+			"headers":     header,
+			"body":        string(req.body),
+			"truncated":   req.truncated,
+			"captured_at": req.capturedAt,
+		}
+	}
+	rawRequests.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": entries})
+}
+
+// HandleReplayRawRequest handles POST /api/requests/raw/{index}/replay, re-dispatching a captured
+// request's exact bytes through the API handler chain and returning its fresh response. The
+// captured Authorization header is sent as-is unless the replay request supplies its own, so a
+// request captured before a key rotation can be retried with the current key.
+func HandleReplayRawRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isDebugMode() {
+		validator.WriteError(w, "10010", "Forbidden", "[SmsSink] This endpoint is only available in debug mode.", http.StatusForbidden)
+		return
+	}
+
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The index must be an integer.", http.StatusBadRequest)
+		return
+	}
+	captured, ok := rawRequestAt(index)
+	if !ok {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] No captured request at that index.", http.StatusNotFound)
+		return
+	}
+	if captured.truncated {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The captured request body was truncated and can't be replayed exactly.", http.StatusBadRequest)
+		return
+	}
+	if replayHandler == nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Request replay is not configured.", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop this router's route context so the API router routes the replay from scratch
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, nil)
+	ctx = context.WithValue(ctx, replayKey{}, true)
+	replay, err := http.NewRequestWithContext(ctx, captured.method, captured.uri, bytes.NewReader(captured.body))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to rebuild captured request.", http.StatusInternalServerError)
+		return
+	}
+	replay.Header = captured.header.Clone()
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		replay.Header.Set("Authorization", auth)
+	}
+	replay.RemoteAddr = r.RemoteAddr
+
+	database.Log("system", "Replaying captured request", map[string]interface{}{
+		"index":  index,
+		"method": captured.method,
+		"uri":    captured.uri,
+	})
+
+	replayHandler.ServeHTTP(w, replay)
+}
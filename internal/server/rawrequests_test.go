@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleReplayRawRequest_ReplaysCreate(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	clearRawRequests()
+	defer clearRawRequests()
+
+	apiRouter := chi.NewRouter()
+	apiRouter.Use(RawRequestCaptureMiddleware)
+	apiRouter.Post("/v2/messages", HandleCreateMessage)
+	SetReplayHandler(apiRouter)
+	defer SetReplayHandler(nil)
+
+	uiRouter := chi.NewRouter()
+	uiRouter.Get("/api/requests/raw", HandleListRawRequests)
+	uiRouter.Post("/api/requests/raw/{index}/replay", HandleReplayRawRequest)
+
+	replay := func(index string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		uiRouter.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/requests/raw/"+index+"/replay", nil))
+		return rr
+	}
+
+	if rr := replay("0"); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d outside debug mode, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	database.SetSetting("debug_mode", "true")
+
+	body := `{"from": "+1234567890", "to": "+0987654321", "text": "Replay me", "messaging_profile_id": "profile-123"}`
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	apiRouter.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected original create to succeed, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	// The listing shows the exact body but not the credential
+	list := httptest.NewRecorder()
+	uiRouter.ServeHTTP(list, httptest.NewRequest(http.MethodGet, "/api/requests/raw", nil))
+	if strings.Contains(list.Body.String(), "test-token") {
+		t.Error("Expected the Authorization header to be redacted in the listing")
+	}
+	if !strings.Contains(list.Body.String(), "Replay me") {
+		t.Errorf("Expected the captured body in the listing, got %s", list.Body.String())
+	}
+
+	rr = replay("0")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected replay status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response map[string]map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["data"]["text"] != "Replay me" {
+		t.Errorf("Expected the replayed message's text, got %v", response["data"]["text"])
+	}
+
+	messages, _ := database.GetMessages(database.MessageFilter{})
+	if len(messages) != 2 {
+		t.Errorf("Expected the replay to create a second message, got %d", len(messages))
+	}
+
+	// Replays aren't captured, so index 1 is out of range
+	if rr := replay("1"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an uncaptured index, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// A replay can supply different credentials
+	overridden := httptest.NewRequest(http.MethodPost, "/api/requests/raw/0/replay", nil)
+	overridden.Header.Set("Authorization", "Bearer wrong-token")
+	rr = httptest.NewRecorder()
+	uiRouter.ServeHTTP(rr, overridden)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d with overridden credentials, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+	"telnyx-mock/internal/webhook"
+)
+
+// HandleReset handles DELETE /api/reset?confirm=true
+func HandleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Require explicit confirmation so a stray request can't wipe everything
+	if r.URL.Query().Get("confirm") != "true" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Resetting deletes all data; pass 'confirm=true' to proceed.", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.ResetAll(); err != nil {
+		database.LogError("system", "Failed to reset mock", map[string]interface{}{
+			"error": err.Error(),
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to reset.", http.StatusInternalServerError)
+		return
+	}
+
+	resetRequestCounts()
+	clearMediaCache()
+	clearRawRequests()
+	cancelBursts()
+	clearManualStatus()
+	webhook.CancelPending()
+
+	// Reclaim the space freed by the reset; a failure here doesn't undo the reset
+	if _, _, err := database.Vacuum(); err != nil {
+		database.LogWarning("system", "Failed to vacuum database after reset", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	database.Log("system", "Mock reset to a clean state", nil)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
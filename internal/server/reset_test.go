@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleReset(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.InsertMessage("test-id", "+111", "+222", "test", []string{}, "profile-1", "outbound")
+	database.UpsertProfile(database.MessagingProfile{ID: "profile-1", Name: "Test"})
+	database.AddBlockedNumber("+15550000000", "test")
+	database.AddOptOut("+15551111111", "STOP")
+	database.SetAutoReply("HELP", "Help text", false)
+	database.SetSetting("message_validity_hours", "2")
+	database.SetCredential("custom-key")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/reset?confirm=true", nil)
+	rr := httptest.NewRecorder()
+	HandleReset(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if messages, _ := database.GetAllMessages(); len(messages) != 0 {
+		t.Errorf("Expected messages cleared, got %d", len(messages))
+	}
+	if profiles, _ := database.GetProfiles(); len(profiles) != 0 {
+		t.Errorf("Expected profiles cleared, got %d", len(profiles))
+	}
+	if blocked, _ := database.GetBlockedNumbers(); len(blocked) != 0 {
+		t.Errorf("Expected blocked numbers cleared, got %d", len(blocked))
+	}
+	if optOuts, _ := database.GetOptOuts(); len(optOuts) != 0 {
+		t.Errorf("Expected opt-outs cleared, got %d", len(optOuts))
+	}
+	if replies, _ := database.GetAutoReplies(); len(replies) != 0 {
+		t.Errorf("Expected auto-replies cleared, got %d", len(replies))
+	}
+	if hours := database.GetMessageValidityHours(); hours != database.DefaultMessageValidityHours {
+		t.Errorf("Expected default validity hours, got %d", hours)
+	}
+	if cred, _ := database.GetCredential(); cred.APIKey != database.DefaultAPIKey {
+		t.Errorf("Expected default API key, got '%s'", cred.APIKey)
+	}
+}
+
+func TestHandleReset_RequiresConfirmation(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.InsertMessage("test-id", "+111", "+222", "test", []string{}, "profile-1", "outbound")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/reset", nil)
+	rr := httptest.NewRecorder()
+	HandleReset(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if messages, _ := database.GetAllMessages(); len(messages) != 1 {
+		t.Errorf("Expected messages untouched without confirmation, got %d", len(messages))
+	}
+}
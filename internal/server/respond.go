@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"telnyx-mock/internal/database"
+)
+
+// prettyResponseWriter marks a response as one that should be written with indentation
+type prettyResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Flush passes through to the underlying writer so streaming handlers keep working
+func (p *prettyResponseWriter) Flush() {
+	if f, ok := p.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// prettyJSON reports whether responses should be indented, via ?pretty=true or the
+// SMSSINK_PRETTY_JSON env var
+func prettyJSON(r *http.Request) bool {
+	return r.URL.Query().Get("pretty") == "true" || os.Getenv("SMSSINK_PRETTY_JSON") == "true"
+}
+
+// PrettyJSONMiddleware makes writeJSON indent its output for requests that ask for it
+func PrettyJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if prettyJSON(r) {
+			w = &prettyResponseWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as a JSON response with the given status code. Every handler
+// goes through here so Content-Type, status and encoding stay consistent.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	if _, ok := w.(*prettyResponseWriter); ok {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		database.LogError("system", "Failed to encode JSON response", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// version is the running SmsSink version reported by HandleVersion; set by SetVersion
+var version string
+
+// SetVersion sets the version HandleVersion reports, normally main's build version
+func SetVersion(v string) {
+	version = v
+}
+
+// HandleVersion handles GET /api/version
+func HandleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"version": version})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.InsertMessage("test-id", "+111", "+222", "test", []string{}, "profile-1", "outbound")
+	handler := PrettyJSONMiddleware(http.HandlerFunc(HandleListMessages))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/messages", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if strings.Contains(rr.Body.String(), "\n  ") {
+		t.Errorf("Expected compact JSON by default, got %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/messages?pretty=true", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "\n  {\n    \"id\": \"test-id\"") {
+		t.Errorf("Expected indented JSON with ?pretty=true, got %s", rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got '%s'", rr.Header().Get("Content-Type"))
+	}
+
+	t.Setenv("SMSSINK_PRETTY_JSON", "true")
+	req = httptest.NewRequest(http.MethodGet, "/api/messages", nil)
+	if !prettyJSON(req) {
+		t.Error("Expected SMSSINK_PRETTY_JSON to enable pretty output")
+	}
+}
+
+func TestWriteJSON_EncodeErrorLogged(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	rr := httptest.NewRecorder()
+	writeJSON(rr, http.StatusOK, map[string]interface{}{"bad": make(chan int)})
+
+	logs, _ := database.GetLogs("error", "system", 10)
+	found := false
+	for _, l := range logs {
+		if l.Message == "Failed to encode JSON response" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected encode failure to be logged")
+	}
+}
+
+func TestDeleteResponsesAreJSON(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/messages", nil)
+	rr := httptest.NewRecorder()
+	HandleClearMessages(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got '%s'", rr.Header().Get("Content-Type"))
+	}
+	if strings.TrimSpace(rr.Body.String()) != `{"status":"success"}` {
+		t.Errorf("Expected success body, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	SetVersion("9.9.9")
+	defer SetVersion("")
+
+	rr := httptest.NewRecorder()
+	HandleVersion(rr, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type 'application/json', got '%s'", ct)
+	}
+	var response map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["version"] != "9.9.9" {
+		t.Errorf("Expected version '9.9.9', got %v", response)
+	}
+}
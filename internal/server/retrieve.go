@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/sms"
+	"telnyx-mock/internal/validator"
+)
+
+// HandleRetrieveMessage handles GET /v2/messages/{id}, returning the stored message in Telnyx's
+// format with its current status, so clients that poll instead of using webhooks can follow delivery
+func HandleRetrieveMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if statusCode, errResp := validator.ValidateAuthorization(r); errResp != nil {
+		writeJSON(w, statusCode, errResp)
+		return
+	}
+
+	msg, err := database.GetMessageByID(chi.URLParam(r, "id"))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve message.", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil || msg.DeletedAt != nil {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Message not found.", http.StatusNotFound)
+		return
+	}
+
+	// A group message reports each participant's own status
+	recipients, err := database.GetMessageRecipients(msg.ID)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve message.", http.StatusInternalServerError)
+		return
+	}
+	if len(recipients) == 0 {
+		recipients = []database.MessageRecipient{{PhoneNumber: msg.Recipient, Status: msg.Status}}
+	}
+
+	details := messageDetailsFromRecord(*msg)
+	var validUntil interface{}
+	if msg.ValidUntil != nil {
+		validUntil = msg.ValidUntil.Format(time.RFC3339)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":                   msg.ID,
+			"record_type":          "message",
+			"direction":            msg.Direction,
+			"messaging_profile_id": msg.MessagingProfileID,
+			"from": map[string]interface{}{
+				"phone_number": msg.Sender,
+				"carrier":      sms.MockCarrier,
+				"line_type":    sms.LineType(msg.Sender),
+			},
+			"to":                   recipientEntries(recipients),
+			"text":                 msg.Content,
+			"media":                details.MediaURLs,
+			"type":                 details.Type,
+			"parts":                details.Parts,
+			"cost":                 details.Cost,
+			"valid_until":          validUntil,
+			"webhook_url":          msg.WebhookURL,
+			"webhook_failover_url": msg.WebhookFailoverURL,
+			"received_at":          msg.ReceivedAt.Format(time.RFC3339),
+			"created_at":           msg.CreatedAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// recipientEntries lists a message's recipients, each with its status, for a response's to array
+func recipientEntries(recipients []database.MessageRecipient) []map[string]interface{} {
+	to := make([]map[string]interface{}, 0, len(recipients))
+	for _, recipient := range recipients {
+		to = append(to, map[string]interface{}{
+			"phone_number": recipient.PhoneNumber,
+			"status":       recipient.Status,
+			"carrier":      sms.MockCarrier,
+			"line_type":    sms.LineType(recipient.PhoneNumber),
+		})
+	}
+	return to
+}
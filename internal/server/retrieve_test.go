@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleRetrieveMessage_PollUntilDelivered(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("webhook_initial_delay_ms", "0")
+
+	// No webhook_url: the status must still advance for pollers
+	data := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Poll me",
+		"messaging_profile_id": "profile-123",
+		"webhook_delay_ms":     20,
+	})
+	id := data["id"].(string)
+
+	router := chi.NewRouter()
+	router.Get("/v2/messages/{id}", HandleRetrieveMessage)
+	poll := func() (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/v2/messages/"+id, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var response struct {
+			Data struct {
+				To []struct {
+					Status string `json:"status"`
+				} `json:"to"`
+			} `json:"data"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if len(response.Data.To) == 0 {
+			return rr.Code, ""
+		}
+		return rr.Code, response.Data.To[0].Status
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		code, status := poll()
+		if code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+		}
+		if status == "delivered" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the message to reach 'delivered', last saw '%s'", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandleRetrieveMessage_Errors(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	router := chi.NewRouter()
+	router.Get("/v2/messages/{id}", HandleRetrieveMessage)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v2/messages/unknown", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without credentials, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/messages/unknown", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown message, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/simrand"
+	"telnyx-mock/internal/validator"
+)
+
+// maxSettingsProfileName bounds settings profile names, which appear in URLs
+const maxSettingsProfileName = 64
+
+// HandleListSettingsProfiles handles GET /api/settings/profiles
+func HandleListSettingsProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profiles, err := database.GetSettingsProfiles()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve settings profiles.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+// HandleSaveSettingsProfile handles POST /api/settings/profiles, saving the current settings under
+// the given name (replacing any profile with that name)
+func HandleSaveSettingsProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || len(req.Name) > maxSettingsProfileName {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] The 'name' parameter is required and must be at most 64 characters.", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.SaveSettingsProfile(req.Name); err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to save settings profile.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Settings profile saved", map[string]interface{}{
+		"name": req.Name,
+	})
+
+	profile, err := database.GetSettingsProfile(req.Name)
+	if err != nil || profile == nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve saved settings profile.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// HandleApplySettingsProfile handles POST /api/settings/profiles/{name}/apply, replacing the
+// current settings with the profile's and returning the effective settings
+func HandleApplySettingsProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	profile, err := database.GetSettingsProfile(name)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve settings profile.", http.StatusInternalServerError)
+		return
+	}
+	if profile == nil {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Settings profile not found.", http.StatusNotFound)
+		return
+	}
+
+	if err := database.ApplySettingsProfile(*profile); err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to apply settings profile.", http.StatusInternalServerError)
+		return
+	}
+
+	// Setting random_seed reseeds the simulations, so restoring it does too
+	if seed, err := strconv.ParseInt(profile.Settings["random_seed"], 10, 64); err == nil {
+		simrand.Seed(seed)
+	}
+
+	database.Log("system", "Settings profile applied", map[string]interface{}{
+		"name":     name,
+		"settings": profile.Settings,
+	})
+
+	writeJSON(w, http.StatusOK, currentSettings())
+}
+
+// HandleDeleteSettingsProfile handles DELETE /api/settings/profiles/{name}
+func HandleDeleteSettingsProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only DELETE method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	deleted, err := database.DeleteSettingsProfile(name)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to delete settings profile.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Settings profile not found.", http.StatusNotFound)
+		return
+	}
+
+	database.Log("system", "Settings profile deleted", map[string]interface{}{
+		"name": name,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
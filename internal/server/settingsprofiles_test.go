@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+)
+
+func TestSettingsProfiles_SaveAndApply(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	router := chi.NewRouter()
+	router.Post("/api/settings", HandleSetSettings)
+	router.Post("/api/settings/profiles", HandleSaveSettingsProfile)
+	router.Post("/api/settings/profiles/{name}/apply", HandleApplySettingsProfile)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := post("/api/settings", `{"message_validity_hours": 48, "carrier_reject_token": "FAIL"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := post("/api/settings/profiles", `{"name": "flaky-carrier"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d saving the profile, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// Change one saved setting and add one the profile doesn't have
+	post("/api/settings", `{"message_validity_hours": 12, "debug_mode": true}`)
+
+	rr := post("/api/settings/profiles/flaky-carrier/apply", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d applying the profile, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var settings map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &settings)
+	if settings["message_validity_hours"] != float64(48) {
+		t.Errorf("Expected message_validity_hours restored to 48, got %v", settings["message_validity_hours"])
+	}
+	if settings["carrier_reject_token"] != "FAIL" {
+		t.Errorf("Expected carrier_reject_token 'FAIL', got %v", settings["carrier_reject_token"])
+	}
+	if database.IsDebugMode() {
+		t.Error("Expected debug_mode, set after the snapshot, to revert to its default")
+	}
+
+	if rr := post("/api/settings/profiles/missing/apply", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown profile, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := post("/api/settings/profiles", `{"name": ""}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without a name, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestSettingsProfiles_ApplyAuditsChanges(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	router := chi.NewRouter()
+	router.Post("/api/settings/profiles/{name}/apply", HandleApplySettingsProfile)
+
+	database.SetSetting("message_validity_hours", "48")
+	database.SetSetting("carrier_reject_token", "FAIL")
+	if err := database.SaveSettingsProfile("flaky-carrier"); err != nil {
+		t.Fatalf("Failed to save profile: %v", err)
+	}
+	database.SetSetting("message_validity_hours", "12")
+	database.SetSetting("debug_mode", "true")
+	database.ClearAllLogs()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/settings/profiles/flaky-carrier/apply", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	logs, _ := database.FilterLogs(database.LogFilter{Category: "system"}, 10)
+	changes := map[string][2]interface{}{}
+	for _, entry := range logs {
+		if entry.Message != "Setting changed" {
+			continue
+		}
+		var details map[string]interface{}
+		json.Unmarshal([]byte(entry.Details), &details)
+		changes[details["key"].(string)] = [2]interface{}{details["old_value"], details["new_value"]}
+	}
+
+	// The unchanged carrier_reject_token isn't audited
+	want := map[string][2]interface{}{
+		"debug_mode":             {"true", ""},
+		"message_validity_hours": {"12", "48"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d 'Setting changed' entries, got %v", len(want), changes)
+	}
+	for key, values := range want {
+		if changes[key] != values {
+			t.Errorf("Expected %s audited as %v, got %v", key, values, changes[key])
+		}
+	}
+}
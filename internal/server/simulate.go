@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"telnyx-mock/internal/validator"
+)
+
+// HandleSimulateError handles POST /api/simulate/error, returning the catalog entry for the
+// requested code verbatim so clients can exercise every error shape
+func HandleSimulateError(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+
+	entry, ok := validator.LookupError(req.Code)
+	if !ok {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Unknown error code: '"+req.Code+"'.", http.StatusBadRequest)
+		return
+	}
+
+	validator.WriteError(w, entry.Code, entry.Title, entry.Detail, entry.Status)
+}
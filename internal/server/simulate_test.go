@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telnyx-mock/internal/validator"
+)
+
+func TestHandleSimulateError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate/error", strings.NewReader(`{"code": "40300"}`))
+	rr := httptest.NewRecorder()
+	HandleSimulateError(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	var resp validator.TelnyxErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	want := validator.ErrorCatalog["40300"]
+	if len(resp.Errors) != 1 || resp.Errors[0].Code != "40300" || resp.Errors[0].Title != want.Title || resp.Errors[0].Detail != want.Detail {
+		t.Errorf("Expected catalog entry for 40300, got %+v", resp.Errors)
+	}
+}
+
+func TestHandleSimulateError_UnknownCode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate/error", strings.NewReader(`{"code": "99999"}`))
+	rr := httptest.NewRecorder()
+	HandleSimulateError(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
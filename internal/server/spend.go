@@ -0,0 +1,39 @@
+package server
+
+import (
+	"time"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/sms"
+)
+
+// chargeDailySpend adds a message's cost to its profile's spend for the current UTC day. ok is false
+// when that would exceed the profile's daily_spend_limit; charged reports whether anything was added,
+// so a send that then fails can be refunded. Profiles without a limit are always charged so the day's
+// total stays visible; lookup errors are logged and let the send through uncharged.
+func chargeDailySpend(profileID string, cost sms.Cost, now time.Time) (ok, charged bool) {
+	profile, err := database.GetProfile(profileID)
+	if err != nil || profile == nil {
+		return true, false
+	}
+
+	ok, err = database.AddProfileSpend(profileID, cost.Dollars(), profile.DailySpendLimit, now)
+	if err != nil {
+		database.LogError("message", "Failed to record profile spend", map[string]interface{}{
+			"error":      err.Error(),
+			"profile_id": profileID,
+		})
+		return true, false
+	}
+	return ok, ok
+}
+
+// refundDailySpend takes back a charge made by chargeDailySpend for a message that wasn't stored
+func refundDailySpend(profileID string, cost sms.Cost, now time.Time) {
+	if err := database.RefundProfileSpend(profileID, cost.Dollars(), now); err != nil {
+		database.LogError("message", "Failed to refund profile spend", map[string]interface{}{
+			"error":      err.Error(),
+			"profile_id": profileID,
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestDailySpendLimit_TripsAfterLimit(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Three single-part SMS at $0.004 fit exactly; the fourth would go over
+	database.UpsertProfile(database.MessagingProfile{ID: "profile-budget", DailySpendLimit: 0.012})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-budget",
+	})
+
+	sent := 0
+	var rr *httptest.ResponseRecorder
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr = httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		if rr.Code != http.StatusOK {
+			break
+		}
+		sent++
+	}
+
+	if sent != 3 {
+		t.Fatalf("Expected 3 sends before the limit tripped, got %d", sent)
+	}
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d once over the limit, got %d", http.StatusForbidden, rr.Code)
+	}
+	var resp map[string][]map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["errors"][0]["code"] != "10015" {
+		t.Errorf("Expected error code '10015', got %v", resp["errors"][0]["code"])
+	}
+
+	messages, _ := database.GetMessages(database.MessageFilter{})
+	if len(messages) != 3 {
+		t.Errorf("Expected rejected sends not to be stored, got %d messages", len(messages))
+	}
+
+	// Other profiles are unaffected
+	createTestMessage(t, "profile-other")
+}
+
+func TestDailySpendLimit_RefundedWhenInsertFails(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.UpsertProfile(database.MessagingProfile{ID: "profile-budget", DailySpendLimit: 0.012})
+
+	// Make every message insert fail after the spend has been charged
+	if _, err := database.DB.Exec("CREATE TRIGGER fail_message_insert BEFORE INSERT ON messages BEGIN SELECT RAISE(ABORT, 'insert failed'); END"); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Test message",
+		"messaging_profile_id": "profile-budget",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusInternalServerError, rr.Code, rr.Body.String())
+	}
+
+	spent, _ := database.GetProfileSpend("profile-budget", time.Now())
+	if spent != 0 {
+		t.Errorf("Expected the failed send to be refunded, got $%v spent", spent)
+	}
+}
+
+func TestAddProfileSpend_ResetsAtUTCMidnight(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	day := time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC)
+	if ok, _ := database.AddProfileSpend("profile-123", 0.01, 0.01, day); !ok {
+		t.Fatal("Expected the first charge to fit under the limit")
+	}
+	if ok, _ := database.AddProfileSpend("profile-123", 0.01, 0.01, day); ok {
+		t.Error("Expected a second charge on the same day to be refused")
+	}
+	if ok, _ := database.AddProfileSpend("profile-123", 0.01, 0.01, day.Add(2*time.Minute)); !ok {
+		t.Error("Expected the accumulator to reset at UTC midnight")
+	}
+
+	spent, _ := database.GetProfileSpend("profile-123", day)
+	if spent != 0.01 {
+		t.Errorf("Expected $0.01 spent on the first day, got %v", spent)
+	}
+}
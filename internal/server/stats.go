@@ -0,0 +1,200 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/sms"
+	"telnyx-mock/internal/validator"
+)
+
+// requestCounts maps "METHOD /route/pattern" to an *atomic.Int64 hit count
+var requestCounts sync.Map
+
+// RequestCounterMiddleware counts requests per matched route, keyed by method and chi route
+// pattern so path parameters don't split the counts. Requests that match no route are not counted.
+func RequestCounterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		counter, _ := requestCounts.LoadOrStore(r.Method+" "+rctx.RoutePattern(), new(atomic.Int64))
+		counter.(*atomic.Int64).Add(1)
+	})
+}
+
+// requestCountSnapshot returns the current count for every route hit so far
+func requestCountSnapshot() map[string]int64 {
+	counts := map[string]int64{}
+	requestCounts.Range(func(route, counter any) bool {
+		counts[route.(string)] = counter.(*atomic.Int64).Load()
+		return true
+	})
+	return counts
+}
+
+// resetRequestCounts clears every route's count
+func resetRequestCounts() {
+	requestCounts.Range(func(route, _ any) bool {
+		requestCounts.Delete(route)
+		return true
+	})
+}
+
+// HandleRequestStats handles GET /api/stats/requests
+func HandleRequestStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, requestCountSnapshot())
+}
+
+// HandleGetCostSummary handles GET /api/stats/cost, summing the stored simulated cost of every
+// billed message in total, per messaging profile and per UTC day
+func HandleGetCostSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summary, err := database.GetCostSummary()
+	if err != nil {
+		database.LogError("system", "Failed to compute cost summary", map[string]interface{}{
+			"error": err.Error(),
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to compute cost summary.", http.StatusInternalServerError)
+		return
+	}
+
+	byProfile := make([]map[string]interface{}, len(summary.ByProfile))
+	for i, group := range summary.ByProfile {
+		byProfile[i] = map[string]interface{}{
+			"messaging_profile_id": group.Key,
+			"amount":               sms.FormatAmount(group.Amount),
+			"message_count":        group.MessageCount,
+		}
+	}
+	byDay := make([]map[string]interface{}, len(summary.ByDay))
+	for i, group := range summary.ByDay {
+		byDay[i] = map[string]interface{}{
+			"day":           group.Key,
+			"amount":        sms.FormatAmount(group.Amount),
+			"message_count": group.MessageCount,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"currency":      sms.Currency,
+		"total":         sms.FormatAmount(summary.Total),
+		"message_count": summary.MessageCount,
+		"by_profile":    byProfile,
+		"by_day":        byDay,
+	})
+}
+
+// HandleGetStats handles GET /api/stats. By default only the message count is computed, to keep
+// the call cheap on large tables; ?include=messages,logs,cost adds the heavier aggregates.
+func HandleGetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fail := func(err error) {
+		database.LogError("system", "Failed to compute stats", map[string]interface{}{
+			"error": err.Error(),
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to compute stats.", http.StatusInternalServerError)
+	}
+
+	count, err := database.CountMessages()
+	if err != nil {
+		fail(err)
+		return
+	}
+	stats := map[string]interface{}{"message_count": count}
+
+	if includes(r, "messages") {
+		counts, err := database.GetMessageCounts()
+		if err != nil {
+			fail(err)
+			return
+		}
+		stats["messages"] = counts
+	}
+
+	if includes(r, "logs") {
+		counts, err := database.GetLogCounts()
+		if err != nil {
+			fail(err)
+			return
+		}
+		stats["logs"] = counts
+	}
+
+	if includes(r, "cost") {
+		summary, err := database.GetCostSummary()
+		if err != nil {
+			fail(err)
+			return
+		}
+		stats["cost"] = map[string]interface{}{
+			"currency":      sms.Currency,
+			"total":         sms.FormatAmount(summary.Total),
+			"message_count": summary.MessageCount,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// HandleGetWebhookStats handles GET /api/stats/webhooks, reporting the success rate of webhook
+// delivery attempts per event type and per URL, optionally within a since/until window
+func HandleGetWebhookStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	stats, err := database.GetDeliveryStats(parseTimeParam(query.Get("since")), parseTimeParam(query.Get("until")))
+	if err != nil {
+		database.LogError("system", "Failed to compute webhook stats", map[string]interface{}{
+			"error": err.Error(),
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to compute webhook stats.", http.StatusInternalServerError)
+		return
+	}
+
+	rates := func(keyName string, groups []database.DeliveryGroup) []map[string]interface{} {
+		result := make([]map[string]interface{}, len(groups))
+		for i, group := range groups {
+			result[i] = deliveryRate(group)
+			result[i][keyName] = group.Key
+		}
+		return result
+	}
+
+	response := deliveryRate(stats.Total)
+	response["by_event_type"] = rates("event_type", stats.ByEventType)
+	response["by_url"] = rates("url", stats.ByURL)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// deliveryRate renders a delivery group's counts and success rate
+func deliveryRate(group database.DeliveryGroup) map[string]interface{} {
+	return map[string]interface{}{
+		"total":        group.Total,
+		"succeeded":    group.Succeeded,
+		"failed":       group.Total - group.Succeeded,
+		"success_rate": group.SuccessRate(),
+	}
+}
@@ -0,0 +1,367 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+)
+
+func TestRequestCounterMiddleware(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	resetRequestCounts()
+
+	router := chi.NewRouter()
+	router.Use(RequestCounterMiddleware)
+	router.Post("/v2/messages", HandleCreateMessage)
+	router.Get("/api/messages/{id}", HandleGetMessage)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", strings.NewReader(`{}`))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/messages/a", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/messages/b", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	stats := func() map[string]int64 {
+		rr := httptest.NewRecorder()
+		HandleRequestStats(rr, httptest.NewRequest(http.MethodGet, "/api/stats/requests", nil))
+		var counts map[string]int64
+		json.Unmarshal(rr.Body.Bytes(), &counts)
+		return counts
+	}
+
+	counts := stats()
+	// Failed requests count too: retries are what the counter is for
+	if counts["POST /v2/messages"] != 2 {
+		t.Errorf("Expected 2 create requests, got %d", counts["POST /v2/messages"])
+	}
+	if counts["GET /api/messages/{id}"] != 2 {
+		t.Errorf("Expected path parameters to share a count of 2, got %d", counts["GET /api/messages/{id}"])
+	}
+	if len(counts) != 2 {
+		t.Errorf("Expected only matched routes to be counted, got %v", counts)
+	}
+
+	rr := httptest.NewRecorder()
+	HandleReset(rr, httptest.NewRequest(http.MethodDelete, "/api/reset?confirm=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected reset to succeed, got %d", rr.Code)
+	}
+	if counts := stats(); len(counts) != 0 {
+		t.Errorf("Expected counts to be cleared by reset, got %v", counts)
+	}
+}
+
+func TestHandleGetCostSummary(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	day1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 3, 2, 23, 30, 0, 0, time.UTC)
+	for i, m := range []struct {
+		profile string
+		at      time.Time
+		cost    float64
+	}{
+		{"profile-a", day1, 0.004},
+		{"profile-a", day1, 0.015},
+		{"profile-b", day1, 0.008},
+		{"profile-b", day2, 0.004},
+	} {
+		id := fmt.Sprintf("msg-%d", i)
+		opts := database.MessageOptions{CreatedAt: m.at, Cost: m.cost}
+		if err := database.InsertMessageWithOptions(id, "+1234567890", "+0987654321", "Hi", nil, m.profile, "outbound", opts); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+	// Inbound messages aren't billed
+	database.InsertMessageWithOptions("msg-in", "+0987654321", "+1234567890", "Hi", nil, "profile-a", "inbound", database.MessageOptions{CreatedAt: day1})
+
+	rr := httptest.NewRecorder()
+	HandleGetCostSummary(rr, httptest.NewRequest(http.MethodGet, "/api/stats/cost", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var summary struct {
+		Currency     string `json:"currency"`
+		Total        string `json:"total"`
+		MessageCount int    `json:"message_count"`
+		ByProfile    []struct {
+			ProfileID    string `json:"messaging_profile_id"`
+			Amount       string `json:"amount"`
+			MessageCount int    `json:"message_count"`
+		} `json:"by_profile"`
+		ByDay []struct {
+			Day          string `json:"day"`
+			Amount       string `json:"amount"`
+			MessageCount int    `json:"message_count"`
+		} `json:"by_day"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &summary)
+
+	if summary.Currency != "USD" || summary.Total != "0.0310" || summary.MessageCount != 4 {
+		t.Errorf("Expected 4 messages totalling USD 0.0310, got %d totalling %s %s", summary.MessageCount, summary.Currency, summary.Total)
+	}
+	if len(summary.ByProfile) != 2 ||
+		summary.ByProfile[0].ProfileID != "profile-a" || summary.ByProfile[0].Amount != "0.0190" || summary.ByProfile[0].MessageCount != 2 ||
+		summary.ByProfile[1].ProfileID != "profile-b" || summary.ByProfile[1].Amount != "0.0120" || summary.ByProfile[1].MessageCount != 2 {
+		t.Errorf("Unexpected per-profile totals: %+v", summary.ByProfile)
+	}
+	if len(summary.ByDay) != 2 ||
+		summary.ByDay[0].Day != "2024-03-01" || summary.ByDay[0].Amount != "0.0270" || summary.ByDay[0].MessageCount != 3 ||
+		summary.ByDay[1].Day != "2024-03-02" || summary.ByDay[1].Amount != "0.0040" || summary.ByDay[1].MessageCount != 1 {
+		t.Errorf("Unexpected per-day totals: %+v", summary.ByDay)
+	}
+}
+
+func TestHandleCreateMessage_PersistsCost(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	createTestMessage(t, "profile-123")
+
+	summary, err := database.GetCostSummary()
+	if err != nil {
+		t.Fatalf("Failed to get cost summary: %v", err)
+	}
+	if summary.Total != 0.004 || summary.MessageCount != 1 {
+		t.Errorf("Expected the sent message's $0.004 cost to be stored, got %v over %d messages", summary.Total, summary.MessageCount)
+	}
+}
+
+func TestHandleGetStats_Include(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	createTestMessage(t, "profile-123")
+	createTestMessage(t, "profile-123")
+	database.InsertMessage("msg-in", "+0987654321", "+1234567890", "Hi", nil, "profile-123", "inbound")
+
+	get := func(query string) map[string]json.RawMessage {
+		rr := httptest.NewRecorder()
+		HandleGetStats(rr, httptest.NewRequest(http.MethodGet, "/api/stats"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var stats map[string]json.RawMessage
+		json.Unmarshal(rr.Body.Bytes(), &stats)
+		return stats
+	}
+
+	// The default is just the message count
+	stats := get("")
+	if len(stats) != 1 || string(stats["message_count"]) != "3" {
+		t.Errorf("Expected only message_count 3 by default, got %v", stats)
+	}
+
+	stats = get("?include=messages,cost")
+	if _, ok := stats["logs"]; ok {
+		t.Error("Expected logs to be absent when not requested")
+	}
+	var messages database.MessageCounts
+	json.Unmarshal(stats["messages"], &messages)
+	if messages.ByDirection["outbound"] != 2 || messages.ByDirection["inbound"] != 1 {
+		t.Errorf("Expected 2 outbound and 1 inbound, got %+v", messages.ByDirection)
+	}
+	var cost map[string]interface{}
+	json.Unmarshal(stats["cost"], &cost)
+	if cost["total"] != "0.0080" {
+		t.Errorf("Expected cost total 0.0080, got %v", cost["total"])
+	}
+
+	stats = get("?include=logs")
+	if _, ok := stats["messages"]; ok {
+		t.Error("Expected messages to be absent when not requested")
+	}
+	if _, ok := stats["cost"]; ok {
+		t.Error("Expected cost to be absent when not requested")
+	}
+	var logs database.LogCounts
+	json.Unmarshal(stats["logs"], &logs)
+	if logs.Total == 0 || logs.ByLevel["info"] == 0 {
+		t.Errorf("Expected info logs to be counted, got %+v", logs)
+	}
+}
+
+func TestHandleGetWebhookStats(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	record := func(url, eventType string, success bool, at time.Time) {
+		if err := database.RecordWebhookDelivery(database.WebhookDelivery{
+			URL:       url,
+			EventType: eventType,
+			Attempt:   1,
+			Success:   success,
+			CreatedAt: at,
+		}); err != nil {
+			t.Fatalf("Failed to record delivery: %v", err)
+		}
+	}
+	now := time.Now()
+	record("http://a.test", "message.sent", true, now)
+	record("http://a.test", "message.sent", true, now)
+	record("http://a.test", "message.delivered", false, now)
+	record("http://b.test", "message.sent", false, now)
+	record("http://b.test", "message.delivered", true, now)
+	// Outside the window queried below
+	record("http://b.test", "message.delivered", false, now.Add(-2*time.Hour))
+
+	since := now.Add(-time.Hour).UTC().Format(time.RFC3339)
+	rr := httptest.NewRecorder()
+	HandleGetWebhookStats(rr, httptest.NewRequest(http.MethodGet, "/api/stats/webhooks?since="+since, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var stats struct {
+		Total       int     `json:"total"`
+		Failed      int     `json:"failed"`
+		SuccessRate float64 `json:"success_rate"`
+		ByEventType []struct {
+			EventType   string  `json:"event_type"`
+			Total       int     `json:"total"`
+			SuccessRate float64 `json:"success_rate"`
+		} `json:"by_event_type"`
+		ByURL []struct {
+			URL         string  `json:"url"`
+			Total       int     `json:"total"`
+			SuccessRate float64 `json:"success_rate"`
+		} `json:"by_url"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &stats)
+
+	if stats.Total != 5 || stats.Failed != 2 || stats.SuccessRate != 0.6 {
+		t.Errorf("Expected 5 attempts, 2 failed, rate 0.6, got %+v", stats)
+	}
+	if len(stats.ByEventType) != 2 ||
+		stats.ByEventType[0].EventType != "message.delivered" || stats.ByEventType[0].Total != 2 || stats.ByEventType[0].SuccessRate != 0.5 ||
+		stats.ByEventType[1].EventType != "message.sent" || stats.ByEventType[1].Total != 3 || stats.ByEventType[1].SuccessRate != 2.0/3 {
+		t.Errorf("Unexpected per-event rates %+v", stats.ByEventType)
+	}
+	if len(stats.ByURL) != 2 ||
+		stats.ByURL[0].URL != "http://a.test" || stats.ByURL[0].Total != 3 || stats.ByURL[0].SuccessRate != 2.0/3 ||
+		stats.ByURL[1].URL != "http://b.test" || stats.ByURL[1].Total != 2 || stats.ByURL[1].SuccessRate != 0.5 {
+		t.Errorf("Unexpected per-URL rates %+v", stats.ByURL)
+	}
+}
+
+// recordedAttempts returns the attempt numbers recorded for eventType, oldest first
+func recordedAttempts(t *testing.T, eventType string) []int {
+	t.Helper()
+
+	rows, err := database.DB.Query("SELECT attempt FROM webhook_deliveries WHERE event_type = ? ORDER BY id", eventType)
+	if err != nil {
+		t.Fatalf("Failed to query deliveries: %v", err)
+	}
+	defer rows.Close()
+	attempts := []int{}
+	for rows.Next() {
+		var attempt int
+		rows.Scan(&attempt)
+		attempts = append(attempts, attempt)
+	}
+	return attempts
+}
+
+func TestWebhookDeliveries_RecordedFromRealSends(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("webhook_initial_delay_ms", "0")
+	database.SetSetting("webhook_duplicate_rate", "1")
+
+	received := make(chan string, 8)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload["data"].(map[string]interface{})["event_type"].(string)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Record me",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+	})
+	// sent, its duplicate, delivered and its duplicate
+	for i := 0; i < 4; i++ {
+		select {
+		case <-received:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timeout waiting for webhook %d", i+1)
+		}
+	}
+
+	// The last duplicate is recorded just after it's received
+	deadline := time.Now().Add(time.Second)
+	for len(recordedAttempts(t, "message.delivered")) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, eventType := range []string{"message.sent", "message.delivered"} {
+		if attempts := recordedAttempts(t, eventType); len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+			t.Errorf("Expected %s recorded as attempt 1 and its duplicate as attempt 2, got %v", eventType, attempts)
+		}
+	}
+
+	stats, _ := database.GetDeliveryStats(time.Time{}, time.Time{})
+	if stats.Total.Total != 4 || stats.Total.Succeeded != 4 {
+		t.Errorf("Expected 4 successful attempts in the stats, got %+v", stats.Total)
+	}
+}
+
+func TestWebhookDeliveries_PrunedWithLogs(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	record := func(at time.Time) {
+		database.RecordWebhookDelivery(database.WebhookDelivery{URL: "http://a.test", EventType: "message.sent", Attempt: 1, Success: true, CreatedAt: at})
+	}
+	count := func() int {
+		stats, _ := database.GetDeliveryStats(time.Time{}, time.Time{})
+		return stats.Total.Total
+	}
+
+	// Old attempts go with the log retention cleanup
+	record(time.Now().AddDate(0, 0, -8))
+	record(time.Now())
+	if _, err := PruneLogs(); err != nil {
+		t.Fatalf("Failed to prune logs: %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("Expected the 8-day-old attempt cleaned up, got %d attempts", n)
+	}
+
+	// max_logs bounds them as they're recorded
+	database.SetSetting("max_logs", "3")
+	for i := 0; i < 5; i++ {
+		record(time.Now())
+	}
+	if n := count(); n != 3 {
+		t.Errorf("Expected max_logs to keep 3 attempts, got %d", n)
+	}
+
+	// Clearing the logs clears them too
+	rr := httptest.NewRecorder()
+	HandleClearLogs(rr, httptest.NewRequest(http.MethodDelete, "/api/logs", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if n := count(); n != 0 {
+		t.Errorf("Expected no attempts after clearing the logs, got %d", n)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"telnyx-mock/internal/database"
+)
+
+// streamMessages writes the messages matching filter as a JSON array, encoding each row as it is
+// read so memory stays flat however many messages there are. With an after_seq cursor the array
+// is wrapped like the buffered listing, {"data": [...], "max_seq": N}, with max_seq written once
+// the rows are done. The status is committed before the first row, so a failure mid-stream is
+// logged and leaves the response unterminated for the client to detect.
+func streamMessages(w http.ResponseWriter, filter database.MessageFilter, withProfiles, withCursor bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	var embed func(database.Message) messageWithProfile
+	if withProfiles {
+		embed = profileEmbedder()
+	}
+	if withCursor {
+		w.Write([]byte(`{"data":`))
+	}
+
+	encoder := json.NewEncoder(w)
+	first := true
+	maxSeq := filter.AfterSeq
+	err := database.StreamMessages(filter, func(msg database.Message) error {
+		separator := ","
+		if first {
+			separator = "["
+			first = false
+		}
+		if _, err := w.Write([]byte(separator)); err != nil {
+			return err
+		}
+
+		maxSeq = max(maxSeq, msg.Seq)
+		if embed != nil {
+			return encoder.Encode(embed(msg))
+		}
+		return encoder.Encode(msg)
+	})
+	if err != nil {
+		database.LogError("message", "Failed to stream messages", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if first {
+		w.Write([]byte("["))
+	}
+	if withCursor {
+		fmt.Fprintf(w, `],"max_seq":%d}`+"\n", maxSeq)
+		return
+	}
+	w.Write([]byte("]\n"))
+}
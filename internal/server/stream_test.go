@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleListMessages_Stream(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	list := func(query string) []database.Message {
+		rr := httptest.NewRecorder()
+		HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var messages []database.Message
+		if err := json.Unmarshal(rr.Body.Bytes(), &messages); err != nil {
+			t.Fatalf("Expected a valid JSON array, got error %v. Body: %.200s", err, rr.Body.String())
+		}
+		return messages
+	}
+
+	if messages := list("?stream=true"); len(messages) != 0 {
+		t.Errorf("Expected an empty array with no messages, got %d", len(messages))
+	}
+
+	const count = 2000
+	ids := make([]string, count)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("msg-%04d", i)
+	}
+	base := time.Now().UTC().Add(-time.Hour)
+	database.InsertMessagesBatch(ids, "+1234567890", "+0987654321", "streamed", "profile-1", "outbound",
+		database.MessageOptions{CreatedAt: base})
+
+	streamed := list("?stream=true&direction=outbound")
+	if len(streamed) != count {
+		t.Fatalf("Expected %d streamed messages, got %d", count, len(streamed))
+	}
+
+	buffered := list("?direction=outbound")
+	for i := range buffered {
+		if streamed[i].ID != buffered[i].ID {
+			t.Fatalf("Expected streamed order to match the buffered listing at %d: %s vs %s", i, streamed[i].ID, buffered[i].ID)
+		}
+	}
+}
+
+func TestHandleListMessages_StreamCursorAndProfiles(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.UpsertProfile(database.MessagingProfile{ID: "profile-1", Name: "Streamed"})
+	ids := []string{"msg-1", "msg-2", "msg-3"}
+	database.InsertMessagesBatch(ids, "+1234567890", "+0987654321", "streamed", "profile-1", "outbound",
+		database.MessageOptions{CreatedAt: time.Now().UTC().Add(-time.Hour)})
+
+	type page struct {
+		Data []struct {
+			ID               string                     `json:"id"`
+			MessagingProfile *database.MessagingProfile `json:"messaging_profile"`
+		} `json:"data"`
+		MaxSeq int64 `json:"max_seq"`
+	}
+	list := func(query string) page {
+		rr := httptest.NewRecorder()
+		HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/api/messages"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var p page
+		if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+			t.Fatalf("Expected a valid JSON page, got error %v. Body: %.200s", err, rr.Body.String())
+		}
+		return p
+	}
+
+	// The streamed page matches the buffered one, max_seq included, so a client can resume from it
+	buffered := list("?after_seq=1&include=messaging_profile")
+	streamed := list("?after_seq=1&include=messaging_profile&stream=true")
+	if streamed.MaxSeq == 0 || streamed.MaxSeq != buffered.MaxSeq {
+		t.Errorf("Expected streamed max_seq %d, got %d", buffered.MaxSeq, streamed.MaxSeq)
+	}
+	if len(streamed.Data) != 2 || len(streamed.Data) != len(buffered.Data) {
+		t.Fatalf("Expected 2 messages after seq 1, got %d streamed and %d buffered", len(streamed.Data), len(buffered.Data))
+	}
+	for i, msg := range streamed.Data {
+		if msg.ID != buffered.Data[i].ID {
+			t.Errorf("Expected streamed message %d to be %s, got %s", i, buffered.Data[i].ID, msg.ID)
+		}
+		if msg.MessagingProfile == nil || msg.MessagingProfile.Name != "Streamed" {
+			t.Errorf("Expected the messaging profile embedded in %s, got %+v", msg.ID, msg.MessagingProfile)
+		}
+	}
+
+	// Resuming from max_seq finds nothing new, and still reports where to resume from
+	if empty := list(fmt.Sprintf("?after_seq=%d&stream=true", streamed.MaxSeq)); len(empty.Data) != 0 || empty.MaxSeq != streamed.MaxSeq {
+		t.Errorf("Expected an empty page at max_seq %d, got %+v", streamed.MaxSeq, empty)
+	}
+}
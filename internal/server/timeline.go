@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+)
+
+// HandleGetMessageTimeline handles GET /api/messages/{id}/timeline, returning the message's
+// creation, status changes and webhook delivery attempts in chronological order
+func HandleGetMessageTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	msg, err := database.GetMessageByID(chi.URLParam(r, "id"))
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to retrieve message.", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil || msg.DeletedAt != nil {
+		validator.WriteError(w, "10004", "Not found", "[SmsSink] Message not found.", http.StatusNotFound)
+		return
+	}
+
+	timeline, err := database.GetMessageTimeline(*msg)
+	if err != nil {
+		database.LogError("system", "Failed to build message timeline", map[string]interface{}{
+			"error":      err.Error(),
+			"message_id": msg.ID,
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to build message timeline.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": timeline})
+}
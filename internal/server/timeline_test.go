@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleGetMessageTimeline(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	id := sendTestMessage(t, map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Timeline",
+		"messaging_profile_id": "profile-1",
+		"webhook_url":          receiver.URL,
+		"webhook_delay_ms":     0,
+	})["id"].(string)
+
+	router := chi.NewRouter()
+	router.Get("/api/messages/{id}/timeline", HandleGetMessageTimeline)
+	get := func(id string) (int, []database.TimelineEvent) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/messages/"+id+"/timeline", nil))
+		var response struct {
+			Data []database.TimelineEvent `json:"data"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response.Data
+	}
+
+	// Wait for the delivered webhook to be logged
+	var events []database.TimelineEvent
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		_, events = get(id)
+		if len(events) == 6 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	steps := []string{}
+	for i, event := range events {
+		step := event.Type
+		switch event.Type {
+		case "status":
+			step += ":" + event.Status
+		case "webhook":
+			var details struct {
+				EventType string `json:"event_type"`
+			}
+			json.Unmarshal(event.Details, &details)
+			step += ":" + details.EventType
+		}
+		steps = append(steps, step)
+		if i > 0 && event.OccurredAt.Before(events[i-1].OccurredAt) {
+			t.Errorf("Expected events in chronological order, got %v before %v", events[i-1], event)
+		}
+	}
+	expected := "created,status:queued,status:sent,webhook:message.sent,status:delivered,webhook:message.delivered"
+	if strings.Join(steps, ",") != expected {
+		t.Errorf("Expected timeline %s, got %s", expected, strings.Join(steps, ","))
+	}
+
+	if code, _ := get("missing"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown message, got %d", http.StatusNotFound, code)
+	}
+
+	database.SetSetting("soft_delete", "true")
+	database.DeleteMessageByID(id)
+	if code, _ := get(id); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a soft-deleted message, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestHandleGetMessageTimeline_ReplacedMessageStartsOver(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if err := database.InsertMessageWithOptions("msg-replaced", "+1234567890", "+0987654321", "First", nil, "profile-1", "outbound", database.MessageOptions{}); err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+	database.UpdateMessageStatus("msg-replaced", "sent")
+	database.UpdateMessageStatus("msg-replaced", "delivered")
+	if err := database.InsertMessageWithOptions("msg-replaced", "+1234567890", "+0987654321", "Second", nil, "profile-1", "outbound", database.MessageOptions{Replace: true}); err != nil {
+		t.Fatalf("Failed to replace message: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/messages/{id}/timeline", HandleGetMessageTimeline)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/messages/msg-replaced/timeline", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Data []database.TimelineEvent `json:"data"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+
+	statuses := []string{}
+	for _, event := range response.Data {
+		if event.Type == "status" {
+			statuses = append(statuses, event.Status)
+		}
+	}
+	if strings.Join(statuses, ",") != "queued" {
+		t.Errorf("Expected only the replacement's queued status, got %v", statuses)
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import "telnyx-mock/internal/database"
+
+// DefaultTextTruncationLength is where text is cut when simulate_text_truncation is on and no
+// length is configured: a single GSM-7 segment
+const DefaultTextTruncationLength = 160
+
+// truncateText simulates a provider that doesn't concatenate long messages and cuts them off
+// instead. With simulate_text_truncation on, text longer than text_truncation_length characters
+// is truncated to that length; it reports whether the text was cut.
+func truncateText(text string) (string, bool) {
+	if !database.GetBoolSetting("simulate_text_truncation", false) {
+		return text, false
+	}
+
+	limit := database.GetIntSetting("text_truncation_length", DefaultTextTruncationLength)
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text, false
+	}
+	return string(runes[:limit]), true
+}
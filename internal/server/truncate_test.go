@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telnyx-mock/internal/database"
+)
+
+func TestHandleCreateMessage_TextTruncation(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	long := strings.Repeat("a", 200)
+	send := func() map[string]interface{} {
+		return sendTestMessage(t, map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 long,
+			"messaging_profile_id": "profile-123",
+		})
+	}
+
+	// Off by default: long text is concatenated
+	data := send()
+	if data["text"] != long || data["parts"] != float64(2) {
+		t.Errorf("Expected untouched text in 2 parts by default, got %d chars in %v parts", len(data["text"].(string)), data["parts"])
+	}
+	if _, ok := data["truncated"]; ok {
+		t.Error("Expected no truncated flag by default")
+	}
+
+	database.SetSetting("simulate_text_truncation", "true")
+	database.SetSetting("text_truncation_length", "120")
+
+	data = send()
+	if data["text"] != long[:120] || data["truncated"] != true {
+		t.Errorf("Expected text cut to 120 chars with truncated=true, got %d chars and %v", len(data["text"].(string)), data["truncated"])
+	}
+	if data["parts"] != float64(1) {
+		t.Errorf("Expected the truncated text to be billed as 1 part, got %v", data["parts"])
+	}
+	msg, _ := database.GetMessageByID(data["id"].(string))
+	if msg == nil || msg.Content != long[:120] {
+		t.Error("Expected the truncated text to be stored")
+	}
+
+	// Text within the limit is left alone
+	data = createTestMessage(t, "profile-123")
+	if _, ok := data["truncated"]; ok {
+		t.Error("Expected no truncated flag for short text")
+	}
+}
+
+func TestHandleCreateMessage_TextTruncationEcho(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	database.SetSetting("debug_mode", "true")
+	database.SetSetting("simulate_text_truncation", "true")
+	database.SetSetting("text_truncation_length", "120")
+
+	long := strings.Repeat("a", 200)
+	body, _ := json.Marshal(map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 long,
+		"messaging_profile_id": "profile-123",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages?echo=true", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	HandleCreateMessage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			Text string `json:"text"`
+		} `json:"data"`
+		Debug struct {
+			Received map[string]interface{} `json:"received"`
+		} `json:"_debug"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response.Data.Text != long[:120] {
+		t.Errorf("Expected the truncated text in the response, got %d chars", len(response.Data.Text))
+	}
+	// The echo still shows the full text the client sent
+	if response.Debug.Received["text"] != long {
+		t.Errorf("Expected the echoed text to be the original 200 chars, got %v", response.Debug.Received["text"])
+	}
+}
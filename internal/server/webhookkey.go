@@ -0,0 +1,123 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/validator"
+	"telnyx-mock/internal/webhook"
+)
+
+// VerifySignatureRequest is the body of POST /api/webhook-key/verify
+type VerifySignatureRequest struct {
+	Payload   string `json:"payload"`
+	Timestamp string `json:"timestamp"`
+	Signature string `json:"signature"`
+}
+
+// publicKeyString returns the base64 public key for a signing key, or nil if there is none
+func publicKeyString(key ed25519.PrivateKey) interface{} {
+	if key == nil {
+		return nil
+	}
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// webhookKeyResponse builds the public view of the current and previous signing keys
+func webhookKeyResponse() (map[string]interface{}, error) {
+	current, err := database.GetWebhookSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	previous, err := database.GetPreviousWebhookSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"public_key":          publicKeyString(current),
+		"previous_public_key": publicKeyString(previous),
+	}, nil
+}
+
+// HandleGetWebhookKey handles GET /api/webhook-key
+func HandleGetWebhookKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only GET method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := webhookKeyResponse()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to load webhook signing key.", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleRotateWebhookKey handles POST /api/webhook-key/rotate
+func HandleRotateWebhookKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := database.RotateWebhookSigningKey(); err != nil {
+		database.LogError("system", "Failed to rotate webhook signing key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to rotate webhook signing key.", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := webhookKeyResponse()
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to load webhook signing key.", http.StatusInternalServerError)
+		return
+	}
+
+	database.Log("system", "Webhook signing key rotated", map[string]interface{}{
+		"public_key": resp["public_key"],
+	})
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleVerifySignature handles POST /api/webhook-key/verify, reporting whether a signature
+// matches the current or previous signing key
+func HandleVerifySignature(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		validator.WriteError(w, "10003", "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req VerifySignatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] Invalid JSON payload.", http.StatusBadRequest)
+		return
+	}
+
+	if req.Timestamp == "" || req.Signature == "" {
+		validator.WriteError(w, "10005", "Invalid parameter", "[SmsSink] timestamp and signature are required.", http.StatusBadRequest)
+		return
+	}
+
+	matched, err := webhook.VerifySignature(req.Payload, req.Timestamp, req.Signature)
+	if err != nil {
+		validator.WriteError(w, "10000", "Internal Server Error", "[SmsSink] Failed to load webhook signing key.", http.StatusInternalServerError)
+		return
+	}
+
+	var key interface{}
+	if matched != "" {
+		key = matched
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"valid":       matched != "",
+		"matched_key": key,
+	})
+}
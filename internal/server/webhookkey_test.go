@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telnyx-mock/internal/webhook"
+)
+
+func verifySignature(t *testing.T, payload, timestamp, signature string) map[string]interface{} {
+	body, _ := json.Marshal(VerifySignatureRequest{Payload: payload, Timestamp: timestamp, Signature: signature})
+	req := httptest.NewRequest(http.MethodPost, "/api/webhook-key/verify", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	HandleVerifySignature(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestHandleVerifySignature(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	payload := `{"data":{"event_type":"message.delivered"}}`
+	timestamp := "2024-01-01T12:00:00Z"
+	signature, err := webhook.SignPayload(timestamp, []byte(payload))
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+
+	resp := verifySignature(t, payload, timestamp, signature)
+	if resp["valid"] != true || resp["matched_key"] != "current" {
+		t.Errorf("Expected valid signature from current key, got %v", resp)
+	}
+
+	resp = verifySignature(t, `{"data":{"event_type":"message.failed"}}`, timestamp, signature)
+	if resp["valid"] != false || resp["matched_key"] != nil {
+		t.Errorf("Expected tampered payload to be invalid, got %v", resp)
+	}
+
+	// After rotation the old signature should still verify against the previous key
+	rr := httptest.NewRecorder()
+	HandleRotateWebhookKey(rr, httptest.NewRequest(http.MethodPost, "/api/webhook-key/rotate", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	resp = verifySignature(t, payload, timestamp, signature)
+	if resp["valid"] != true || resp["matched_key"] != "previous" {
+		t.Errorf("Expected valid signature from previous key, got %v", resp)
+	}
+}
+
+func TestHandleVerifySignature_MissingFields(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhook-key/verify", bytes.NewReader([]byte(`{"payload": "{}"}`)))
+	rr := httptest.NewRecorder()
+	HandleVerifySignature(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strings"
+
+	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/sms"
+	"telnyx-mock/internal/validator"
+)
+
+// xmlMessageResponse is the create response in the XML form legacy consumers ask for with
+// Accept: application/xml, mirroring the JSON {"data": {...}} shape
+type xmlMessageResponse struct {
+	XMLName xml.Name   `xml:"response"`
+	Data    xmlMessage `xml:"data"`
+}
+
+// xmlMessage mirrors the create response's data object. Fields dropped by response_omit_fields
+// are left empty and so omitted here too. Its json tags match the JSON response, so a stored
+// response can be decoded straight into it for an idempotent replay.
+type xmlMessage struct {
+	ID                 string        `xml:"id,omitempty" json:"id"`
+	RecordType         string        `xml:"record_type,omitempty" json:"record_type"`
+	Direction          string        `xml:"direction,omitempty" json:"direction"`
+	MessagingProfileID string        `xml:"messaging_profile_id,omitempty" json:"messaging_profile_id"`
+	From               *xmlEndpoint  `xml:"from,omitempty" json:"from"`
+	To                 []xmlEndpoint `xml:"to>recipient,omitempty" json:"to"`
+	Text               string        `xml:"text,omitempty" json:"text"`
+	Media              []string      `xml:"media>url,omitempty" json:"media"`
+	Type               string        `xml:"type,omitempty" json:"type"`
+	Subject            string        `xml:"subject,omitempty" json:"subject"`
+	ValidUntil         string        `xml:"valid_until,omitempty" json:"valid_until"`
+	WebhookURL         string        `xml:"webhook_url,omitempty" json:"webhook_url"`
+	WebhookFailoverURL string        `xml:"webhook_failover_url,omitempty" json:"webhook_failover_url"`
+	WebhookURLs        []string      `xml:"webhook_urls>url,omitempty" json:"webhook_urls"`
+	UseProfileWebhooks *bool         `xml:"use_profile_webhooks,omitempty" json:"use_profile_webhooks"`
+	Encoding           string        `xml:"encoding,omitempty" json:"encoding"`
+	Parts              int           `xml:"parts,omitempty" json:"parts"`
+	Tags               []string      `xml:"tags>tag,omitempty" json:"tags"`
+	Cost               *xmlCost      `xml:"cost,omitempty" json:"cost"`
+	CreatedAt          string        `xml:"created_at,omitempty" json:"created_at"`
+	UpdatedAt          string        `xml:"updated_at,omitempty" json:"updated_at"`
+	Truncated          bool          `xml:"truncated,omitempty" json:"truncated"`
+	SkipWebhooks       bool          `xml:"skip_webhooks,omitempty" json:"skip_webhooks"`
+}
+
+// xmlEndpoint is a sender or recipient
+type xmlEndpoint struct {
+	PhoneNumber        string `xml:"phone_number" json:"phone_number"`
+	Status             string `xml:"status,omitempty" json:"status"`
+	Carrier            string `xml:"carrier" json:"carrier"`
+	LineType           string `xml:"line_type" json:"line_type"`
+	MessagingProfileID string `xml:"messaging_profile_id,omitempty" json:"messaging_profile_id"`
+	DisplayName        string `xml:"display_name,omitempty" json:"display_name"`
+}
+
+// xmlCost is a message's cost, with the per-part breakdown when detailed_cost is on
+type xmlCost struct {
+	Amount    string        `xml:"amount" json:"amount"`
+	Currency  string        `xml:"currency" json:"currency"`
+	Breakdown []xmlPartCost `xml:"breakdown>part,omitempty" json:"breakdown"`
+}
+
+// xmlPartCost is one part's share of a message's cost
+type xmlPartCost struct {
+	Part   int    `xml:"part" json:"part"`
+	Amount string `xml:"amount" json:"amount"`
+}
+
+// xmlErrorResponse is a Telnyx error response in XML form, mirroring the JSON {"errors": [...]} shape
+type xmlErrorResponse struct {
+	XMLName xml.Name   `xml:"response"`
+	Errors  []xmlError `xml:"errors>error"`
+}
+
+// xmlError is one entry of an error response
+type xmlError struct {
+	Code   string `xml:"code"`
+	Title  string `xml:"title"`
+	Detail string `xml:"detail"`
+}
+
+// wantsXML reports whether the request's Accept header prefers XML over JSON. Media types are
+// taken in the order listed; without either, the response is JSON.
+func wantsXML(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return false
+		case "application/xml", "text/xml":
+			return true
+		}
+	}
+	return false
+}
+
+// newXMLMessage builds the XML form of a create response's data object. Extra fields from
+// response_extra_fields have no place in the fixed shape and are left out.
+func newXMLMessage(data map[string]interface{}) xmlMessage {
+	str := func(key string) string {
+		s, _ := data[key].(string)
+		return s
+	}
+	msg := xmlMessage{
+		ID:                 str("id"),
+		RecordType:         str("record_type"),
+		Direction:          str("direction"),
+		MessagingProfileID: str("messaging_profile_id"),
+		Text:               str("text"),
+		Type:               str("type"),
+		Subject:            str("subject"),
+		ValidUntil:         str("valid_until"),
+		WebhookURL:         str("webhook_url"),
+		WebhookFailoverURL: str("webhook_failover_url"),
+		Encoding:           str("encoding"),
+		CreatedAt:          str("created_at"),
+		UpdatedAt:          str("updated_at"),
+	}
+	msg.Media, _ = data["media"].([]string)
+	msg.Tags, _ = data["tags"].([]string)
+	msg.WebhookURLs, _ = data["webhook_urls"].([]string)
+	msg.Parts, _ = data["parts"].(int)
+	msg.Truncated, _ = data["truncated"].(bool)
+	msg.SkipWebhooks, _ = data["skip_webhooks"].(bool)
+	if useProfileWebhooks, ok := data["use_profile_webhooks"].(bool); ok {
+		msg.UseProfileWebhooks = &useProfileWebhooks
+	}
+
+	if from, ok := data["from"].(map[string]interface{}); ok {
+		endpoint := newXMLEndpoint(from)
+		msg.From = &endpoint
+	}
+	if to, ok := data["to"].([]map[string]interface{}); ok {
+		for _, recipient := range to {
+			msg.To = append(msg.To, newXMLEndpoint(recipient))
+		}
+	}
+
+	if cost, ok := data["cost"].(sms.Cost); ok {
+		msg.Cost = &xmlCost{Amount: cost.Amount, Currency: cost.Currency}
+		for _, part := range cost.Breakdown {
+			msg.Cost.Breakdown = append(msg.Cost.Breakdown, xmlPartCost{Part: part.Part, Amount: part.Amount})
+		}
+	}
+	return msg
+}
+
+// newXMLEndpoint builds the XML form of a from or to entry
+func newXMLEndpoint(fields map[string]interface{}) xmlEndpoint {
+	str := func(key string) string {
+		s, _ := fields[key].(string)
+		return s
+	}
+	return xmlEndpoint{
+		PhoneNumber:        str("phone_number"),
+		Status:             str("status"),
+		Carrier:            str("carrier"),
+		LineType:           str("line_type"),
+		MessagingProfileID: str("messaging_profile_id"),
+		DisplayName:        str("display_name"),
+	}
+}
+
+// writeXML writes v as an XML response with the given status code, indented like writeJSON for
+// requests that ask for pretty output
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	if _, ok := w.(*prettyResponseWriter); ok {
+		encoder.Indent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		database.LogError("system", "Failed to encode XML response", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// writeErrorResponse writes a Telnyx error response, as XML when the request asks for it
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, resp *validator.TelnyxErrorResponse) {
+	if !wantsXML(r) {
+		writeJSON(w, status, resp)
+		return
+	}
+
+	xmlResp := xmlErrorResponse{}
+	for _, e := range resp.Errors {
+		xmlResp.Errors = append(xmlResp.Errors, xmlError{Code: e.Code, Title: e.Title, Detail: e.Detail})
+	}
+	writeXML(w, status, xmlResp)
+}
+
+// writeCreateError writes a single Telnyx error for POST /v2/messages, negotiating its format
+// like the success response
+func writeCreateError(w http.ResponseWriter, r *http.Request, code, title, detail string, status int) {
+	writeErrorResponse(w, r, status, &validator.TelnyxErrorResponse{
+		Errors: []validator.TelnyxError{{Code: code, Title: title, Detail: detail}},
+	})
+}
+
+// writeCatalogError writes the catalog's error for code on POST /v2/messages, so the code means the
+// same as it does from POST /api/simulate/error
+func writeCatalogError(w http.ResponseWriter, r *http.Request, code string) {
+	entry, _ := validator.LookupError(code)
+	writeCreateError(w, r, entry.Code, entry.Title, entry.Detail, entry.Status)
+}
+
+// xmlMessageResponseFromJSON converts a stored JSON create response to its XML form
+func xmlMessageResponseFromJSON(stored []byte) (xmlMessageResponse, error) {
+	var resp xmlMessageResponse
+	var decoded struct {
+		Data xmlMessage `json:"data"`
+	}
+	if err := json.Unmarshal(stored, &decoded); err != nil {
+		return resp, err
+	}
+	resp.Data = decoded.Data
+	return resp, nil
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleCreateMessage_XML(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	create := func(accept string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"from":                 "+1234567890",
+			"to":                   "+0987654321",
+			"text":                 "Hello <XML> & friends",
+			"media_urls":           []string{"https://example.com/a.jpg"},
+			"messaging_profile_id": "profile-1",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		return rr
+	}
+
+	rr := create("application/xml")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("Expected Content-Type application/xml, got %q", ct)
+	}
+
+	var response xmlMessageResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Expected a valid XML body: %v\n%s", err, rr.Body.String())
+	}
+	data := response.Data
+	if data.ID == "" || data.RecordType != "message" || data.Direction != "outbound" {
+		t.Errorf("Unexpected message fields: %+v", data)
+	}
+	if data.Text != "Hello <XML> & friends" {
+		t.Errorf("Expected text to round-trip through escaping, got %q", data.Text)
+	}
+	if data.From == nil || data.From.PhoneNumber != "+1234567890" {
+		t.Errorf("Unexpected from: %+v", data.From)
+	}
+	if len(data.To) != 1 || data.To[0].PhoneNumber != "+0987654321" || data.To[0].Status != "queued" {
+		t.Errorf("Unexpected to: %+v", data.To)
+	}
+	if len(data.Media) != 1 || data.Type != "MMS" || data.Parts != 1 {
+		t.Errorf("Unexpected media, type or parts: %+v", data)
+	}
+	if data.Cost == nil || data.Cost.Currency != "USD" {
+		t.Errorf("Unexpected cost: %+v", data.Cost)
+	}
+
+	// JSON stays the default, and wins when listed before XML
+	for _, accept := range []string{"", "*/*", "application/json, application/xml"} {
+		rr := create(accept)
+		if !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/json") || !json.Valid(rr.Body.Bytes()) {
+			t.Errorf("Expected a JSON response for Accept %q, got %s", accept, rr.Body.String())
+		}
+	}
+}
+
+func TestHandleCreateMessage_XMLErrorsAndReplays(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	create := func(body map[string]interface{}, idempotencyKey string) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", bytes.NewReader(bodyBytes))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Accept", "application/xml")
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		rr := httptest.NewRecorder()
+		HandleCreateMessage(rr, req)
+		return rr
+	}
+
+	// A validation error is negotiated like the success response
+	rr := create(map[string]interface{}{"from": "+1234567890", "text": "No recipient", "messaging_profile_id": "profile-1"}, "")
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("Expected Content-Type application/xml for an error, got %q", ct)
+	}
+	var errResp xmlErrorResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &errResp); err != nil || len(errResp.Errors) != 1 || errResp.Errors[0].Code != "10005" {
+		t.Errorf("Expected an XML error with code 10005, got %s (%v)", rr.Body.String(), err)
+	}
+
+	// So is a replay of an idempotent request
+	body := map[string]interface{}{
+		"from":                 "+1234567890",
+		"to":                   "+0987654321",
+		"text":                 "Replay me",
+		"messaging_profile_id": "profile-1",
+	}
+	first := create(body, "key-1")
+	replay := create(body, "key-1")
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("Expected a replayed response, got %s", replay.Body.String())
+	}
+	if ct := replay.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("Expected Content-Type application/xml for a replay, got %q", ct)
+	}
+	var original, replayed xmlMessageResponse
+	xml.Unmarshal(first.Body.Bytes(), &original)
+	if err := xml.Unmarshal(replay.Body.Bytes(), &replayed); err != nil {
+		t.Fatalf("Expected a valid XML replay: %v\n%s", err, replay.Body.String())
+	}
+	if replayed.Data.ID != original.Data.ID || replayed.Data.Text != "Replay me" || len(replayed.Data.To) != 1 || replayed.Data.Cost == nil {
+		t.Errorf("Expected the replay to match the original\noriginal: %+v\nreplayed: %+v", original.Data, replayed.Data)
+	}
+}
@@ -0,0 +1,42 @@
+// Package simrand provides the shared random source used by simulations (latency, failure
+// injection, ...). It is safe for concurrent use and can be reseeded for reproducible runs.
+package simrand
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	mu  sync.Mutex
+	rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Seed resets the random source so subsequent values are reproducible
+func Seed(seed int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	rng = rand.New(rand.NewSource(seed))
+}
+
+// Float64 returns a pseudo-random number in [0.0, 1.0)
+func Float64() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.Float64()
+}
+
+// NormFloat64 returns a normally distributed number with mean 0 and standard deviation 1
+func NormFloat64() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.NormFloat64()
+}
+
+// Intn returns a pseudo-random number in [0, n)
+func Intn(n int) int {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.Intn(n)
+}
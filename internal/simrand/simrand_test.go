@@ -0,0 +1,17 @@
+package simrand
+
+import "testing"
+
+func TestSeed_Reproducible(t *testing.T) {
+	Seed(42)
+	first := []float64{Float64(), NormFloat64(), float64(Intn(100))}
+
+	Seed(42)
+	second := []float64{Float64(), NormFloat64(), float64(Intn(100))}
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("Value %d differs after reseeding: %v != %v", i, first[i], second[i])
+		}
+	}
+}
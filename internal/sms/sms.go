@@ -0,0 +1,175 @@
+// Package sms implements message segmentation, simulated pricing and simulated number lookups
+package sms
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Per-segment limits for single and concatenated messages
+const (
+	gsm7SingleLimit = 160
+	gsm7PartLimit   = 153
+	ucs2SingleLimit = 70
+	ucs2PartLimit   = 67
+)
+
+// Simulated prices in USD
+const (
+	SMSPartPrice = 0.0040 // per SMS segment
+	MMSPrice     = 0.0150 // per MMS, regardless of size
+	Currency     = "USD"
+)
+
+// PartCost is the price of a single segment
+type PartCost struct {
+	Part   int    `json:"part"`
+	Amount string `json:"amount"`
+}
+
+// Cost is the simulated price of a message, formatted like Telnyx's cost object
+type Cost struct {
+	Amount    string     `json:"amount"`
+	Currency  string     `json:"currency"`
+	Breakdown []PartCost `json:"breakdown,omitempty"`
+}
+
+// gsm7Extension holds the characters sent as an escape plus a character from the GSM-7
+// extension table, so they take two septets instead of one
+const gsm7Extension = "|^{}[]~\\€"
+
+// gsm7Basic holds the characters of the GSM-7 default alphabet, each sent as a single septet
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// DetectEncoding returns the encoding a carrier picks for text: "GSM-7" when every character is in
+// the GSM-7 alphabet or its extension table, otherwise "UCS-2"
+func DetectEncoding(text string) string {
+	for _, r := range text {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extension, r) {
+			return "UCS-2"
+		}
+	}
+	return "GSM-7"
+}
+
+// CountParts returns how many segments text is split into for the given encoding
+// ("GSM-7" or "UCS-2"). UCS-2 length is measured in UTF-16 code units.
+func CountParts(text, encoding string) int {
+	if encoding == "UCS-2" {
+		length := len(utf16.Encode([]rune(text)))
+		if length <= ucs2SingleLimit {
+			return 1
+		}
+		return (length + ucs2PartLimit - 1) / ucs2PartLimit
+	}
+	return gsm7Parts(text)
+}
+
+// gsm7Parts counts GSM-7 segments, weighting extension characters as two septets. Like real
+// segmentation, an escape is never split from its character, so a part can end a septet short.
+func gsm7Parts(text string) int {
+	septets := 0
+	for _, r := range text {
+		septets += gsm7Septets(r)
+	}
+	if septets <= gsm7SingleLimit {
+		return 1
+	}
+
+	parts, used := 1, 0
+	for _, r := range text {
+		n := gsm7Septets(r)
+		if used+n > gsm7PartLimit {
+			parts++
+			used = 0
+		}
+		used += n
+	}
+	return parts
+}
+
+func gsm7Septets(r rune) int {
+	if strings.ContainsRune(gsm7Extension, r) {
+		return 2
+	}
+	return 1
+}
+
+// EstimateCost prices a message of the given type and segment count. When detailed is set,
+// the result includes a per-part breakdown; an MMS is always billed as a single part.
+func EstimateCost(msgType string, parts int, detailed bool) Cost {
+	price := SMSPartPrice
+	if msgType == "MMS" {
+		price, parts = MMSPrice, 1
+	}
+
+	cost := Cost{
+		Amount:   FormatAmount(price * float64(parts)),
+		Currency: Currency,
+	}
+	if detailed {
+		cost.Breakdown = make([]PartCost, parts)
+		for i := range cost.Breakdown {
+			cost.Breakdown[i] = PartCost{Part: i + 1, Amount: FormatAmount(price)}
+		}
+	}
+	return cost
+}
+
+// FormatAmount renders a price the way Telnyx does, as a decimal string
+func FormatAmount(amount float64) string {
+	return fmt.Sprintf("%.4f", amount)
+}
+
+// Dollars returns the cost's amount as a number, or 0 if it can't be parsed
+func (c Cost) Dollars() float64 {
+	amount, _ := strconv.ParseFloat(c.Amount, 64)
+	return amount
+}
+
+// MockCarrier is the carrier reported for every number
+const MockCarrier = "SmsSink Mock Carrier"
+
+// tollFreePrefixes are the North American toll-free area codes
+var tollFreePrefixes = []string{"+1800", "+1833", "+1844", "+1855", "+1866", "+1877", "+1888"}
+
+// IsTollFree reports whether a number is a North American toll-free number
+func IsTollFree(number string) bool {
+	if len(number) != 12 {
+		return false
+	}
+	for _, prefix := range tollFreePrefixes {
+		if strings.HasPrefix(number, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsShortCode reports whether a number is a 5 or 6 digit short code
+func IsShortCode(number string) bool {
+	if len(number) < 5 || len(number) > 6 {
+		return false
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// LineType derives the line type reported for a number from its shape: "Short Code", "Toll-Free",
+// or "Wireless" for everything else
+func LineType(number string) string {
+	switch {
+	case IsShortCode(number):
+		return "Short Code"
+	case IsTollFree(number):
+		return "Toll-Free"
+	}
+	return "Wireless"
+}
@@ -0,0 +1,124 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountParts(t *testing.T) {
+	tests := []struct {
+		text     string
+		encoding string
+		want     int
+	}{
+		{"", "GSM-7", 1},
+		{strings.Repeat("a", 160), "GSM-7", 1},
+		{strings.Repeat("a", 161), "GSM-7", 2},
+		{strings.Repeat("a", 459), "GSM-7", 3},
+		{strings.Repeat("é", 70), "UCS-2", 1},
+		{strings.Repeat("é", 71), "UCS-2", 2},
+		{strings.Repeat("😀", 36), "UCS-2", 2}, // surrogate pairs count twice
+	}
+
+	for _, tt := range tests {
+		if got := CountParts(tt.text, tt.encoding); got != tt.want {
+			t.Errorf("CountParts(%d chars, %s) = %d, want %d", len([]rune(tt.text)), tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestCountParts_GSM7Extension(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		encoding string
+		want     int
+	}{
+		{"80 pipes fill one part exactly", strings.Repeat("|", 80), "GSM-7", 1},
+		{"81 pipes overflow into two parts", strings.Repeat("|", 81), "GSM-7", 2},
+		{"one extension char tips a full message over", strings.Repeat("a", 159) + "€", "GSM-7", 2},
+		{"every extension char counts double", "|^{}[]~\\€" + strings.Repeat("a", 143), "GSM-7", 2},
+		{"escape pairs aren't split across parts", strings.Repeat("a", 152) + "{" + strings.Repeat("a", 152) + "}", "GSM-7", 3},
+		{"extension-heavy multipart", strings.Repeat("~", 153), "GSM-7", 3}, // 76 pairs per 153-septet part
+		{"extension chars in UCS-2 count once", strings.Repeat("|", 70), "UCS-2", 1},
+	}
+
+	for _, tt := range tests {
+		if got := CountParts(tt.text, tt.encoding); got != tt.want {
+			t.Errorf("%s: CountParts = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "GSM-7"},
+		{"Hello, world! 100% @home", "GSM-7"},
+		{"Ça coûte 5€ {approx}", "UCS-2"}, // û is outside the GSM-7 alphabet
+		{"Café à 5€ [approx]", "GSM-7"},
+		{"こんにちは", "UCS-2"},
+		{"Party 🎉", "UCS-2"},
+	}
+
+	for _, tt := range tests {
+		if got := DetectEncoding(tt.text); got != tt.want {
+			t.Errorf("DetectEncoding(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateCost_Simple(t *testing.T) {
+	cost := EstimateCost("SMS", 2, false)
+	if cost.Amount != "0.0080" || cost.Currency != "USD" {
+		t.Errorf("Expected 0.0080 USD, got %s %s", cost.Amount, cost.Currency)
+	}
+	if cost.Breakdown != nil {
+		t.Errorf("Expected no breakdown by default, got %+v", cost.Breakdown)
+	}
+
+	cost = EstimateCost("MMS", 3, false)
+	if cost.Amount != "0.0150" {
+		t.Errorf("Expected MMS to be billed once, got %s", cost.Amount)
+	}
+}
+
+func TestEstimateCost_DetailedBreakdown(t *testing.T) {
+	parts := CountParts(strings.Repeat("a", 400), "GSM-7")
+	cost := EstimateCost("SMS", parts, true)
+
+	if cost.Amount != "0.0120" {
+		t.Errorf("Expected total 0.0120, got %s", cost.Amount)
+	}
+	if len(cost.Breakdown) != 3 {
+		t.Fatalf("Expected 3 breakdown entries, got %d", len(cost.Breakdown))
+	}
+	for i, part := range cost.Breakdown {
+		if part.Part != i+1 || part.Amount != "0.0040" {
+			t.Errorf("Unexpected breakdown entry %d: %+v", i, part)
+		}
+	}
+}
+
+func TestLineType(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"12345", "Short Code"},
+		{"123456", "Short Code"},
+		{"1234567", "Wireless"},
+		{"+18005550000", "Toll-Free"},
+		{"+18885550000", "Toll-Free"},
+		{"+15551234567", "Wireless"},
+		{"+447700900123", "Wireless"},
+	}
+
+	for _, tt := range tests {
+		if got := LineType(tt.number); got != tt.want {
+			t.Errorf("LineType(%s) = %s, want %s", tt.number, got, tt.want)
+		}
+	}
+}
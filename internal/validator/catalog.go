@@ -0,0 +1,39 @@
+package validator
+
+import "net/http"
+
+// CatalogEntry describes a Telnyx error the mock can return, with the HTTP status it's sent with
+type CatalogEntry struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// ErrorCatalog lists the Telnyx errors the mock knows about, keyed by code. Codes the mock emits
+// use the same title and detail as the handlers that return them.
+var ErrorCatalog = map[string]CatalogEntry{
+	"10000": {"10000", http.StatusInternalServerError, "Internal Server Error", "[SmsSink] An unexpected error occurred."},
+	"10001": {"10001", http.StatusUnauthorized, "Unauthorized", "[SmsSink] Invalid API key."},
+	"10003": {"10003", http.StatusMethodNotAllowed, "Method not allowed", "[SmsSink] Only POST method is supported for this endpoint."},
+	"10004": {"10004", http.StatusNotFound, "Not found", "[SmsSink] The requested resource was not found."},
+	"10005": {"10005", http.StatusUnprocessableEntity, "Invalid parameter", "[SmsSink] The 'to' parameter is required."},
+	"10010": {"10010", http.StatusForbidden, "Forbidden", "[SmsSink] This endpoint is only available in debug mode."},
+	"10011": {"10011", http.StatusTooManyRequests, "Too many requests", "[SmsSink] Rate limit exceeded."},
+	"10013": {"10013", http.StatusForbidden, "Recipient opted out", "[SmsSink] Recipient has opted out."},
+	"10015": {"10015", http.StatusForbidden, "Daily spend limit exceeded", "[SmsSink] Daily spend limit exceeded."},
+	"10016": {"10016", http.StatusServiceUnavailable, "Service unavailable", "[SmsSink] Too many inbound messages are being processed. Retry later."},
+	"30006": {"30006", http.StatusUnprocessableEntity, "Carrier rejected", "[SmsSink] The message was rejected by the carrier before it was sent."},
+	"40008": {"40008", http.StatusUnprocessableEntity, "Message expired", "[SmsSink] The message was not delivered before its valid_until time."},
+	"40010": {"40010", http.StatusForbidden, "Number not registered for 10DLC", "[SmsSink] Number not registered for 10DLC."},
+	"40300": {"40300", http.StatusForbidden, "Blocked due to STOP message", "[SmsSink] The recipient has replied STOP and can't be messaged from this number."},
+	"40310": {"40310", http.StatusBadRequest, "Invalid 'to' address", "[SmsSink] The 'to' address is not a valid phone number."},
+	"40311": {"40311", http.StatusBadRequest, "Invalid 'from' address", "[SmsSink] The 'from' address is not a valid phone number."},
+	"40317": {"40317", http.StatusBadRequest, "Invalid messaging profile", "[SmsSink] The messaging profile does not exist or is disabled."},
+}
+
+// LookupError returns the catalog entry for a Telnyx error code
+func LookupError(code string) (CatalogEntry, bool) {
+	entry, ok := ErrorCatalog[code]
+	return entry, ok
+}
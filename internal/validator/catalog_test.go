@@ -0,0 +1,21 @@
+package validator
+
+import "testing"
+
+func TestErrorCatalog(t *testing.T) {
+	for code, entry := range ErrorCatalog {
+		if entry.Code != code {
+			t.Errorf("Catalog key %s has mismatched code %s", code, entry.Code)
+		}
+		if entry.Status < 400 || entry.Status > 599 {
+			t.Errorf("Catalog entry %s has non-error status %d", code, entry.Status)
+		}
+		if entry.Title == "" || entry.Detail == "" {
+			t.Errorf("Catalog entry %s is missing a title or detail", code)
+		}
+	}
+
+	if _, ok := LookupError("99999"); ok {
+		t.Error("Expected unknown code to be missing from the catalog")
+	}
+}
@@ -2,7 +2,9 @@ package validator
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"telnyx-mock/internal/database"
 )
@@ -19,19 +21,42 @@ type TelnyxErrorResponse struct {
 	Errors []TelnyxError `json:"errors"`
 }
 
+// MaxWebhookDelayMs bounds the per-message webhook_delay_ms override
+const MaxWebhookDelayMs = 60000
+
+// MaxAutoAdvanceMs bounds the per-message auto_advance_after_ms timeout
+const MaxAutoAdvanceMs = 3600000
+
+// MaxWebhookURLs bounds how many consumers one message's webhooks fan out to
+const MaxWebhookURLs = 10
+
+// MaxRecipients bounds how many participants a group MMS "to" array may list
+const MaxRecipients = 8
+
+// MaxForceParts bounds the debug force_parts override; a concatenated SMS has at most 255 parts
+const MaxForceParts = 255
+
 // MessageRequest represents the incoming message request payload
 // Matches Telnyx API v2/messages request format
 // Note: Telnyx accepts "to" as either a string "+1234567890" or an array ["+1234567890"]
 type MessageRequest struct {
-	From               string   `json:"from"`
-	To                 string   `json:"-"` // Handled by custom unmarshal
-	ToRaw              any      `json:"to"`
-	Text               string   `json:"text"`
-	MediaURLs          []string `json:"media_urls"`
-	MessagingProfileID string   `json:"messaging_profile_id"`
-	WebhookURL         string   `json:"webhook_url,omitempty"`
-	WebhookFailoverURL string   `json:"webhook_failover_url,omitempty"`
-	UseProfileWebhooks *bool    `json:"use_profile_webhooks,omitempty"`
+	From               string            `json:"from"`
+	To                 string            `json:"-"` // Handled by custom unmarshal
+	ToRaw              any               `json:"to"`
+	Text               string            `json:"text"`
+	MediaURLs          []string          `json:"media_urls"`
+	MessagingProfileID string            `json:"messaging_profile_id"`
+	WebhookURL         string            `json:"webhook_url,omitempty"`
+	WebhookFailoverURL string            `json:"webhook_failover_url,omitempty"`
+	WebhookURLs        []string          `json:"webhook_urls,omitempty"` // Fan-out: every URL receives each event independently
+	UseProfileWebhooks *bool             `json:"use_profile_webhooks,omitempty"`
+	WebhookHeaders     map[string]string `json:"webhook_headers,omitempty"`       // Extra headers sent with this message's webhooks
+	WebhookDelayMs     *int              `json:"webhook_delay_ms,omitempty"`      // Overrides the wait before each status webhook
+	Priority           string            `json:"priority,omitempty"`              // "high" or "normal" (default); high-priority messages' events go before normal ones
+	SkipWebhooks       bool              `json:"skip_webhooks,omitempty"`         // Suppresses this message's webhooks; its status still advances
+	ManualStatus       bool              `json:"manual_status,omitempty"`         // Hold the message until its status is set via /api/messages/{id}/status
+	AutoAdvanceAfterMs *int              `json:"auto_advance_after_ms,omitempty"` // With manual_status, advance on its own after this long without a manual update
+	ForceParts         *int              `json:"force_parts,omitempty"`           // Debug mode only: reported and billed parts, whatever the text
 	// Additional optional Telnyx fields for API compatibility
 	Type           string `json:"type,omitempty"`            // "SMS" or "MMS"
 	Subject        string `json:"subject,omitempty"`         // MMS subject
@@ -66,6 +91,26 @@ func (m *MessageRequest) NormalizeTo() string {
 	return ""
 }
 
+// Recipients returns every number in the To field: the single number of a string, or each entry
+// of an array, in order. Non-string entries are skipped; validation rejects them.
+func (m *MessageRequest) Recipients() []string {
+	arr, ok := m.ToRaw.([]interface{})
+	if !ok {
+		if to := m.NormalizeTo(); to != "" {
+			return []string{to}
+		}
+		return nil
+	}
+
+	recipients := make([]string, 0, len(arr))
+	for _, v := range arr {
+		if s, ok := v.(string); ok {
+			recipients = append(recipients, s)
+		}
+	}
+	return recipients
+}
+
 // WriteError writes a Telnyx-formatted error response
 func WriteError(w http.ResponseWriter, code, title, detail string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
@@ -84,9 +129,9 @@ func WriteError(w http.ResponseWriter, code, title, detail string, statusCode in
 	json.NewEncoder(w).Encode(response)
 }
 
-// ValidateMessageRequest performs strict validation on the message request
+// ValidateAuthorization checks the request's Authorization header against the stored credential
 // Returns nil if valid, or an error response that should be written
-func ValidateMessageRequest(r *http.Request, req *MessageRequest) (int, *TelnyxErrorResponse) {
+func ValidateAuthorization(r *http.Request) (int, *TelnyxErrorResponse) {
 	// Check Authorization header
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -119,6 +164,16 @@ func ValidateMessageRequest(r *http.Request, req *MessageRequest) (int, *TelnyxE
 		}
 	}
 
+	return 0, nil
+}
+
+// ValidateMessageRequest performs strict validation on the message request
+// Returns nil if valid, or an error response that should be written
+func ValidateMessageRequest(r *http.Request, req *MessageRequest) (int, *TelnyxErrorResponse) {
+	if statusCode, errResp := ValidateAuthorization(r); errResp != nil {
+		return statusCode, errResp
+	}
+
 	// 'from' is optional - Telnyx can infer it from the messaging profile
 	// If not provided, use a placeholder indicating it came from the profile
 	if req.From == "" {
@@ -139,6 +194,34 @@ func ValidateMessageRequest(r *http.Request, req *MessageRequest) (int, *TelnyxE
 		}
 	}
 
+	// A "to" array lists a group MMS's participants, each a number
+	if arr, ok := req.ToRaw.([]interface{}); ok {
+		if len(arr) > MaxRecipients {
+			return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+				Errors: []TelnyxError{
+					{
+						Code:   "10005",
+						Title:  "Invalid parameter",
+						Detail: fmt.Sprintf("[SmsSink] The 'to' parameter may contain at most %d recipients.", MaxRecipients),
+					},
+				},
+			}
+		}
+		for _, v := range arr {
+			if s, ok := v.(string); !ok || s == "" {
+				return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+					Errors: []TelnyxError{
+						{
+							Code:   "10005",
+							Title:  "Invalid parameter",
+							Detail: "[SmsSink] Each entry of the 'to' parameter must be a phone number.",
+						},
+					},
+				}
+			}
+		}
+	}
+
 	// Validate 'messaging_profile_id' field
 	if req.MessagingProfileID == "" {
 		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
@@ -152,8 +235,26 @@ func ValidateMessageRequest(r *http.Request, req *MessageRequest) (int, *TelnyxE
 		}
 	}
 
-	// Validate that at least one of 'text' or 'media_urls' is present
-	if req.Text == "" && (req.MediaURLs == nil || len(req.MediaURLs) == 0) {
+	// An explicit MMS needs content too, though a subject alone is enough on accounts that
+	// classify subject-only messages as MMS
+	if req.Type == "MMS" && req.Text == "" && len(req.MediaURLs) == 0 {
+		if req.Subject == "" || !database.GetBoolSetting("classify_mms_on_subject", false) {
+			return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+				Errors: []TelnyxError{
+					{
+						Code:   "10005",
+						Title:  "Invalid parameter",
+						Detail: "[SmsSink] Messages with type 'MMS' require 'media_urls', 'text' or a 'subject'.",
+					},
+				},
+			}
+		}
+	}
+
+	// Validate that at least one of 'text' or 'media_urls' is present; an explicit MMS that
+	// passed the check above may carry only a subject
+	subjectOnlyMMS := req.Type == "MMS" && req.Subject != ""
+	if req.Text == "" && (req.MediaURLs == nil || len(req.MediaURLs) == 0) && !subjectOnlyMMS {
 		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
 			Errors: []TelnyxError{
 				{
@@ -165,5 +266,158 @@ func ValidateMessageRequest(r *http.Request, req *MessageRequest) (int, *TelnyxE
 		}
 	}
 
+	// SMS can't carry media, and MMS is capped at a configurable number of media URLs
+	if req.Type == "SMS" && len(req.MediaURLs) > 0 {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: "[SmsSink] Messages with type 'SMS' cannot include 'media_urls'.",
+				},
+			},
+		}
+	}
+	if maxMedia := database.GetMMSMaxMedia(); len(req.MediaURLs) > maxMedia {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: fmt.Sprintf("[SmsSink] The 'media_urls' parameter may contain at most %d URLs.", maxMedia),
+				},
+			},
+		}
+	}
+
+	// Validate custom webhook header names
+	for name := range req.WebhookHeaders {
+		if !ValidHeaderName(name) {
+			return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+				Errors: []TelnyxError{
+					{
+						Code:   "10005",
+						Title:  "Invalid parameter",
+						Detail: "[SmsSink] The 'webhook_headers' parameter contains an invalid header name: '" + name + "'.",
+					},
+				},
+			}
+		}
+	}
+
+	if req.WebhookDelayMs != nil && (*req.WebhookDelayMs < 0 || *req.WebhookDelayMs > MaxWebhookDelayMs) {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: fmt.Sprintf("[SmsSink] The 'webhook_delay_ms' parameter must be between 0 and %d.", MaxWebhookDelayMs),
+				},
+			},
+		}
+	}
+
+	if req.Priority != "" && req.Priority != "high" && req.Priority != "normal" {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: "[SmsSink] The 'priority' parameter must be 'high' or 'normal'.",
+				},
+			},
+		}
+	}
+
+	if req.AutoAdvanceAfterMs != nil && !req.ManualStatus {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: "[SmsSink] The 'auto_advance_after_ms' parameter requires 'manual_status'.",
+				},
+			},
+		}
+	}
+
+	if req.ForceParts != nil && (*req.ForceParts < 1 || *req.ForceParts > MaxForceParts) {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: fmt.Sprintf("[SmsSink] The 'force_parts' parameter must be between 1 and %d.", MaxForceParts),
+				},
+			},
+		}
+	}
+
+	if req.AutoAdvanceAfterMs != nil && (*req.AutoAdvanceAfterMs < 1 || *req.AutoAdvanceAfterMs > MaxAutoAdvanceMs) {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: fmt.Sprintf("[SmsSink] The 'auto_advance_after_ms' parameter must be between 1 and %d.", MaxAutoAdvanceMs),
+				},
+			},
+		}
+	}
+
+	if len(req.WebhookURLs) > 0 && (req.WebhookURL != "" || req.WebhookFailoverURL != "") {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: "[SmsSink] The 'webhook_urls' parameter can't be combined with 'webhook_url' or 'webhook_failover_url'.",
+				},
+			},
+		}
+	}
+
+	if len(req.WebhookURLs) > MaxWebhookURLs {
+		return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+			Errors: []TelnyxError{
+				{
+					Code:   "10005",
+					Title:  "Invalid parameter",
+					Detail: fmt.Sprintf("[SmsSink] The 'webhook_urls' parameter may contain at most %d URLs.", MaxWebhookURLs),
+				},
+			},
+		}
+	}
+
+	for _, webhookURL := range req.WebhookURLs {
+		if webhookURL == "" {
+			return http.StatusUnprocessableEntity, &TelnyxErrorResponse{
+				Errors: []TelnyxError{
+					{
+						Code:   "10005",
+						Title:  "Invalid parameter",
+						Detail: "[SmsSink] The 'webhook_urls' parameter can't contain empty URLs.",
+					},
+				},
+			}
+		}
+	}
+
 	return 0, nil // Valid request
 }
+
+// ValidHeaderName reports whether name is a valid HTTP header field name (an RFC 7230 token)
+func ValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
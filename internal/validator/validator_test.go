@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 
 	"telnyx-mock/internal/database"
@@ -279,3 +280,186 @@ func TestValidateMessageRequest_ToAsArray(t *testing.T) {
 		t.Errorf("Expected To to be normalized to '+0987654321', got '%s'", msgReq.To)
 	}
 }
+
+func TestValidateMessageRequest_GroupRecipients(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	msgReq := &MessageRequest{
+		From:               "+1234567890",
+		ToRaw:              []interface{}{"+15550000001", "+15550000002", "+15550000003"},
+		Text:               "Hello",
+		MessagingProfileID: "profile-123",
+	}
+	if statusCode, errResp := ValidateMessageRequest(req, msgReq); errResp != nil {
+		t.Fatalf("Expected a valid group message, got %d %+v", statusCode, errResp)
+	}
+
+	recipients := msgReq.Recipients()
+	if len(recipients) != 3 || recipients[0] != "+15550000001" || recipients[2] != "+15550000003" {
+		t.Errorf("Expected all three recipients in order, got %v", recipients)
+	}
+	if msgReq.To != "+15550000001" {
+		t.Errorf("Expected To to be the first recipient, got '%s'", msgReq.To)
+	}
+
+	invalid := [][]interface{}{
+		{"+15550000001", ""},
+		{"+15550000001", 15550000002.0},
+		{"+1", "+2", "+3", "+4", "+5", "+6", "+7", "+8", "+9"},
+	}
+	for _, to := range invalid {
+		msgReq := &MessageRequest{
+			From:               "+1234567890",
+			ToRaw:              to,
+			Text:               "Hello",
+			MessagingProfileID: "profile-123",
+		}
+		statusCode, errResp := ValidateMessageRequest(req, msgReq)
+		if statusCode != http.StatusUnprocessableEntity || errResp == nil || errResp.Errors[0].Code != "10005" {
+			t.Errorf("Expected 422 with code 10005 for to=%v, got %d %+v", to, statusCode, errResp)
+		}
+	}
+}
+
+func TestValidateMessageRequest_InvalidWebhookHeaderName(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	msgReq := &MessageRequest{
+		From:               "+1234567890",
+		To:                 "+0987654321",
+		Text:               "Hello",
+		MessagingProfileID: "profile-123",
+		WebhookHeaders:     map[string]string{"X Tenant": "acme"},
+	}
+
+	statusCode, errResp := ValidateMessageRequest(req, msgReq)
+	if statusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, statusCode)
+	}
+	if errResp == nil || errResp.Errors[0].Code != "10005" {
+		t.Errorf("Expected error code 10005, got %+v", errResp)
+	}
+}
+
+func TestValidHeaderName(t *testing.T) {
+	valid := []string{"X-Tenant-Id", "x_custom", "Authorization"}
+	invalid := []string{"", "X Tenant", "X-Tenant:", "Ünicode", "X\r\nInjected"}
+
+	for _, name := range valid {
+		if !ValidHeaderName(name) {
+			t.Errorf("Expected '%s' to be a valid header name", name)
+		}
+	}
+	for _, name := range invalid {
+		if ValidHeaderName(name) {
+			t.Errorf("Expected '%q' to be an invalid header name", name)
+		}
+	}
+}
+
+func TestValidateMessageRequest_SMSWithMedia(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	msgReq := &MessageRequest{
+		From:               "+1234567890",
+		To:                 "+0987654321",
+		Text:               "Hello",
+		MediaURLs:          []string{"https://example.com/image.jpg"},
+		MessagingProfileID: "profile-123",
+		Type:               "SMS",
+	}
+
+	statusCode, errResp := ValidateMessageRequest(req, msgReq)
+	if statusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, statusCode)
+	}
+	if errResp == nil || errResp.Errors[0].Code != "10005" {
+		t.Errorf("Expected error code 10005, got %+v", errResp)
+	}
+}
+
+func TestValidateMessageRequest_MediaOverCap(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/messages", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	mediaURLs := make([]string, 11)
+	for i := range mediaURLs {
+		mediaURLs[i] = "https://example.com/image.jpg"
+	}
+	msgReq := &MessageRequest{
+		From:               "+1234567890",
+		To:                 "+0987654321",
+		MediaURLs:          mediaURLs,
+		MessagingProfileID: "profile-123",
+	}
+
+	statusCode, errResp := ValidateMessageRequest(req, msgReq)
+	if statusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for 11 media URLs, got %d", http.StatusUnprocessableEntity, statusCode)
+	}
+	if errResp == nil || errResp.Errors[0].Code != "10005" {
+		t.Errorf("Expected error code 10005, got %+v", errResp)
+	}
+
+	// Raising the cap allows the same request
+	database.SetSetting("mms_max_media", "20")
+	if statusCode, errResp := ValidateMessageRequest(req, msgReq); statusCode != 0 {
+		t.Errorf("Expected request to be valid with a cap of 20, got %d: %+v", statusCode, errResp)
+	}
+}
+
+func TestValidateMessageRequest_EmptyMMS(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tests := []struct {
+		name            string
+		text            string
+		subject         string
+		classifySubject bool
+		wantStatus      int
+	}{
+		{"text only", "Hello", "", false, 0},
+		{"nothing", "", "", false, http.StatusUnprocessableEntity},
+		{"subject only with classification off", "", "Photos", false, http.StatusUnprocessableEntity},
+		{"subject only with classification on", "", "Photos", true, 0},
+	}
+
+	for _, tt := range tests {
+		database.SetSetting("classify_mms_on_subject", strconv.FormatBool(tt.classifySubject))
+
+		req := httptest.NewRequest(http.MethodPost, "/v2/messages", nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		msgReq := &MessageRequest{
+			From:               "+1234567890",
+			To:                 "+0987654321",
+			Text:               tt.text,
+			Subject:            tt.subject,
+			MessagingProfileID: "profile-123",
+			Type:               "MMS",
+		}
+
+		statusCode, errResp := ValidateMessageRequest(req, msgReq)
+		if statusCode != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, statusCode)
+		}
+		if tt.wantStatus != 0 && (errResp == nil || errResp.Errors[0].Code != "10005") {
+			t.Errorf("%s: expected error code 10005, got %+v", tt.name, errResp)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+
+	"telnyx-mock/internal/database"
+)
+
+// dumpPayload writes an encoded webhook body to dir as <message ID>_<event type>.json, creating
+// dir if needed. Failures are logged and never affect delivery.
+func dumpPayload(dir, messageID, eventType string, body []byte) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		database.LogError("webhook", "Failed to create webhook dump directory", map[string]interface{}{
+			"error": err.Error(),
+			"dir":   dir,
+		})
+		return
+	}
+
+	// Base keeps a message ID containing a separator from writing outside dir
+	path := filepath.Join(dir, filepath.Base(messageID+"_"+eventType+".json"))
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		database.LogError("webhook", "Failed to write webhook payload to dump directory", map[string]interface{}{
+			"error":      err.Error(),
+			"path":       path,
+			"message_id": messageID,
+			"event_type": eventType,
+		})
+	}
+}
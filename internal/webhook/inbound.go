@@ -0,0 +1,45 @@
+package webhook
+
+import "time"
+
+// SendInboundCallbacks asynchronously sends message.received for an inbound message to its webhook
+// URL and, when readDelay is set, message.read that long afterwards, as a read-receipt capable
+// channel would. Both come from one goroutine, so message.read never arrives first.
+func SendInboundCallbacks(msg MessageDetails, readDelay *time.Duration) {
+	if !msg.hasWebhooks() {
+		return
+	}
+
+	cancelled := pendingSignal()
+	inFlight.Add(1)
+	go func() {
+		defer inFlight.Add(-1)
+		receivedAt := time.Now().UTC()
+		sendWebhook(msg, buildInboundPayload(msg, "message.received", receivedAt, receivedAt))
+
+		if readDelay == nil || !wait(cancelled, *readDelay) {
+			return
+		}
+		sendWebhook(msg, buildInboundPayload(msg, "message.read", receivedAt, time.Now().UTC()))
+	}()
+}
+
+// buildInboundPayload builds a message.received or message.read webhook for an inbound message
+func buildInboundPayload(msg MessageDetails, eventType string, receivedAt, occurredAt time.Time) TelnyxWebhookPayload {
+	payload := buildBasePayload(msg)
+	payload["direction"] = "inbound"
+	payload["received_at"] = receivedAt.Format(time.RFC3339)
+	if eventType == "message.read" {
+		payload["read_at"] = occurredAt.Format(time.RFC3339)
+	}
+
+	return TelnyxWebhookPayload{
+		Data: TelnyxWebhookData{
+			EventType:  eventType,
+			ID:         IDGenerator(),
+			OccurredAt: occurredAt.Format(time.RFC3339),
+			Payload:    payload,
+			RecordType: "event",
+		},
+	}
+}
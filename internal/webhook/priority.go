@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// priorityQueue models a carrier queue with two priorities: while any high-priority message still
+// has events to send, normal messages hold theirs, so high-priority messages are delivered first
+// whatever their timing relative to the normal ones
+var priorityQueue = struct {
+	mu       sync.Mutex
+	high     int           // High-priority messages with events still to send
+	released chan struct{} // Closed when high drops to zero
+}{released: make(chan struct{})}
+
+// enterHighPriority registers a high-priority message whose events are about to be scheduled.
+// It's called before the message's goroutine starts, so a normal message created right after
+// already sees it.
+func enterHighPriority() {
+	priorityQueue.mu.Lock()
+	defer priorityQueue.mu.Unlock()
+	priorityQueue.high++
+}
+
+// leaveHighPriority unregisters a high-priority message once it has no events left, releasing
+// the normal messages held behind it when it was the last one
+func leaveHighPriority() {
+	priorityQueue.mu.Lock()
+	defer priorityQueue.mu.Unlock()
+	priorityQueue.high--
+	if priorityQueue.high == 0 {
+		close(priorityQueue.released)
+		priorityQueue.released = make(chan struct{})
+	}
+}
+
+// holdForHighPriority blocks a normal message's next event until no high-priority message has
+// events pending, returning how long it was held. It returns false if cancelled is closed first.
+func holdForHighPriority(cancelled <-chan struct{}) (time.Duration, bool) {
+	start := time.Now()
+	for {
+		priorityQueue.mu.Lock()
+		if priorityQueue.high == 0 {
+			priorityQueue.mu.Unlock()
+			return time.Since(start), true
+		}
+		released := priorityQueue.released
+		priorityQueue.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-cancelled:
+			return time.Since(start), false
+		}
+	}
+}
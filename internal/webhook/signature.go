@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+
+	"telnyx-mock/internal/database"
+)
+
+// signedContent builds the string Telnyx signs: the timestamp and raw body joined by "|"
+func signedContent(timestamp string, body []byte) []byte {
+	return append([]byte(timestamp+"|"), body...)
+}
+
+// SignPayload returns the base64 ed25519 signature sent in telnyx-signature-ed25519
+func SignPayload(timestamp string, body []byte) (string, error) {
+	key, err := database.GetWebhookSigningKey()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, signedContent(timestamp, body))), nil
+}
+
+// VerifySignature checks a signature against the current and previous signing keys, returning
+// "current" or "previous" for the key that matched, or "" if neither did
+func VerifySignature(payload, timestamp, signature string) (string, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return "", nil
+	}
+
+	current, err := database.GetWebhookSigningKey()
+	if err != nil {
+		return "", err
+	}
+	previous, err := database.GetPreviousWebhookSigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	content := signedContent(timestamp, []byte(payload))
+	if ed25519.Verify(current.Public().(ed25519.PublicKey), content, sig) {
+		return "current", nil
+	}
+	if previous != nil && ed25519.Verify(previous.Public().(ed25519.PublicKey), content, sig) {
+		return "previous", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,31 @@
+package webhook
+
+import "testing"
+
+func TestVerifySignature(t *testing.T) {
+	payload := `{"data":{"event_type":"message.sent"}}`
+	timestamp := "2024-01-01T12:00:00Z"
+
+	signature, err := SignPayload(timestamp, []byte(payload))
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+
+	matched, err := VerifySignature(payload, timestamp, signature)
+	if err != nil {
+		t.Fatalf("Failed to verify signature: %v", err)
+	}
+	if matched != "current" {
+		t.Errorf("Expected signature to match current key, got '%s'", matched)
+	}
+
+	matched, _ = VerifySignature(payload+" ", timestamp, signature)
+	if matched != "" {
+		t.Errorf("Expected tampered payload to fail verification, got '%s'", matched)
+	}
+
+	matched, _ = VerifySignature(payload, timestamp, "not-base64!")
+	if matched != "" {
+		t.Errorf("Expected malformed signature to fail verification, got '%s'", matched)
+	}
+}
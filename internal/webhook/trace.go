@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// requestTiming records connection phase durations for a single webhook request
+type requestTiming struct {
+	start time.Time
+
+	dnsStart, connectStart, tlsStart, wroteRequest time.Time
+
+	DNS        time.Duration
+	Connect    time.Duration
+	TLS        time.Duration
+	FirstByte  time.Duration // From request written to first response byte
+	Total      time.Duration
+	ReusedConn bool
+}
+
+// withClientTrace attaches httptrace hooks that fill in the timing as the request progresses
+func (t *requestTiming) withClientTrace(req *http.Request) *http.Request {
+	t.start = time.Now()
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			if !t.dnsStart.IsZero() {
+				t.DNS = time.Since(t.dnsStart)
+			}
+		},
+		ConnectStart: func(string, string) { t.connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			if !t.connectStart.IsZero() {
+				t.Connect = time.Since(t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			if !t.tlsStart.IsZero() {
+				t.TLS = time.Since(t.tlsStart)
+			}
+		},
+		GotConn:      func(info httptrace.GotConnInfo) { t.ReusedConn = info.Reused },
+		WroteRequest: func(httptrace.WroteRequestInfo) { t.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() {
+			if !t.wroteRequest.IsZero() {
+				t.FirstByte = time.Since(t.wroteRequest)
+			}
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// finish records the total request duration
+func (t *requestTiming) finish() {
+	t.Total = time.Since(t.start)
+}
+
+// fields returns the phase durations in milliseconds, for inclusion in log details
+func (t *requestTiming) fields() map[string]interface{} {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return map[string]interface{}{
+		"dns_ms":        ms(t.DNS),
+		"connect_ms":    ms(t.Connect),
+		"tls_ms":        ms(t.TLS),
+		"first_byte_ms": ms(t.FirstByte),
+		"total_ms":      ms(t.Total),
+		"reused_conn":   t.ReusedConn,
+	}
+}
+
+// withTiming adds the request timing to log details when tracing was enabled
+func withTiming(details map[string]interface{}, timing *requestTiming) map[string]interface{} {
+	if timing != nil {
+		details["timing"] = timing.fields()
+	}
+	return details
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoWebhookRequest_RecordsTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	timing := &requestTiming{}
+	if _, err := doWebhookRequest(server.URL, []byte(`{}`), nil, timing); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if timing.FirstByte < 20*time.Millisecond {
+		t.Errorf("Expected first byte after at least 20ms, got %v", timing.FirstByte)
+	}
+	if timing.Total < timing.FirstByte {
+		t.Errorf("Expected total (%v) to cover first byte (%v)", timing.Total, timing.FirstByte)
+	}
+
+	fields := timing.fields()
+	for _, key := range []string{"dns_ms", "connect_ms", "tls_ms", "first_byte_ms", "total_ms", "reused_conn"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("Expected timing field '%s'", key)
+		}
+	}
+}
+
+func TestNewRequestTiming_OnlyInDebugMode(t *testing.T) {
+	t.Setenv("SMSSINK_DEBUG", "")
+	if newRequestTiming() != nil {
+		t.Error("Expected no tracing when debug mode is off")
+	}
+
+	t.Setenv("SMSSINK_DEBUG", "true")
+	if newRequestTiming() == nil {
+		t.Error("Expected tracing when debug mode is on")
+	}
+}
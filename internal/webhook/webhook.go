@@ -2,26 +2,63 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"telnyx-mock/internal/database"
+	"telnyx-mock/internal/events"
+	"telnyx-mock/internal/simrand"
+	"telnyx-mock/internal/sms"
 )
 
+// DeliveryEvent describes the outcome of a single webhook delivery attempt
+type DeliveryEvent struct {
+	URL        string `json:"url"`
+	EventType  string `json:"event_type"`
+	MessageID  string `json:"message_id"`
+	StatusCode int    `json:"status_code"` // 0 when no HTTP response was received
+	Attempt    int    `json:"attempt"`     // 1 for the primary URL, 2 for the failover URL
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+// EventTypes lists the message events the mock can deliver: outbound ones in lifecycle order,
+// then the inbound ones
+var EventTypes = []string{"message.sent", "message.delivered", "message.failed", "message.received", "message.read"}
+
+// Deliveries publishes a DeliveryEvent for every webhook delivery attempt
+var Deliveries = events.NewBroadcaster[DeliveryEvent](64)
+
 // MessageDetails contains info needed for webhook callbacks
 type MessageDetails struct {
 	ID                 string
 	From               string
 	To                 string
+	Recipients         []string // Every participant of a group message; empty means just To
+	FailedRecipients   []string // Group participants the carrier rejects while the rest are delivered
 	Text               string
 	MediaURLs          []string
 	MessagingProfileID string
 	Type               string
+	Parts              int
+	Cost               *sms.Cost // Reported in the message.delivered payload
 	WebhookURL         string
 	WebhookFailoverURL string
+	WebhookURLs        []string          // Fan-out consumers; when set, each gets every event instead of WebhookURL/WebhookFailoverURL
+	Headers            map[string]string // Per-message custom headers, sent in addition to webhook_custom_headers
+	RejectReason       *FailureReason    // When set, the carrier rejects the message: queued → failed, never sent
+	Delay              *time.Duration    // When set, overrides the wait before each status webhook
+	Priority           string            // "high" delivers before normal messages, which hold their events while it has any pending
 }
 
 // TelnyxWebhookPayload represents the standard Telnyx webhook format
@@ -38,88 +75,435 @@ type TelnyxWebhookData struct {
 	RecordType string                 `json:"record_type"`
 }
 
-// SendStatusCallbacks sends a series of status webhooks simulating message delivery
+// IDGenerator returns the ID of each webhook event, random UUIDs by default. It's meant for tests:
+// swapping in a predictable generator lets full payloads be compared against golden files.
+var IDGenerator = func() string {
+	return uuid.New().String()
+}
+
+// inFlight counts the status and failure goroutines that haven't finished yet
+var inFlight atomic.Int64
+
+// InFlight returns how many webhook goroutines are still running; steady growth points to
+// deliveries that never complete
+func InFlight() int64 {
+	return inFlight.Load()
+}
+
+// pending is closed by CancelPending to wake the goroutines waiting to send their next event
+var (
+	pendingMu sync.Mutex
+	pending   = make(chan struct{})
+)
+
+// CancelPending stops the webhook goroutines waiting out a delay before their next event; they
+// send nothing more. Deliveries already under way still finish, so follow with Drain to wait
+// for them.
+func CancelPending() {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	close(pending)
+	pending = make(chan struct{})
+}
+
+// pendingSignal returns the channel the next CancelPending closes. Goroutines take it before they
+// start, so a cancel can't be missed between waits.
+func pendingSignal() <-chan struct{} {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	return pending
+}
+
+// wait sleeps for d, returning false if cancelled is closed first
+func wait(cancelled <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-cancelled:
+		return false
+	}
+}
+
+// drainPollInterval is how often Drain checks for remaining webhook goroutines
+const drainPollInterval = 50 * time.Millisecond
+
+// Drain waits for the in-flight webhook goroutines to finish, returning ctx's error if it's done
+// first. It's meant for shutdown, once no new messages can arrive.
+func Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// SendStatusCallbacks advances a message through its delivery statuses, updating the stored status
+// at each step, and sends a status webhook for each step when the message has a webhook URL.
 // Telnyx sends: message.queued → message.sent → message.delivered (or message.failed)
+// The statuses advance either way, so clients that poll for status see the same progression.
+// A message's events are sent one at a time from a single goroutine, each only after the previous
+// delivery (including its failover attempt) has returned, so they arrive in lifecycle order even
+// when every delay is zero or out_of_order_delivery scrambles the timing between messages.
+// High-priority messages are delivered first: a normal message's events wait while any
+// high-priority message still has events to send.
 func SendStatusCallbacks(msg MessageDetails) {
-	if msg.WebhookURL == "" {
-		return
+	cancelled := pendingSignal()
+	highPriority := msg.Priority == "high"
+	if highPriority {
+		enterHighPriority()
 	}
-
+	inFlight.Add(1)
 	go func() {
+		defer inFlight.Add(-1)
+		if highPriority {
+			defer leaveHighPriority()
+		}
 		now := time.Now().UTC()
 
-		// Build base payload
-		basePayload := map[string]interface{}{
-			"id":                   msg.ID,
-			"record_type":          "message",
-			"direction":            "outbound",
-			"messaging_profile_id": msg.MessagingProfileID,
-			"from": map[string]interface{}{
-				"phone_number": msg.From,
-				"carrier":      "SmsSink Mock Carrier",
-				"line_type":    "Wireless",
-			},
-			"to": []map[string]interface{}{
-				{
-					"phone_number": msg.To,
-					"carrier":      "SmsSink Mock Carrier",
-					"line_type":    "Wireless",
-				},
-			},
-			"text":  msg.Text,
-			"media": msg.MediaURLs,
-			"type":  msg.Type,
+		// Normal messages hold each event while a high-priority message has events pending
+		hold := func() (time.Duration, bool) {
+			if highPriority {
+				return 0, true
+			}
+			return holdForHighPriority(cancelled)
 		}
 
+		// Give the client time to record the message ID before the first event arrives
+		initialDelay := firstEventDelay(msg)
+		if !wait(cancelled, initialDelay) {
+			return
+		}
+
+		// A carrier reject fails the message before it is ever sent
+		if msg.RejectReason != nil {
+			if msg.Delay != nil && !wait(cancelled, *msg.Delay) {
+				return
+			}
+			if _, ok := hold(); !ok {
+				return
+			}
+
+			if !advanceStatus(msg.ID, "failed") {
+				return
+			}
+			if msg.hasWebhooks() {
+				sendWebhook(msg, buildFailedPayload(msg, "failed", *msg.RejectReason))
+			}
+			return
+		}
+
+		// Rejected group participants fail as the rest of the group is sent
+		failRecipients(msg)
+
+		sentDelay, deliveredDelay := eventDelays(msg, database.GetBoolSetting("out_of_order_delivery", false))
+
 		// Status sequence with delays to simulate real-world timing
 		statuses := []struct {
 			eventType string
 			status    string
 			delay     time.Duration
 		}{
-			{"message.sent", "sent", 500 * time.Millisecond},
-			{"message.delivered", "delivered", 1500 * time.Millisecond},
+			{"message.sent", "sent", sentDelay},
+			{"message.delivered", "delivered", deliveredDelay},
 		}
 
+		var sentAt time.Time
+		elapsed := initialDelay
 		for _, s := range statuses {
-			time.Sleep(s.delay)
-
-			payload := copyMap(basePayload)
-			payload["status"] = s.status
-
-			// Add timestamps based on status
-			occurredAt := now.Add(s.delay).Format(time.RFC3339)
-			switch s.status {
-			case "sent":
-				payload["sent_at"] = occurredAt
-			case "delivered":
-				payload["sent_at"] = now.Add(500 * time.Millisecond).Format(time.RFC3339)
-				payload["completed_at"] = occurredAt
+			if !wait(cancelled, s.delay) {
+				return
+			}
+			held, ok := hold()
+			if !ok {
+				return
+			}
+			elapsed += s.delay + held
+			if s.status == "sent" {
+				sentAt = now.Add(elapsed)
 			}
 
-			// Update the to array status
-			if toArr, ok := payload["to"].([]map[string]interface{}); ok && len(toArr) > 0 {
-				toArr[0]["status"] = s.status
+			// A message expired (or otherwise finished) in the meantime gets no further events
+			if !advanceStatus(msg.ID, s.status) {
+				return
 			}
 
-			webhookPayload := TelnyxWebhookPayload{
-				Data: TelnyxWebhookData{
-					EventType:  s.eventType,
-					ID:         uuid.New().String(),
-					OccurredAt: occurredAt,
-					Payload:    payload,
-					RecordType: "event",
-				},
+			if msg.hasWebhooks() {
+				webhookPayload := buildStatusPayload(msg, s.eventType, s.status, sentAt, now.Add(elapsed))
+				sendWebhook(msg, webhookPayload)
 			}
+		}
+	}()
+}
 
-			sendWebhook(msg.WebhookURL, msg.WebhookFailoverURL, webhookPayload)
+// SendStatusUpdate moves a message straight to status ("sent", "delivered" or "failed"), storing it
+// and sending the matching webhook when the message has a webhook URL. It's the single-step
+// counterpart to SendStatusCallbacks for messages whose status is set by hand; sentAt is when the
+// message was sent, reported in the delivered payload.
+func SendStatusUpdate(msg MessageDetails, status string, sentAt time.Time) {
+	if status != "failed" {
+		failRecipients(msg)
+	}
+	if !advanceStatus(msg.ID, status) || !msg.hasWebhooks() {
+		return
+	}
+
+	if status == "failed" {
+		reason := DeliveryFailedReason
+		if msg.RejectReason != nil {
+			reason = *msg.RejectReason
 		}
+		sendWebhook(msg, buildFailedPayload(msg, status, reason))
+		return
+	}
+	sendWebhook(msg, buildStatusPayload(msg, "message."+status, status, sentAt, time.Now().UTC()))
+}
+
+// advanceStatus stores a message's next status, reporting whether its events should continue: false
+// when the message had already reached a final status. A failed write is logged and the events
+// carry on, as the webhook still reflects the simulated carrier.
+func advanceStatus(id, status string) bool {
+	updated, err := database.UpdateMessageStatus(id, status)
+	if err != nil {
+		log.Printf("Webhook: Failed to update message status: %v", err)
+		return true
+	}
+	return updated
+}
+
+// BuildDeliveredPayload builds the message.delivered webhook SendStatusCallbacks sends for a
+// message created at createdAt, with timestamps following the in-order timing. Time a normal
+// message spent held behind high-priority messages isn't recorded, so it's left out.
+func BuildDeliveredPayload(msg MessageDetails, createdAt time.Time) TelnyxWebhookPayload {
+	sentDelay, deliveredDelay := eventDelays(msg, false)
+	sentAt := createdAt.Add(firstEventDelay(msg) + sentDelay)
+	return buildStatusPayload(msg, "message.delivered", "delivered", sentAt, sentAt.Add(deliveredDelay))
+}
+
+// buildStatusPayload builds a message.sent or message.delivered webhook occurring at occurredAt
+func buildStatusPayload(msg MessageDetails, eventType, status string, sentAt, occurredAt time.Time) TelnyxWebhookPayload {
+	payload := buildBasePayload(msg)
+	payload["status"] = status
+
+	// Add timestamps based on status; completed_at is the terminal time, null until then
+	switch status {
+	case "sent":
+		payload["sent_at"] = occurredAt.Format(time.RFC3339)
+		payload["completed_at"] = nil
+	case "delivered":
+		payload["sent_at"] = sentAt.Format(time.RFC3339)
+		payload["completed_at"] = occurredAt.Format(time.RFC3339)
+		payload["cost"] = msg.Cost
+	}
+
+	// Update the to array status; rejected group participants stay failed
+	if toArr, ok := payload["to"].([]map[string]interface{}); ok {
+		for _, recipient := range toArr {
+			recipient["status"] = status
+			if number, _ := recipient["phone_number"].(string); msg.recipientFailed(number) {
+				recipient["status"] = "failed"
+			}
+		}
+	}
+
+	return TelnyxWebhookPayload{
+		Data: TelnyxWebhookData{
+			EventType:  eventType,
+			ID:         IDGenerator(),
+			OccurredAt: occurredAt.Format(time.RFC3339),
+			Payload:    payload,
+			RecordType: "event",
+		},
+	}
+}
+
+// Status webhook timing: fixed delays keep events from concurrent messages in send order, while
+// out-of-order delivery draws each delay from [0, maxStatusJitter)
+const (
+	defaultDeliveredDelay = 1500 * time.Millisecond
+	maxStatusJitter       = 3 * time.Second
+)
+
+// statusDelays returns how long to wait before message.sent (on top of the initial delay), and
+// then before message.delivered. Random delays use simrand so a fixed random_seed reproduces the
+// same scrambled order.
+func statusDelays(outOfOrder bool) (sent, delivered time.Duration) {
+	if !outOfOrder {
+		return 0, defaultDeliveredDelay
+	}
+	jitter := int(maxStatusJitter / time.Millisecond)
+	sent = time.Duration(simrand.Intn(jitter)) * time.Millisecond
+	delivered = time.Duration(simrand.Intn(jitter)) * time.Millisecond
+	return sent, delivered
+}
+
+// firstEventDelay returns how long SendStatusCallbacks waits before a message's first event. A
+// per-message delay replaces the default timing entirely.
+func firstEventDelay(msg MessageDetails) time.Duration {
+	if msg.Delay != nil {
+		return 0
+	}
+	return time.Duration(database.GetIntSetting("webhook_initial_delay_ms", database.DefaultWebhookInitialDelayMs)) * time.Millisecond
+}
+
+// eventDelays returns the waits before a message's sent and delivered events
+func eventDelays(msg MessageDetails, outOfOrder bool) (sent, delivered time.Duration) {
+	sent, delivered = statusDelays(outOfOrder)
+	if msg.Delay != nil {
+		return *msg.Delay, *msg.Delay
+	}
+	return sent, delivered
+}
+
+// FailureReason describes why a message failed, reported in the message.failed payload's errors array
+type FailureReason struct {
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// ExpiredReason is reported when a message was not delivered before its valid_until
+var ExpiredReason = FailureReason{
+	Code:   "40008",
+	Title:  "Message expired",
+	Detail: "[SmsSink] The message was not delivered before its valid_until time.",
+}
+
+// CarrierRejectedReason is reported when the carrier rejects a message before it is sent
+var CarrierRejectedReason = FailureReason{
+	Code:   "30006",
+	Title:  "Carrier rejected",
+	Detail: "[SmsSink] The message was rejected by the carrier before it was sent.",
+}
+
+// DeliveryFailedReason is reported when a message is failed by hand after being accepted
+var DeliveryFailedReason = FailureReason{
+	Code:   "40006",
+	Title:  "Recipient server unavailable",
+	Detail: "[SmsSink] The recipient's carrier did not accept the message.",
+}
+
+// buildBasePayload builds the message object shared by every status webhook for a message
+func buildBasePayload(msg MessageDetails) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   msg.ID,
+		"record_type":          "message",
+		"direction":            "outbound",
+		"messaging_profile_id": msg.MessagingProfileID,
+		"from": map[string]interface{}{
+			"phone_number": msg.From,
+			"carrier":      sms.MockCarrier,
+			"line_type":    sms.LineType(msg.From),
+		},
+		"to":    buildRecipients(msg),
+		"text":  msg.Text,
+		"media": msg.MediaURLs,
+		"type":  msg.Type,
+		"parts": msg.Parts,
+	}
+}
+
+// buildRecipients lists each of the message's recipients for the payload's to array
+func buildRecipients(msg MessageDetails) []map[string]interface{} {
+	recipients := msg.Recipients
+	if len(recipients) == 0 {
+		recipients = []string{msg.To}
+	}
+
+	to := make([]map[string]interface{}, 0, len(recipients))
+	for _, number := range recipients {
+		to = append(to, map[string]interface{}{
+			"phone_number": number,
+			"carrier":      sms.MockCarrier,
+			"line_type":    sms.LineType(number),
+		})
+	}
+	return to
+}
+
+// recipientFailed reports whether the carrier rejected one participant of a group message
+func (m MessageDetails) recipientFailed(number string) bool {
+	for _, failed := range m.FailedRecipients {
+		if failed == number {
+			return true
+		}
+	}
+	return false
+}
+
+// failRecipients stores the failed status of the group participants the carrier rejected
+func failRecipients(msg MessageDetails) {
+	if len(msg.FailedRecipients) == 0 {
+		return
+	}
+	if err := database.FailMessageRecipients(msg.ID, msg.FailedRecipients); err != nil {
+		log.Printf("Webhook: Failed to update message recipients: %v", err)
+	}
+}
+
+// SendFailureCallback asynchronously sends a message.failed webhook reporting the message's
+// final status (e.g. "expired") and the reason it failed
+func SendFailureCallback(msg MessageDetails, status string, reason FailureReason) {
+	if !msg.hasWebhooks() {
+		return
+	}
+
+	inFlight.Add(1)
+	go func() {
+		defer inFlight.Add(-1)
+		sendWebhook(msg, buildFailedPayload(msg, status, reason))
 	}()
 }
 
-// sendWebhook sends a webhook to the specified URL
-func sendWebhook(url, failoverURL string, payload TelnyxWebhookPayload) {
-	body, err := json.Marshal(payload)
+// buildFailedPayload builds a message.failed webhook carrying the final status and failure reason
+func buildFailedPayload(msg MessageDetails, status string, reason FailureReason) TelnyxWebhookPayload {
+	occurredAt := time.Now().UTC().Format(time.RFC3339)
+	payload := buildBasePayload(msg)
+	payload["status"] = status
+	payload["errors"] = []FailureReason{reason}
+	// A failure is terminal, so like delivery it completes the message
+	payload["completed_at"] = occurredAt
+	if toArr, ok := payload["to"].([]map[string]interface{}); ok {
+		for _, recipient := range toArr {
+			recipient["status"] = status
+			if number, _ := recipient["phone_number"].(string); msg.recipientFailed(number) {
+				recipient["status"] = "failed"
+			}
+		}
+	}
+
+	return TelnyxWebhookPayload{
+		Data: TelnyxWebhookData{
+			EventType:  "message.failed",
+			ID:         IDGenerator(),
+			OccurredAt: occurredAt,
+			Payload:    payload,
+			RecordType: "event",
+		},
+	}
+}
+
+// hasWebhooks reports whether the message has anywhere to send its webhooks
+func (msg MessageDetails) hasWebhooks() bool {
+	return msg.WebhookURL != "" || len(msg.WebhookURLs) > 0
+}
+
+// sendWebhook sends a webhook for the message, with the global custom headers plus any per-message
+// headers. Fan-out URLs are delivered to concurrently, each tracked on its own, and all are
+// finished before it returns so every consumer still sees the message's events in order.
+func sendWebhook(msg MessageDetails, payload TelnyxWebhookPayload) {
+	// Consumers subscribed to a subset of events never see the others
+	if !database.WebhookEventEnabled(payload.Data.EventType) {
+		return
+	}
+
+	body, err := EncodePayload(payload)
 	if err != nil {
 		log.Printf("Webhook: Failed to marshal payload: %v", err)
 		database.LogError("webhook", "Failed to marshal webhook payload", map[string]interface{}{
@@ -131,75 +515,290 @@ func sendWebhook(url, failoverURL string, payload TelnyxWebhookPayload) {
 
 	messageID, _ := payload.Data.Payload["id"].(string)
 
+	// Keep a copy of exactly what's delivered for offline inspection
+	if dir := database.GetWebhookDumpDir(); dir != "" {
+		dumpPayload(dir, messageID, payload.Data.EventType, body)
+	}
+
+	headers := database.GetWebhookCustomHeaders()
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+
+	if len(msg.WebhookURLs) == 0 {
+		deliver(msg.WebhookURL, msg.WebhookFailoverURL, body, headers, payload.Data.EventType, messageID)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, url := range msg.WebhookURLs {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			deliver(url, "", body, headers, payload.Data.EventType, messageID)
+		}(url)
+	}
+	wg.Wait()
+}
+
+// deliver posts an encoded webhook to url, falling back to failoverURL (if any) when it fails
+func deliver(url, failoverURL string, body []byte, headers map[string]string, eventType, messageID string) {
 	// Try primary URL
-	if err := doWebhookRequest(url, body); err != nil {
+	timing := newRequestTiming()
+	statusCode, err := doWebhookRequest(url, body, headers, timing)
+	publishDelivery(url, eventType, messageID, 1, statusCode, err)
+	if err != nil {
 		log.Printf("Webhook: Primary URL failed (%s): %v", url, err)
-		database.LogWarning("webhook", "Primary webhook URL failed", map[string]interface{}{
+		database.LogWarning("webhook", "Primary webhook URL failed", withTiming(map[string]interface{}{
 			"url":        url,
 			"error":      err.Error(),
-			"event_type": payload.Data.EventType,
+			"event_type": eventType,
 			"message_id": messageID,
-		})
+		}, timing))
 
 		// Try failover URL if available
 		if failoverURL != "" {
-			if err := doWebhookRequest(failoverURL, body); err != nil {
+			timing := newRequestTiming()
+			statusCode, err := doWebhookRequest(failoverURL, body, headers, timing)
+			publishDelivery(failoverURL, eventType, messageID, 2, statusCode, err)
+			if err != nil {
 				log.Printf("Webhook: Failover URL also failed (%s): %v", failoverURL, err)
-				database.LogError("webhook", "Failover webhook URL also failed", map[string]interface{}{
+				database.LogError("webhook", "Failover webhook URL also failed", withTiming(map[string]interface{}{
 					"url":        failoverURL,
 					"error":      err.Error(),
-					"event_type": payload.Data.EventType,
+					"event_type": eventType,
 					"message_id": messageID,
-				})
+				}, timing))
 			} else {
-				log.Printf("Webhook: Sent to failover URL: %s (event: %s)", failoverURL, payload.Data.EventType)
-				database.Log("webhook", "Webhook sent to failover URL", map[string]interface{}{
+				log.Printf("Webhook: Sent to failover URL: %s (event: %s)", failoverURL, eventType)
+				database.Log("webhook", "Webhook sent to failover URL", withTiming(map[string]interface{}{
 					"url":        failoverURL,
-					"event_type": payload.Data.EventType,
+					"event_type": eventType,
 					"message_id": messageID,
-				})
+				}, timing))
+				duplicateDelivery(failoverURL, body, headers, eventType, messageID, 3)
 			}
 		}
 	} else {
-		log.Printf("Webhook: Sent to %s (event: %s, message: %s)", url, payload.Data.EventType, payload.Data.Payload["id"])
-		database.Log("webhook", "Webhook sent successfully", map[string]interface{}{
+		log.Printf("Webhook: Sent to %s (event: %s, message: %s)", url, eventType, messageID)
+		database.Log("webhook", "Webhook sent successfully", withTiming(map[string]interface{}{
 			"url":        url,
-			"event_type": payload.Data.EventType,
+			"event_type": eventType,
 			"message_id": messageID,
-		})
+		}, timing))
+		duplicateDelivery(url, body, headers, eventType, messageID, 2)
+	}
+}
+
+// duplicateDelivery re-sends a successfully delivered webhook, unchanged and so with the same event
+// ID, to a webhook_duplicate_rate fraction of deliveries, simulating at-least-once delivery so
+// consumers can test their deduplication. The draw uses simrand so random_seed reproduces it.
+// The duplicate is recorded as attempt, the one after the delivery it repeats.
+func duplicateDelivery(url string, body []byte, headers map[string]string, eventType, messageID string, attempt int) {
+	rate := database.GetFloatSetting("webhook_duplicate_rate", 0)
+	if rate <= 0 || simrand.Float64() >= rate {
+		return
+	}
+
+	timing := newRequestTiming()
+	statusCode, err := doWebhookRequest(url, body, headers, timing)
+	publishDelivery(url, eventType, messageID, attempt, statusCode, err)
+	details := withTiming(map[string]interface{}{
+		"url":        url,
+		"event_type": eventType,
+		"message_id": messageID,
+	}, timing)
+	if err != nil {
+		details["error"] = err.Error()
+		database.LogWarning("webhook", "Duplicate webhook delivery failed", details)
+		return
+	}
+	log.Printf("Webhook: Sent duplicate to %s (event: %s, message: %s)", url, eventType, messageID)
+	database.Log("webhook", "Duplicate webhook sent", details)
+}
+
+// EncodePayload encodes a webhook exactly as it's delivered, with the configured webhook_field_map
+// and webhook_version applied
+func EncodePayload(payload TelnyxWebhookPayload) ([]byte, error) {
+	return encodePayload(renameFields(payload, database.GetWebhookFieldMap()), database.GetWebhookVersion())
+}
+
+// encodePayload serializes a webhook in the requested format: "v2" (the default, nested under
+// data.payload) or the legacy "v1" shape with the message fields flattened to the top level
+func encodePayload(payload TelnyxWebhookPayload, version string) ([]byte, error) {
+	if version != "v1" {
+		return json.Marshal(payload)
 	}
+
+	legacy := copyMap(payload.Data.Payload)
+	legacy["event_type"] = payload.Data.EventType
+	legacy["event_id"] = payload.Data.ID
+	legacy["occurred_at"] = payload.Data.OccurredAt
+	return json.Marshal(legacy)
+}
+
+// payloadFields lists every top-level key a webhook payload can carry
+var payloadFields = []string{
+	"id", "record_type", "direction", "messaging_profile_id", "from", "to", "text", "media", "type",
+	"parts", "status", "sent_at", "completed_at", "cost", "errors", "received_at", "read_at",
 }
 
-// doWebhookRequest performs the actual HTTP request
-func doWebhookRequest(url string, body []byte) error {
+// envelopeFields are the keys v1 payloads add next to the payload fields after renaming
+var envelopeFields = []string{"event_type", "event_id", "occurred_at"}
+
+// ValidateFieldMap checks a webhook_field_map setting: names must be non-empty, no two fields may
+// be renamed to the same name, and a field may only take the name of a standard field that is
+// itself renamed away
+func ValidateFieldMap(fieldMap map[string]string) error {
+	targets := map[string]string{}
+	for from, to := range fieldMap {
+		if from == "" || to == "" {
+			return errors.New("field names must not be empty")
+		}
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("'%s' and '%s' are both renamed to '%s'", other, from, to)
+		}
+		targets[to] = from
+
+		if slices.Contains(envelopeFields, to) {
+			return fmt.Errorf("'%s' cannot be renamed to the reserved field '%s'", from, to)
+		}
+		if _, renamedAway := fieldMap[to]; slices.Contains(payloadFields, to) && !renamedAway {
+			return fmt.Errorf("'%s' cannot be renamed to the existing field '%s'", from, to)
+		}
+	}
+	return nil
+}
+
+// renameFields returns the payload with its top-level payload keys renamed per fieldMap (old name
+// to new name). Renames apply simultaneously, so fields can be swapped. The original is untouched.
+func renameFields(payload TelnyxWebhookPayload, fieldMap map[string]string) TelnyxWebhookPayload {
+	if len(fieldMap) == 0 {
+		return payload
+	}
+
+	renamed := make(map[string]interface{}, len(payload.Data.Payload))
+	for key, value := range payload.Data.Payload {
+		if newKey, ok := fieldMap[key]; ok {
+			key = newKey
+		}
+		renamed[key] = value
+	}
+	payload.Data.Payload = renamed
+	return payload
+}
+
+// publishDelivery broadcasts the outcome of a delivery attempt to live subscribers and records it
+// for the delivery stats
+func publishDelivery(url, eventType, messageID string, attempt, statusCode int, err error) {
+	event := DeliveryEvent{
+		URL:        url,
+		EventType:  eventType,
+		MessageID:  messageID,
+		StatusCode: statusCode,
+		Attempt:    attempt,
+		Success:    err == nil,
+		OccurredAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	Deliveries.Publish(event)
+
+	recordErr := database.RecordWebhookDelivery(database.WebhookDelivery{
+		URL:        url,
+		EventType:  eventType,
+		MessageID:  messageID,
+		Attempt:    attempt,
+		StatusCode: statusCode,
+		Success:    event.Success,
+		Error:      event.Error,
+	})
+	if recordErr != nil {
+		log.Printf("Webhook: Failed to record delivery: %v", recordErr)
+	}
+}
+
+// newRequestTiming returns a timing recorder when debug mode is on, or nil to skip tracing
+func newRequestTiming() *requestTiming {
+	if !database.DebugEnabled() {
+		return nil
+	}
+	return &requestTiming{}
+}
+
+// doWebhookRequest performs the actual HTTP request, returning the response status code
+// (0 if no response was received). Custom headers are applied first so they can't override the
+// standard Telnyx headers. If timing is non-nil, connection phases are traced into it.
+func doWebhookRequest(url string, body []byte, headers map[string]string, timing *requestTiming) (int, error) {
 	client := &http.Client{
 		Timeout: 5 * time.Second,
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	req, err := http.NewRequest(database.GetWebhookHTTPMethod(), url, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "SmsSink/1.0")
 
-	// Telnyx includes these headers - we'll add placeholders
-	req.Header.Set("telnyx-timestamp", time.Now().UTC().Format(time.RFC3339))
-	req.Header.Set("telnyx-signature-ed25519", "mock-signature")
+	// Telnyx signs "timestamp|body" with ed25519; consumers can check it against /api/webhook-key
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	signature, err := SignPayload(timestamp, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("telnyx-timestamp", timestamp)
+	req.Header.Set("telnyx-signature-ed25519", signature)
+
+	if timing != nil {
+		req = timing.withClientTrace(req)
+		defer timing.finish()
+	}
+
+	// Simulate a dropped connection on a share of attempts: the request is aborted before any
+	// response is read, so consumers see a network failure rather than an HTTP error. The random
+	// draw only happens when enabled, so seeded runs without aborts are unaffected.
+	if percent := database.GetIntSetting("webhook_abort_percent", 0); percent > 0 && simrand.Intn(100) < percent {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancel()
+		req = req.WithContext(ctx)
+	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return 0, &ConnectionError{Err: err}
 	}
 	defer resp.Body.Close()
+	warnIfSlow(url, resp.StatusCode, time.Since(start))
 
 	// Telnyx expects 2xx response
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return &WebhookError{StatusCode: resp.StatusCode}
+		return resp.StatusCode, &WebhookError{StatusCode: resp.StatusCode}
 	}
 
-	return nil
+	return resp.StatusCode, nil
+}
+
+// warnIfSlow logs a warning when a consumer took longer to respond than the
+// slow_consumer_threshold_ms setting allows (0 = off)
+func warnIfSlow(url string, statusCode int, elapsed time.Duration) {
+	threshold := time.Duration(database.GetIntSetting("slow_consumer_threshold_ms", 0)) * time.Millisecond
+	if threshold <= 0 || elapsed <= threshold {
+		return
+	}
+	database.LogWarning("webhook", "Slow webhook consumer", map[string]interface{}{
+		"url":          url,
+		"status_code":  statusCode,
+		"duration_ms":  elapsed.Milliseconds(),
+		"threshold_ms": threshold.Milliseconds(),
+	})
 }
 
 // WebhookError represents a webhook delivery failure
@@ -211,6 +810,20 @@ func (e *WebhookError) Error() string {
 	return "webhook returned non-2xx status"
 }
 
+// ConnectionError is a delivery failure where no HTTP response was received (refused, reset,
+// timed out or aborted)
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return "connection error: " + e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
 // copyMap creates a shallow copy of a map
 func copyMap(m map[string]interface{}) map[string]interface{} {
 	result := make(map[string]interface{})
@@ -1,12 +1,19 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"sort"
 	"sync"
 	"testing"
 	"time"
+
+	"telnyx-mock/internal/simrand"
 )
 
 func TestSendStatusCallbacks_NoWebhookURL(t *testing.T) {
@@ -227,3 +234,362 @@ func TestWebhookPayloadStructure(t *testing.T) {
 		}
 	}
 }
+
+func TestSendStatusCallbacks_PublishesDeliveryEvents(t *testing.T) {
+	deliveries, unsubscribe := Deliveries.Subscribe()
+	defer unsubscribe()
+
+	// Primary fails so both attempts are published
+	primaryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primaryServer.Close()
+
+	failoverServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer failoverServer.Close()
+
+	SendStatusCallbacks(MessageDetails{
+		ID:                 "msg-events-1",
+		From:               "+1234567890",
+		To:                 "+0987654321",
+		Text:               "Test message",
+		MessagingProfileID: "profile-123",
+		Type:               "SMS",
+		WebhookURL:         primaryServer.URL,
+		WebhookFailoverURL: failoverServer.URL,
+	})
+
+	var got []DeliveryEvent
+	timeout := time.After(3 * time.Second)
+	for len(got) < 2 {
+		select {
+		case event := <-deliveries:
+			if event.MessageID == "msg-events-1" {
+				got = append(got, event)
+			}
+		case <-timeout:
+			t.Fatalf("Timeout waiting for delivery events, got %d", len(got))
+		}
+	}
+
+	if got[0].Attempt != 1 || got[0].Success || got[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected primary attempt event: %+v", got[0])
+	}
+	if got[1].Attempt != 2 || !got[1].Success || got[1].StatusCode != http.StatusOK || got[1].URL != failoverServer.URL {
+		t.Errorf("Unexpected failover attempt event: %+v", got[1])
+	}
+	if got[0].EventType != "message.sent" {
+		t.Errorf("Expected event_type 'message.sent', got '%s'", got[0].EventType)
+	}
+}
+
+func TestSendStatusCallbacks_CustomHeaders(t *testing.T) {
+	headers := make(chan http.Header, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	SendStatusCallbacks(MessageDetails{
+		ID:         "test-headers",
+		From:       "+1234567890",
+		To:         "+0987654321",
+		Text:       "Test message",
+		Type:       "SMS",
+		WebhookURL: server.URL,
+		Headers: map[string]string{
+			"X-Tenant-Id":  "acme",
+			"Content-Type": "text/plain", // Can't override the standard headers
+		},
+	})
+
+	select {
+	case h := <-headers:
+		if h.Get("X-Tenant-Id") != "acme" {
+			t.Errorf("Expected X-Tenant-Id 'acme', got '%s'", h.Get("X-Tenant-Id"))
+		}
+		if h.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected Content-Type to stay 'application/json', got '%s'", h.Get("Content-Type"))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for webhook")
+	}
+}
+
+func TestSendStatusCallbacks_CompletedAtOnFailure(t *testing.T) {
+	received := make(chan TelnyxWebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	noDelay := time.Duration(0)
+	reason := CarrierRejectedReason
+	SendStatusCallbacks(MessageDetails{
+		ID:           "test-failed",
+		From:         "+1234567890",
+		To:           "+0987654321",
+		Text:         "Test message",
+		Type:         "SMS",
+		WebhookURL:   server.URL,
+		RejectReason: &reason,
+		Delay:        &noDelay,
+	})
+
+	select {
+	case payload := <-received:
+		if payload.Data.EventType != "message.failed" {
+			t.Fatalf("Expected message.failed, got %s", payload.Data.EventType)
+		}
+		if payload.Data.Payload["completed_at"] != payload.Data.OccurredAt {
+			t.Errorf("Expected completed_at %s on the failed event, got %v", payload.Data.OccurredAt, payload.Data.Payload["completed_at"])
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for message.failed")
+	}
+
+	// Until a terminal status, completed_at is present but null
+	sent := buildStatusPayload(MessageDetails{ID: "test-sent"}, "message.sent", "sent", time.Now(), time.Now())
+	if value, ok := sent.Data.Payload["completed_at"]; !ok || value != nil {
+		t.Errorf("Expected a null completed_at on message.sent, got %v (present: %v)", value, ok)
+	}
+}
+
+func TestEncodePayload_V1LegacyShape(t *testing.T) {
+	payload := buildFailedPayload(MessageDetails{
+		ID:   "test-v1",
+		From: "+1234567890",
+		To:   "+0987654321",
+		Text: "Test message",
+		Type: "SMS",
+	}, "failed", CarrierRejectedReason)
+
+	body, err := encodePayload(payload, "v1")
+	if err != nil {
+		t.Fatalf("Failed to encode payload: %v", err)
+	}
+
+	var legacy map[string]interface{}
+	json.Unmarshal(body, &legacy)
+
+	if _, ok := legacy["data"]; ok {
+		t.Error("Expected no nested 'data' object in v1 payload")
+	}
+	if legacy["event_type"] != "message.failed" {
+		t.Errorf("Expected event_type 'message.failed', got '%v'", legacy["event_type"])
+	}
+	if legacy["id"] != "test-v1" {
+		t.Errorf("Expected flat message id 'test-v1', got '%v'", legacy["id"])
+	}
+	if legacy["status"] != "failed" || legacy["text"] != "Test message" {
+		t.Errorf("Expected flat message fields, got %v", legacy)
+	}
+	if legacy["event_id"] != payload.Data.ID || legacy["occurred_at"] != payload.Data.OccurredAt {
+		t.Errorf("Expected event metadata alongside message fields, got %v", legacy)
+	}
+
+	// v2 stays nested
+	body, _ = encodePayload(payload, "v2")
+	var nested TelnyxWebhookPayload
+	json.Unmarshal(body, &nested)
+	if nested.Data.Payload["id"] != "test-v1" {
+		t.Errorf("Expected v2 payload nested under data.payload, got %s", body)
+	}
+}
+
+// eventOrder returns the order status webhooks fire in for messages sent at the same instant
+func eventOrder(outOfOrder bool, messages ...string) []string {
+	type event struct {
+		name string
+		at   time.Duration
+	}
+	timeline := []event{}
+	for _, id := range messages {
+		sent, delivered := statusDelays(outOfOrder)
+		timeline = append(timeline, event{id + ".sent", sent}, event{id + ".delivered", sent + delivered})
+	}
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].at < timeline[j].at })
+
+	order := []string{}
+	for _, e := range timeline {
+		order = append(order, e.name)
+	}
+	return order
+}
+
+func TestStatusDelays_OutOfOrder(t *testing.T) {
+	strict := eventOrder(false, "A", "B")
+	if want := []string{"A.sent", "B.sent", "A.delivered", "B.delivered"}; !slices.Equal(strict, want) {
+		t.Errorf("Expected strict order %v, got %v", want, strict)
+	}
+
+	// With a fixed seed, A is delivered before B is even sent
+	simrand.Seed(7)
+	scrambled := eventOrder(true, "A", "B")
+	if want := []string{"A.sent", "A.delivered", "B.sent", "B.delivered"}; !slices.Equal(scrambled, want) {
+		t.Errorf("Expected scrambled order %v, got %v", want, scrambled)
+	}
+}
+
+func TestRenameFields_Swap(t *testing.T) {
+	payload := TelnyxWebhookPayload{Data: TelnyxWebhookData{Payload: map[string]interface{}{"id": "a", "text": "b", "type": "SMS"}}}
+
+	renamed := renameFields(payload, map[string]string{"id": "text", "text": "id"})
+	if renamed.Data.Payload["id"] != "b" || renamed.Data.Payload["text"] != "a" || renamed.Data.Payload["type"] != "SMS" {
+		t.Errorf("Expected id and text swapped, got %v", renamed.Data.Payload)
+	}
+	if payload.Data.Payload["id"] != "a" {
+		t.Error("Expected the original payload to be untouched")
+	}
+
+	if err := ValidateFieldMap(map[string]string{"id": "text", "text": "id"}); err != nil {
+		t.Errorf("Expected a swap to be valid, got %v", err)
+	}
+}
+
+func TestValidateFieldMap_InboundFields(t *testing.T) {
+	for _, field := range []string{"received_at", "read_at"} {
+		if err := ValidateFieldMap(map[string]string{"text": field}); err == nil {
+			t.Errorf("Expected renaming onto '%s' to be rejected", field)
+		}
+	}
+}
+
+func TestSendStatusCallbacks_OrderedWithZeroDelay(t *testing.T) {
+	var mu sync.Mutex
+	events := map[string][]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload TelnyxWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		// A slow sent handler gives a racing delivered event every chance to overtake it
+		if payload.Data.EventType == "message.sent" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		id, _ := payload.Data.Payload["id"].(string)
+		mu.Lock()
+		events[id] = append(events[id], payload.Data.EventType)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	zero := time.Duration(0)
+	const messages = 10
+	for i := 0; i < messages; i++ {
+		SendStatusCallbacks(MessageDetails{
+			ID:         "ordered-" + string(rune('a'+i)),
+			From:       "+1234567890",
+			To:         "+0987654321",
+			Text:       "Test message",
+			Type:       "SMS",
+			WebhookURL: server.URL,
+			Delay:      &zero,
+		})
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != messages {
+		t.Fatalf("Expected events for %d messages, got %d", messages, len(events))
+	}
+	for id, got := range events {
+		if !slices.Equal(got, []string{"message.sent", "message.delivered"}) {
+			t.Errorf("Expected sent before delivered for %s, got %v", id, got)
+		}
+	}
+}
+
+func TestIDGenerator_Override(t *testing.T) {
+	defer func(original func() string) { IDGenerator = original }(IDGenerator)
+
+	next := 0
+	IDGenerator = func() string {
+		next++
+		return fmt.Sprintf("event-%d", next)
+	}
+
+	msg := MessageDetails{ID: "msg-1", From: "+1234567890", To: "+0987654321", Type: "SMS", Parts: 1}
+	now := time.Now().UTC()
+	ids := []string{
+		buildStatusPayload(msg, "message.sent", "sent", now, now).Data.ID,
+		buildStatusPayload(msg, "message.delivered", "delivered", now, now).Data.ID,
+		buildFailedPayload(msg, "failed", DeliveryFailedReason).Data.ID,
+	}
+	if !slices.Equal(ids, []string{"event-1", "event-2", "event-3"}) {
+		t.Errorf("Expected predictable event IDs, got %v", ids)
+	}
+}
+
+func TestDrain_HonorsTimeout(t *testing.T) {
+	// A delivery that never finishes holds shutdown only until the timeout
+	inFlight.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := Drain(ctx)
+	elapsed := time.Since(start)
+	inFlight.Add(-1)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected Drain to give up after about 100ms, took %s", elapsed)
+	}
+
+	// Once deliveries finish, Drain returns without waiting out the timeout
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := Drain(ctx); err != nil {
+		t.Errorf("Expected Drain to finish once idle, got %v", err)
+	}
+}
+
+func TestHoldForHighPriority(t *testing.T) {
+	cancelled := make(chan struct{})
+
+	// Nothing is held without a high-priority message in flight
+	if held, ok := holdForHighPriority(cancelled); !ok || held > 10*time.Millisecond {
+		t.Fatalf("Expected no hold, got %v (%v)", held, ok)
+	}
+
+	enterHighPriority()
+	released := make(chan bool, 1)
+	go func() {
+		_, ok := holdForHighPriority(cancelled)
+		released <- ok
+	}()
+
+	select {
+	case <-released:
+		t.Fatal("Expected the normal event to be held while a high-priority message is pending")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	leaveHighPriority()
+	select {
+	case ok := <-released:
+		if !ok {
+			t.Error("Expected the hold to end with the release, not a cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the normal event to be released once no high-priority message is pending")
+	}
+
+	// A cancel ends a hold early
+	enterHighPriority()
+	defer leaveHighPriority()
+	close(cancelled)
+	if _, ok := holdForHighPriority(cancelled); ok {
+		t.Error("Expected a cancelled hold to report false")
+	}
+}
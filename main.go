@@ -3,11 +3,11 @@ package main
 import (
 	"context"
 	"embed"
-	"encoding/json"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -15,6 +15,8 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"telnyx-mock/internal/database"
 	"telnyx-mock/internal/server"
+	"telnyx-mock/internal/simrand"
+	"telnyx-mock/internal/webhook"
 )
 
 // Version is the current version of SmsSink
@@ -33,25 +35,52 @@ func main() {
 
 	log.Println("Database initialized successfully")
 
+	// Reseed simulations for reproducible runs when a random_seed is configured
+	if seed, err := database.GetSetting("random_seed"); err == nil && seed != "" {
+		if value, err := strconv.ParseInt(seed, 10, 64); err == nil {
+			simrand.Seed(value)
+		}
+	}
+
+	// Expire undelivered messages past valid_until (runs once now, then periodically)
+	stopExpirySweeper := server.StartExpirySweeper(server.ExpirySweepInterval)
+	defer stopExpirySweeper()
+
+	// Keep the log table bounded by age and by the max_logs setting
+	stopLogPruner := server.StartLogPruner(server.LogPruneInterval)
+	defer stopLogPruner()
+
 	// Setup API server (port 23456)
 	apiRouter := chi.NewRouter()
 	apiRouter.Use(middleware.Logger)
 	apiRouter.Use(middleware.Recoverer)
+	apiRouter.Use(server.RawRequestCaptureMiddleware)
+	apiRouter.Use(server.RequestCounterMiddleware)
+	apiRouter.Use(server.LatencyMiddleware)
+	apiRouter.Use(server.PrettyJSONMiddleware)
 	// Support both /v2/... and /... routes for SDK compatibility
-	apiRouter.Post("/v2/messages", server.HandleCreateMessage)
-	apiRouter.Post("/messages", server.HandleCreateMessage)
-	apiRouter.Post("/v2/webhooks/messages", server.HandleInboundWebhook)
-	apiRouter.Post("/webhooks/messages", server.HandleInboundWebhook)
+	apiRouter.With(server.RateLimitMiddleware).Post("/v2/messages", server.HandleCreateMessage)
+	apiRouter.With(server.RateLimitMiddleware).Post("/messages", server.HandleCreateMessage)
+	apiRouter.Get("/v2/messages/{id}", server.HandleRetrieveMessage)
+	apiRouter.Get("/messages/{id}", server.HandleRetrieveMessage)
+	apiRouter.With(server.InboundRateLimitMiddleware, server.InboundConcurrencyMiddleware).Post("/v2/webhooks/messages", server.HandleInboundWebhook)
+	apiRouter.With(server.InboundRateLimitMiddleware, server.InboundConcurrencyMiddleware).Post("/webhooks/messages", server.HandleInboundWebhook)
+
+	server.SetReplayHandler(apiRouter)
 
 	apiServer := &http.Server{
-		Addr:    ":23456",
-		Handler: apiRouter,
+		Handler:      apiRouter,
+		ReadTimeout:  envDuration("SMSSINK_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout: envDuration("SMSSINK_WRITE_TIMEOUT", 15*time.Second),
+		IdleTimeout:  envDuration("SMSSINK_IDLE_TIMEOUT", 60*time.Second),
 	}
+	server.SetAPIWriteTimeout(apiServer.WriteTimeout)
 
 	// Setup UI server (port 23457)
 	uiRouter := chi.NewRouter()
 	uiRouter.Use(middleware.Logger)
 	uiRouter.Use(middleware.Recoverer)
+	uiRouter.Use(server.PrettyJSONMiddleware)
 
 	// Serve the embedded HTML
 	uiRouter.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -153,42 +182,107 @@ func main() {
 	// API endpoints for UI
 	uiRouter.Get("/api/messages", server.HandleListMessages)
 	uiRouter.Delete("/api/messages", server.HandleClearMessages)
+	uiRouter.Get("/api/messages/{id}", server.HandleGetMessage)
+	uiRouter.Get("/api/messages/{id}/delivered-payload", server.HandleGetDeliveredPayload)
+	uiRouter.Post("/api/messages/{id}/status", server.HandleSetMessageStatus)
+	uiRouter.Get("/api/messages/{id}/timeline", server.HandleGetMessageTimeline)
+	uiRouter.Delete("/api/messages/{id}", server.HandleDeleteMessage)
 	uiRouter.Post("/api/messages/inbound", server.HandleSimulateInbound)
+	uiRouter.Post("/api/simulate/error", server.HandleSimulateError)
 	uiRouter.Get("/api/credentials", server.HandleGetCredentials)
 	uiRouter.Post("/api/credentials", server.HandleSetCredentials)
 	uiRouter.Get("/api/logs", server.HandleGetLogs)
+	uiRouter.Get("/api/logs/export", server.HandleExportLogs)
 	uiRouter.Delete("/api/logs", server.HandleClearLogs)
 	uiRouter.Get("/api/settings", server.HandleGetSettings)
 	uiRouter.Post("/api/settings", server.HandleSetSettings)
-	uiRouter.Get("/api/version", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"version": Version})
-	})
+	uiRouter.Get("/api/settings/profiles", server.HandleListSettingsProfiles)
+	uiRouter.Post("/api/settings/profiles", server.HandleSaveSettingsProfile)
+	uiRouter.Post("/api/settings/profiles/{name}/apply", server.HandleApplySettingsProfile)
+	uiRouter.Delete("/api/settings/profiles/{name}", server.HandleDeleteSettingsProfile)
+	uiRouter.Delete("/api/reset", server.HandleReset)
+	uiRouter.Get("/api/stats", server.HandleGetStats)
+	uiRouter.Get("/api/stats/requests", server.HandleRequestStats)
+	uiRouter.Get("/api/stats/cost", server.HandleGetCostSummary)
+	uiRouter.Get("/api/stats/webhooks", server.HandleGetWebhookStats)
+	uiRouter.Post("/api/maintenance/vacuum", server.HandleVacuum)
+	uiRouter.Post("/api/benchmark/generate", server.HandleGenerateLoad)
+	uiRouter.Get("/api/debug/health", server.HandleDebugHealth)
+	uiRouter.Post("/api/simulator/burst", server.HandleStartBurst)
+	uiRouter.Get("/api/simulator/burst/{id}", server.HandleGetBurst)
+	uiRouter.Get("/api/requests/raw", server.HandleListRawRequests)
+	uiRouter.Post("/api/requests/raw/{index}/replay", server.HandleReplayRawRequest)
+	uiRouter.Get("/api/idempotency", server.HandleListIdempotency)
+	uiRouter.Delete("/api/idempotency", server.HandleClearIdempotency)
+	uiRouter.Get("/api/auto-replies", server.HandleListAutoReplies)
+	uiRouter.Post("/api/auto-replies", server.HandleSetAutoReply)
+	uiRouter.Delete("/api/auto-replies/{keyword}", server.HandleDeleteAutoReply)
+	uiRouter.Get("/api/opt-outs", server.HandleListOptOuts)
+	uiRouter.Delete("/api/opt-outs/{phone_number}", server.HandleDeleteOptOut)
+	uiRouter.Get("/api/blocked-numbers", server.HandleListBlockedNumbers)
+	uiRouter.Post("/api/blocked-numbers", server.HandleAddBlockedNumber)
+	uiRouter.Delete("/api/blocked-numbers/{phone_number}", server.HandleDeleteBlockedNumber)
+	uiRouter.Get("/api/profiles", server.HandleListProfiles)
+	uiRouter.Post("/api/profiles", server.HandleSaveProfile)
+	uiRouter.Get("/api/profiles/{id}", server.HandleGetProfile)
+	uiRouter.Delete("/api/profiles/{id}", server.HandleDeleteProfile)
+	uiRouter.Get("/api/profiles/{id}/numbers", server.HandleListPoolNumbers)
+	uiRouter.Post("/api/profiles/{id}/numbers", server.HandleAddPoolNumber)
+	uiRouter.Delete("/api/profiles/{id}/numbers/{phone_number}", server.HandleDeletePoolNumber)
+	uiRouter.Get("/api/webhooks/events", server.HandleWebhookEvents)
+	uiRouter.Get("/api/webhook-key", server.HandleGetWebhookKey)
+	uiRouter.Post("/api/webhook-key/rotate", server.HandleRotateWebhookKey)
+	uiRouter.Post("/api/webhook-key/verify", server.HandleVerifySignature)
+	server.SetVersion(Version)
+	uiRouter.Get("/api/version", server.HandleVersion)
 
+	// The UI server streams Server-Sent Events (/api/webhooks/events), so its write timeout
+	// is off by default; a non-zero value cuts those streams off after that long
 	uiServer := &http.Server{
-		Addr:    ":23457",
-		Handler: uiRouter,
+		Handler:      uiRouter,
+		ReadTimeout:  envDuration("SMSSINK_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout: envDuration("SMSSINK_UI_WRITE_TIMEOUT", 0),
+		IdleTimeout:  envDuration("SMSSINK_IDLE_TIMEOUT", 60*time.Second),
+	}
+
+	// Bind both ports before serving, so ":0" addresses resolve to the ports the OS assigned
+	apiListener, apiPort, err := server.Listen(envString("SMSSINK_API_ADDR", ":23456"))
+	if err != nil {
+		log.Fatalf("API server failed: %v", err)
+	}
+	uiListener, uiPort, err := server.Listen(envString("SMSSINK_UI_ADDR", ":23457"))
+	if err != nil {
+		log.Fatalf("UI server failed: %v", err)
 	}
 
 	// Start API server
 	go func() {
-		log.Printf("API server starting on port 23456")
-		if err := apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("API server starting on port %d", apiPort)
+		if err := apiServer.Serve(apiListener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("API server failed: %v", err)
 		}
 	}()
 
 	// Start UI server
 	go func() {
-		log.Printf("UI server starting on port 23457")
-		if err := uiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("UI server starting on port %d", uiPort)
+		if err := uiServer.Serve(uiListener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("UI server failed: %v", err)
 		}
 	}()
 
 	log.Printf("SmsSink v%s is running", Version)
-	log.Println("API endpoint: http://localhost:23456/v2/messages")
-	log.Println("Web UI: http://localhost:23457")
+	log.Printf("API endpoint: http://localhost:%d/v2/messages", apiPort)
+	log.Printf("Web UI: http://localhost:%d", uiPort)
+
+	// Report the bound ports in a parseable form for harnesses that start the mock on :0
+	ports := server.FormatPorts(apiPort, uiPort)
+	os.Stdout.WriteString(ports)
+	if portFile := os.Getenv("SMSSINK_PORT_FILE"); portFile != "" {
+		if err := os.WriteFile(portFile, []byte(ports), 0644); err != nil {
+			log.Printf("Failed to write port file %s: %v", portFile, err)
+		}
+	}
 	if os.Getenv("SMSSINK_DEBUG") == "true" {
 		log.Println("Debug mode: ENABLED (raw request bodies will be logged)")
 	}
@@ -200,8 +294,9 @@ func main() {
 
 	log.Println("Shutting down servers...")
 
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Graceful shutdown with timeout, shared by the servers and the webhook drain
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("SMSSINK_SHUTDOWN_TIMEOUT", 5*time.Second))
 	defer cancel()
 
 	if err := apiServer.Shutdown(ctx); err != nil {
@@ -212,5 +307,33 @@ func main() {
 		log.Printf("Error shutting down UI server: %v", err)
 	}
 
-	log.Println("Servers stopped")
+	// No new messages can arrive now; give pending status webhooks what's left of the timeout
+	if err := webhook.Drain(ctx); err != nil {
+		log.Printf("Shutdown timed out with %d webhook deliveries in flight", webhook.InFlight())
+	}
+
+	log.Printf("Servers stopped in %s", time.Since(start).Round(time.Millisecond))
+}
+
+// envString reads the named env var, returning def when it is unset
+func envString(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// envDuration reads a duration such as "15s" from the named env var, returning def when it is
+// unset or invalid
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("Ignoring invalid %s %q, using %s", name, value, def)
+		return def
+	}
+	return d
 }